@@ -1,6 +1,9 @@
 package acmeserverless
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Carts is a slice of Cart objects
 type Carts []Cart
@@ -120,3 +123,43 @@ type UserIDResponse struct {
 func (r *UserIDResponse) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+// CartAbandoned is the event sent when a cart that still has items expires, so the user can be
+// reminded of the items they left behind.
+type CartAbandoned struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data CartAbandonedData `json:"data"`
+}
+
+// CartAbandonedData is the data of an expired cart.
+type CartAbandonedData struct {
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Items are the items that were in the cart when it expired
+	Items []CartItem `json:"cart"`
+
+	// ItemTotal is the number of items in the cart
+	ItemTotal int64 `json:"cartitemtotal"`
+
+	// ValueTotal is the value of the items in the cart
+	ValueTotal float64 `json:"carttotal"`
+
+	// ExpiredAt is when the cart expired
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+// UnmarshalCartAbandoned parses the JSON-encoded data and stores the result in a CartAbandoned.
+func UnmarshalCartAbandoned(data []byte) (CartAbandoned, error) {
+	var r CartAbandoned
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of CartAbandoned.
+func (e *CartAbandoned) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
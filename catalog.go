@@ -74,3 +74,36 @@ type AllCatalogItemsResponse struct {
 func (r *AllCatalogItemsResponse) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+// ProductUpdated is the event sent when a product in the catalog is added, changed, or removed.
+type ProductUpdated struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data ProductChange `json:"data"`
+}
+
+// ProductChange is the data of a product that was added, changed, or removed.
+type ProductChange struct {
+	// Product is the product after the change, or before it was removed.
+	Product CatalogItem `json:"product"`
+
+	// Previous is the product before the change, if it existed.
+	Previous *CatalogItem `json:"previous,omitempty"`
+
+	// Deleted is true when the product was removed from the catalog.
+	Deleted bool `json:"deleted"`
+}
+
+// UnmarshalProductUpdated parses the JSON-encoded data and stores the result in a ProductUpdated.
+func UnmarshalProductUpdated(data []byte) (ProductUpdated, error) {
+	var r ProductUpdated
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of ProductUpdated.
+func (e *ProductUpdated) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
@@ -16,6 +16,9 @@ const (
 	// ShipmentDomain is the name used for the shipment domain
 	ShipmentDomain = "Shipment"
 
+	// UserDomain is the name used for the user domain
+	UserDomain = "User"
+
 	// CreditCardValidatedEventName is the name used for the CreditCardValidated event
 	CreditCardValidatedEventName = "CreditCardValidatedEvent"
 
@@ -31,6 +34,24 @@ const (
 	// ShipmentDeliveredEventName is the event name of ShipmentDelivered.
 	ShipmentDeliveredEventName = "ShipmentDelivered"
 
+	// PaymentRefundRequestedEventName is the name used for the PaymentRefundRequested event
+	PaymentRefundRequestedEventName = "PaymentRefundRequestedEvent"
+
+	// OrderCancelledEventName is the event name of OrderCancelled.
+	OrderCancelledEventName = "OrderCancelled"
+
+	// CartAbandonedEventName is the event name of CartAbandoned.
+	CartAbandonedEventName = "CartAbandoned"
+
+	// ProductUpdatedEventName is the event name of ProductUpdated.
+	ProductUpdatedEventName = "ProductUpdated"
+
+	// UserRegisteredEventName is the event name of UserRegistered.
+	UserRegisteredEventName = "UserRegistered"
+
+	// OrderUpdatedEventName is the event name of OrderUpdated.
+	OrderUpdatedEventName = "OrderUpdated"
+
 	// DefaultSuccessStatus is a string representation of the default status for success messages
 	DefaultSuccessStatus = "success"
 
@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// CartSweeperSource is the source of the CartAbandoned events of a CartSweeper.
+const CartSweeperSource = "CartSweeper"
+
+// CartAbandonedPublisher publishes the CartAbandoned event of a cart that was removed by a
+// CartSweeper.
+type CartAbandonedPublisher func(ctx context.Context, event acmeserverless.CartAbandoned) error
+
+// CartSweeper removes the carts whose TTL passed, and publishes a CartAbandoned event for each of
+// them that still had items. DynamoDB and MongoDB remove expired carts by themselves as well, but
+// without an event, so the sweeper must run more often than they do. MongoDB waits ExpiryGrace
+// before removing a record, DynamoDB usually removes it within a few days.
+type CartSweeper struct {
+	store     Store
+	publisher CartAbandonedPublisher
+	now       func() time.Time
+}
+
+// SweepResult contains the number of carts a sweep handled.
+type SweepResult struct {
+	// Abandoned is the number of expired carts with items, for which an event was published.
+	Abandoned int
+
+	// Empty is the number of expired carts without items.
+	Empty int
+
+	// Changed is the number of expired carts that were changed during the sweep, which are kept.
+	Changed int
+}
+
+// NewCartSweeper creates a CartSweeper for the carts in s. The store must be a VersionedStore, so a
+// cart that is changed while it is swept isn't removed.
+func NewCartSweeper(s Store, publisher CartAbandonedPublisher) *CartSweeper {
+	return &CartSweeper{
+		store:     s,
+		publisher: publisher,
+		now:       time.Now,
+	}
+}
+
+// WithClock sets the function the CartSweeper uses to get the current time.
+func (c *CartSweeper) WithClock(now func() time.Time) *CartSweeper {
+	c.now = now
+	return c
+}
+
+// Sweep handles all carts that are expired. A cart is removed before its event is published, so a
+// cart that changes during the sweep never gets an event. When the event can't be published, the
+// cart is written back so the next sweep tries again, unless a new cart was created in the meantime.
+func (c *CartSweeper) Sweep(ctx context.Context) (SweepResult, error) {
+	var res SweepResult
+
+	vs, ok := c.store.(VersionedStore)
+	if !ok {
+		return res, fmt.Errorf("datastore: %T doesn't support versioned writes", c.store)
+	}
+
+	recs, err := c.store.List(ctx, PartitionCart)
+	if err != nil {
+		return res, fmt.Errorf("error listing carts: %s", err.Error())
+	}
+
+	now := c.now().Unix()
+	for _, rec := range recs {
+		if rec.TTL == 0 || rec.TTL > now {
+			continue
+		}
+
+		event, abandoned, err := CartAbandonedEvent(rec, CartSweeperSource)
+		if err != nil {
+			return res, err
+		}
+
+		err = vs.DeleteIfVersion(ctx, PartitionCart, rec.SK, rec.Version)
+		if IsConflict(err) {
+			res.Changed++
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("error removing cart of %s: %s", rec.SK, err.Error())
+		}
+
+		if !abandoned {
+			res.Empty++
+			continue
+		}
+
+		if err := c.publisher(ctx, event); err != nil {
+			rec.Version = 0
+			if _, rerr := vs.PutIfVersion(ctx, rec); rerr != nil && !IsConflict(rerr) {
+				return res, fmt.Errorf("error publishing abandoned cart of %s: %s, and error restoring the cart: %s", rec.SK, err.Error(), rerr.Error())
+			}
+			return res, fmt.Errorf("error publishing abandoned cart of %s: %s", rec.SK, err.Error())
+		}
+		res.Abandoned++
+	}
+
+	return res, nil
+}
+
+// CartAbandonedEvent creates the CartAbandoned event of an expired cart, with source as the source
+// in its metadata. It returns false when the cart has no items, as an empty cart isn't abandoned.
+func CartAbandonedEvent(rec Record, source string) (acmeserverless.CartAbandoned, bool, error) {
+	items, err := acmeserverless.UnmarshalItems(rec.Payload)
+	if err != nil {
+		return acmeserverless.CartAbandoned{}, false, fmt.Errorf("error reading cart of %s: %s", rec.SK, err.Error())
+	}
+
+	if len(items) == 0 {
+		return acmeserverless.CartAbandoned{}, false, nil
+	}
+
+	data := acmeserverless.CartAbandonedData{
+		UserID:    rec.SK,
+		Items:     items,
+		ExpiredAt: time.Unix(rec.TTL, 0).UTC(),
+	}
+	for _, item := range items {
+		data.ItemTotal += item.Quantity
+		data.ValueTotal += item.Price * float64(item.Quantity)
+	}
+
+	return acmeserverless.CartAbandoned{
+		Metadata: acmeserverless.Metadata{
+			Domain: acmeserverless.CartDomain,
+			Source: source,
+			Type:   acmeserverless.CartAbandonedEventName,
+			Status: acmeserverless.DefaultSuccessStatus,
+		},
+		Data: data,
+	}, true, nil
+}
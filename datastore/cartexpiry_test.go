@@ -0,0 +1,135 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// putCart stores a cart with the items and TTL.
+func putCart(t *testing.T, s Store, userID string, ttl time.Time, items ...acmeserverless.CartItem) {
+	t.Helper()
+
+	rec, err := CartRecord(userID, items)
+	if err != nil {
+		t.Fatalf("error creating cart record: %s", err.Error())
+	}
+	rec.TTL = 0
+	if !ttl.IsZero() {
+		rec.TTL = ttl.Unix()
+	}
+	if _, err := s.(VersionedStore).PutIfVersion(context.Background(), rec); err != nil {
+		t.Fatalf("error storing cart: %s", err.Error())
+	}
+}
+
+// carts returns the users that have a cart in the store.
+func carts(t *testing.T, s Store) []string {
+	t.Helper()
+
+	recs, err := s.List(context.Background(), PartitionCart)
+	if err != nil {
+		t.Fatalf("error listing carts: %s", err.Error())
+	}
+	return sortKeys(recs)
+}
+
+func TestCartSweeper(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	mat := acmeserverless.CartItem{ItemID: str("product-1"), Name: "Yoga mat", Price: 62.5, Quantity: 2}
+	bottle := acmeserverless.CartItem{ItemID: str("product-2"), Name: "Water bottle", Price: 10, Quantity: 1}
+
+	s := NewMemoryStore()
+	putCart(t, s, "expired", now.Add(-time.Hour), mat, bottle)
+	putCart(t, s, "expired-now", now, bottle)
+	putCart(t, s, "expired-empty", now.Add(-time.Hour))
+	putCart(t, s, "active", now.Add(time.Second), mat)
+	putCart(t, s, "no-ttl", time.Time{}, mat)
+
+	var events []acmeserverless.CartAbandoned
+	sweeper := NewCartSweeper(s, func(ctx context.Context, e acmeserverless.CartAbandoned) error {
+		events = append(events, e)
+		return nil
+	}).WithClock(func() time.Time { return now })
+
+	res, err := sweeper.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("error sweeping carts: %s", err.Error())
+	}
+	if res != (SweepResult{Abandoned: 2, Empty: 1}) {
+		t.Fatalf("result is %+v, want 2 abandoned and 1 empty cart", res)
+	}
+
+	if len(events) != 2 || events[0].Data.UserID != "expired" || events[1].Data.UserID != "expired-now" {
+		t.Fatalf("published %+v, want events for expired and expired-now", events)
+	}
+	e := events[0]
+	if e.Metadata.Type != acmeserverless.CartAbandonedEventName || e.Metadata.Source != CartSweeperSource || e.Metadata.Domain != acmeserverless.CartDomain {
+		t.Fatalf("event has metadata %+v, want a CartAbandoned event of the CartSweeper", e.Metadata)
+	}
+	if e.Data.ItemTotal != 3 || e.Data.ValueTotal != 135 || !e.Data.ExpiredAt.Equal(now.Add(-time.Hour)) || len(e.Data.Items) != 2 {
+		t.Fatalf("event has data %+v, want 3 items worth 135 that expired an hour ago", e.Data)
+	}
+
+	if got := carts(t, s); !reflect.DeepEqual(got, []string{"active", "no-ttl"}) {
+		t.Fatalf("carts of %v are left, want active and no-ttl", got)
+	}
+
+	// A second sweep finds nothing to do
+	if res, err := sweeper.Sweep(context.Background()); err != nil || res != (SweepResult{}) || len(events) != 2 {
+		t.Fatalf("second sweep returned %+v (error %v) with %d events, want nothing swept", res, err, len(events))
+	}
+}
+
+func TestCartSweeperRestoresCartWhenPublishingFails(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	putCart(t, s, "expired", now.Add(-time.Hour), acmeserverless.CartItem{Name: "Yoga mat", Price: 62.5, Quantity: 1})
+
+	sweeper := NewCartSweeper(s, func(ctx context.Context, e acmeserverless.CartAbandoned) error {
+		return errors.New("queue unavailable")
+	}).WithClock(func() time.Time { return now })
+
+	if _, err := sweeper.Sweep(context.Background()); err == nil {
+		t.Fatal("expected an error publishing the event")
+	}
+	if got := carts(t, s); !reflect.DeepEqual(got, []string{"expired"}) {
+		t.Fatalf("carts of %v are left, want the expired cart restored", got)
+	}
+}
+
+// changingStore is a MemoryStore in which every cart changes between listing and removing it.
+type changingStore struct {
+	*MemoryStore
+}
+
+func (c *changingStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	rec, _ := c.Get(ctx, pk, sk)
+	rec.TTL = 0
+	c.PutIfVersion(ctx, rec)
+	return c.MemoryStore.DeleteIfVersion(ctx, pk, sk, version)
+}
+
+func TestCartSweeperKeepsChangedCarts(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := &changingStore{NewMemoryStore()}
+	putCart(t, s, "expired", now.Add(-time.Hour), acmeserverless.CartItem{Name: "Yoga mat", Price: 62.5, Quantity: 1})
+
+	published := 0
+	sweeper := NewCartSweeper(s, func(ctx context.Context, e acmeserverless.CartAbandoned) error {
+		published++
+		return nil
+	}).WithClock(func() time.Time { return now })
+
+	res, err := sweeper.Sweep(context.Background())
+	if err != nil || res != (SweepResult{Changed: 1}) || published != 0 {
+		t.Fatalf("sweep returned %+v (error %v) with %d events, want 1 changed cart without an event", res, err, published)
+	}
+	if got := carts(t, s); !reflect.DeepEqual(got, []string{"expired"}) {
+		t.Fatalf("carts of %v are left, want the changed cart kept", got)
+	}
+}
@@ -0,0 +1,194 @@
+// Package datastore gives the services of the ACME Serverless Fitness Shop access to their data.
+// All data is stored using a single table layout: every record has a partition key (PK) that
+// identifies the kind of data, like USER or ORDER, a sort key (SK) that identifies the record within
+// the partition, an optional KeyID for lookups by a secondary identifier, and a JSON Payload.
+//
+// A Store provides the access patterns of that layout, and the repositories built on top of a Store
+// convert the records into the types of the acmeserverless package.
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// PartitionUser is the partition key of users. The KeyID of a user is the username.
+	PartitionUser = "USER"
+
+	// PartitionProduct is the partition key of catalog items.
+	PartitionProduct = "PRODUCT"
+
+	// PartitionOrder is the partition key of orders. The KeyID of an order is the userid.
+	PartitionOrder = "ORDER"
+
+	// PartitionCart is the partition key of carts. The sort key of a cart is the userid.
+	PartitionCart = "CART"
+)
+
+// ErrNotFound is returned when the requested record doesn't exist.
+var ErrNotFound = errors.New("datastore: record not found")
+
+// Record is a single item in the single table layout.
+type Record struct {
+	// PK is the partition key, like USER or ORDER.
+	PK string
+
+	// SK is the sort key that uniquely identifies the record within the partition.
+	SK string
+
+	// KeyID is a secondary identifier of the record, like the username of a user.
+	KeyID string
+
+	// Payload is the JSON encoding of the data.
+	Payload string
+
+	// Version is incremented by every conditional write of the record. It is 0 for records that
+	// don't exist yet and for records that were written without a version.
+	Version int64
+
+	// TTL is the time, in seconds since the epoch, after which the record expires. It is 0 for
+	// records that don't expire.
+	TTL int64
+}
+
+// Store provides the access patterns of the single table layout.
+type Store interface {
+	// Get returns the record with the given partition and sort key, or ErrNotFound.
+	Get(ctx context.Context, pk string, sk string) (Record, error)
+
+	// Put creates or replaces a record, without checking its version. The Version of rec is
+	// stored as is, so a Put of a record that was read before makes a conditional write based on
+	// an older version fail, while a Put with Version 0 removes the version.
+	Put(ctx context.Context, rec Record) error
+
+	// Delete removes a record. Deleting a record that doesn't exist is not an error.
+	Delete(ctx context.Context, pk string, sk string) error
+
+	// List returns all records in a partition.
+	List(ctx context.Context, pk string) ([]Record, error)
+
+	// QueryKeyID returns all records in a partition with the given KeyID.
+	QueryKeyID(ctx context.Context, pk string, keyID string) ([]Record, error)
+}
+
+// BatchWriter is implemented by stores that can write many records in a single request.
+type BatchWriter interface {
+	// PutBatch writes the records. When some records could not be written it returns a
+	// *BatchError containing those records.
+	PutBatch(ctx context.Context, recs []Record) error
+}
+
+// BatchError is returned by PutBatch when some of the records could not be written.
+type BatchError struct {
+	// Failed contains the records that were not written.
+	Failed []Record
+
+	// Err is the reason the records were not written.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d records not written", len(e.Failed))
+	}
+	return fmt.Sprintf("%d records not written: %s", len(e.Failed), e.Err.Error())
+}
+
+// VersionedStore is implemented by stores that support optimistic concurrency control.
+type VersionedStore interface {
+	// PutIfVersion writes the record only if the stored record still has the Version of rec,
+	// where Version 0 means the record doesn't exist or has no version. It returns the record with
+	// its new Version, or a *ConflictError when the stored record has another version.
+	PutIfVersion(ctx context.Context, rec Record) (Record, error)
+
+	// DeleteIfVersion removes the record only if it still has the given version, and returns a
+	// *ConflictError otherwise. Deleting a record that doesn't exist is a conflict as well.
+	DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error
+}
+
+// ConflictError is returned by PutIfVersion when the record was changed after it was read.
+type ConflictError struct {
+	// PK is the partition key of the record.
+	PK string
+
+	// SK is the sort key of the record.
+	SK string
+
+	// Version is the version the write expected.
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("datastore: %s %s was changed after version %d was read", e.PK, e.SK, e.Version)
+}
+
+// IsConflict reports whether err is, or wraps, a *ConflictError.
+func IsConflict(err error) bool {
+	var ce *ConflictError
+	return errors.As(err, &ce)
+}
+
+// UserRecord converts a user into a record in the USER partition, with the username as KeyID.
+func UserRecord(user acmeserverless.User) (Record, error) {
+	payload, err := user.Marshal()
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		PK:      PartitionUser,
+		SK:      user.ID,
+		KeyID:   user.Username,
+		Payload: string(payload),
+	}, nil
+}
+
+// ProductRecord converts a product into a record in the PRODUCT partition.
+func ProductRecord(product acmeserverless.CatalogItem) (Record, error) {
+	payload, err := product.Marshal()
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		PK:      PartitionProduct,
+		SK:      product.ID,
+		Payload: string(payload),
+	}, nil
+}
+
+// OrderRecord converts an order into a record in the ORDER partition, with the userid as KeyID.
+func OrderRecord(order acmeserverless.Order) (Record, error) {
+	payload, err := order.Marshal()
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		PK:      PartitionOrder,
+		SK:      order.OrderID,
+		KeyID:   order.UserID,
+		Payload: string(payload),
+	}, nil
+}
+
+// CartRecord converts the items in the cart of a user into a record in the CART partition, with
+// the userid as sort key. The cart expires DefaultCartTTL from now.
+func CartRecord(userID string, items acmeserverless.CartItems) (Record, error) {
+	payload, err := items.Marshal()
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		PK:      PartitionCart,
+		SK:      userID,
+		Payload: string(payload),
+		TTL:     time.Now().Add(DefaultCartTTL).Unix(),
+	}, nil
+}
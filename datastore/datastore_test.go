@@ -0,0 +1,40 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func TestOnlyCartsExpire(t *testing.T) {
+	user, err := UserRecord(acmeserverless.User{ID: "user-1", Username: "jdoe"})
+	if err != nil {
+		t.Fatalf("error creating user record: %s", err.Error())
+	}
+	product, err := ProductRecord(acmeserverless.CatalogItem{ID: "product-1", Name: "Yoga mat"})
+	if err != nil {
+		t.Fatalf("error creating product record: %s", err.Error())
+	}
+	order, err := OrderRecord(acmeserverless.Order{OrderID: "order-1", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("error creating order record: %s", err.Error())
+	}
+
+	// DynamoDB and MongoDB remove every record with a TTL, so only carts may have one
+	for _, rec := range []Record{user, product, order} {
+		if rec.TTL != 0 {
+			t.Errorf("%s record %s has TTL %d, want none", rec.PK, rec.SK, rec.TTL)
+		}
+	}
+
+	before := time.Now().Add(DefaultCartTTL).Unix()
+	cart, err := CartRecord("user-1", acmeserverless.CartItems{})
+	if err != nil {
+		t.Fatalf("error creating cart record: %s", err.Error())
+	}
+	after := time.Now().Add(DefaultCartTTL).Unix()
+	if cart.TTL < before || cart.TTL > after {
+		t.Fatalf("cart has TTL %d, want DefaultCartTTL from now", cart.TTL)
+	}
+}
@@ -0,0 +1,410 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// KeyIDIndex is the name of the global secondary index with KeyID as hash key and PK as range key.
+const KeyIDIndex = "KeyID-index"
+
+// DynamoDBStore is a Store backed by a single Amazon DynamoDB table with the string attributes PK
+// and SK as key schema.
+type DynamoDBStore struct {
+	dbs        *dynamodb.DynamoDB
+	table      string
+	keyIDIndex string
+}
+
+// NewDynamoDBStore creates a DynamoDBStore that uses the given table. QueryKeyID uses the index
+// KeyIDIndex, which can be changed using WithKeyIDIndex.
+func NewDynamoDBStore(dbs *dynamodb.DynamoDB, table string) *DynamoDBStore {
+	return &DynamoDBStore{
+		dbs:        dbs,
+		table:      table,
+		keyIDIndex: KeyIDIndex,
+	}
+}
+
+// WithKeyIDIndex sets the name of the global secondary index QueryKeyID uses. With an empty name
+// QueryKeyID queries the partition and filters the records on their KeyID, which reads the entire
+// partition but works for tables without the index.
+func (d *DynamoDBStore) WithKeyIDIndex(name string) *DynamoDBStore {
+	d.keyIDIndex = name
+	return d
+}
+
+// Get returns the record with the given partition and sort key, or ErrNotFound.
+func (d *DynamoDBStore) Get(ctx context.Context, pk string, sk string) (Record, error) {
+	gio, err := d.dbs.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.table),
+		Key:       keys(pk, sk),
+	})
+	if err != nil {
+		return Record{}, err
+	}
+
+	if gio.Item == nil {
+		return Record{}, ErrNotFound
+	}
+
+	return RecordFromItem(gio.Item), nil
+}
+
+// Put creates or replaces a record. Only the KeyID, Payload, Version, and TTL attributes are set,
+// so other attributes of an existing item are kept.
+func (d *DynamoDBStore) Put(ctx context.Context, rec Record) error {
+	expr, en, em := updateExpression(rec, rec.Version)
+
+	_, err := d.dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       keys(rec.PK, rec.SK),
+		ExpressionAttributeNames:  en,
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String(expr),
+	})
+
+	return err
+}
+
+// PutIfVersion writes the record if the item in the table has the Version of rec, using a
+// ConditionExpression, and returns the record with its new Version.
+func (d *DynamoDBStore) PutIfVersion(ctx context.Context, rec Record) (Record, error) {
+	expr, en, em := updateExpression(rec, rec.Version+1)
+
+	cond := "attribute_not_exists(#version)"
+	if rec.Version > 0 {
+		em[":expected"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(rec.Version, 10)),
+		}
+		cond = "#version = :expected"
+	}
+
+	_, err := d.dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       keys(rec.PK, rec.SK),
+		ConditionExpression:       aws.String(cond),
+		ExpressionAttributeNames:  en,
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String(expr),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return Record{}, &ConflictError{PK: rec.PK, SK: rec.SK, Version: rec.Version}
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	rec.Version++
+	return rec, nil
+}
+
+// updateExpression returns the expression that sets the Payload, KeyID, and TTL of the record, and
+// sets the Version to version. A Version or TTL of 0 removes the attribute.
+func updateExpression(rec Record, version int64) (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	en := map[string]*string{
+		"#version": aws.String("Version"),
+		"#ttl":     aws.String("TTL"),
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table data elements
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":payload"] = &dynamodb.AttributeValue{
+		S: aws.String(rec.Payload),
+	}
+
+	set := []string{"Payload = :payload"}
+	var remove []string
+	if len(rec.KeyID) > 0 {
+		em[":keyid"] = &dynamodb.AttributeValue{
+			S: aws.String(rec.KeyID),
+		}
+		set = append(set, "KeyID = :keyid")
+	}
+
+	if version > 0 {
+		em[":version"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(version, 10)),
+		}
+		set = append(set, "#version = :version")
+	} else {
+		remove = append(remove, "#version")
+	}
+
+	if rec.TTL > 0 {
+		em[":ttl"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(rec.TTL, 10)),
+		}
+		set = append(set, "#ttl = :ttl")
+	} else {
+		remove = append(remove, "#ttl")
+	}
+
+	expr := "SET " + strings.Join(set, ", ")
+	if len(remove) > 0 {
+		expr += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	return expr, en, em
+}
+
+// Delete removes a record.
+func (d *DynamoDBStore) Delete(ctx context.Context, pk string, sk string) error {
+	_, err := d.dbs.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.table),
+		Key:       keys(pk, sk),
+	})
+
+	return err
+}
+
+// DeleteIfVersion removes the record if the item in the table has the given version.
+func (d *DynamoDBStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	// DynamoDB doesn't accept an empty map of values, so it is only created when it is used
+	var em map[string]*dynamodb.AttributeValue
+	cond := "attribute_exists(PK) AND attribute_not_exists(#version)"
+	if version > 0 {
+		em = map[string]*dynamodb.AttributeValue{
+			":expected": {N: aws.String(strconv.FormatInt(version, 10))},
+		}
+		cond = "#version = :expected"
+	}
+
+	_, err := d.dbs.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       keys(pk, sk),
+		ConditionExpression:       aws.String(cond),
+		ExpressionAttributeNames:  map[string]*string{"#version": aws.String("Version")},
+		ExpressionAttributeValues: em,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return &ConflictError{PK: pk, SK: sk, Version: version}
+	}
+
+	return err
+}
+
+// List returns all records in a partition.
+func (d *DynamoDBStore) List(ctx context.Context, pk string) ([]Record, error) {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(pk),
+	}
+
+	return d.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: em,
+	})
+}
+
+// QueryKeyID returns all records in a partition with the given KeyID.
+func (d *DynamoDBStore) QueryKeyID(ctx context.Context, pk string, keyID string) ([]Record, error) {
+	if len(d.keyIDIndex) > 0 {
+		return d.QueryIndex(ctx, d.keyIDIndex, map[string]string{"KeyID": keyID, "PK": pk})
+	}
+
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(pk),
+	}
+	em[":keyid"] = &dynamodb.AttributeValue{
+		S: aws.String(keyID),
+	}
+
+	return d.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		FilterExpression:          aws.String("KeyID = :keyid"),
+		ExpressionAttributeValues: em,
+	})
+}
+
+// QueryIndex returns the records in the global secondary index that have the given values for the
+// keys of the index. The keys map the names of the hash key, and optionally the range key, to their
+// values. Indexes that don't project all attributes return records with only the attributes that
+// are projected.
+func (d *DynamoDBStore) QueryIndex(ctx context.Context, index string, keys map[string]string) ([]Record, error) {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	en := make(map[string]*string)
+	em := make(map[string]*dynamodb.AttributeValue)
+	conds := make([]string, 0, len(names))
+	for i, name := range names {
+		en[fmt.Sprintf("#k%d", i)] = aws.String(name)
+		em[fmt.Sprintf(":k%d", i)] = &dynamodb.AttributeValue{
+			S: aws.String(keys[name]),
+		}
+		conds = append(conds, fmt.Sprintf("#k%d = :k%d", i, i))
+	}
+
+	return d.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    aws.String(strings.Join(conds, " AND ")),
+		ExpressionAttributeNames:  en,
+		ExpressionAttributeValues: em,
+	})
+}
+
+// query runs the query and returns the records of all pages.
+func (d *DynamoDBStore) query(ctx context.Context, qi *dynamodb.QueryInput) ([]Record, error) {
+	var recs []Record
+
+	err := d.dbs.QueryPagesWithContext(ctx, qi, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			recs = append(recs, RecordFromItem(item))
+		}
+		return true
+	})
+
+	return recs, err
+}
+
+// keys creates a map of DynamoDB Attribute Values containing the table keys
+func keys(pk string, sk string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(pk),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(sk),
+	}
+	return km
+}
+
+// RecordFromItem converts a DynamoDB item, like an image in a DynamoDB Streams record, into a Record.
+func RecordFromItem(item map[string]*dynamodb.AttributeValue) Record {
+	rec := Record{}
+	if v, ok := item["PK"]; ok {
+		rec.PK = aws.StringValue(v.S)
+	}
+	if v, ok := item["SK"]; ok {
+		rec.SK = aws.StringValue(v.S)
+	}
+	if v, ok := item["KeyID"]; ok {
+		rec.KeyID = aws.StringValue(v.S)
+	}
+	if v, ok := item["Payload"]; ok {
+		rec.Payload = aws.StringValue(v.S)
+	}
+	if v, ok := item["Version"]; ok {
+		rec.Version, _ = strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+	}
+	if v, ok := item["TTL"]; ok {
+		rec.TTL, _ = strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+	}
+	return rec
+}
+
+// maxBatchAttempts is the number of times PutBatch sends records that DynamoDB didn't process,
+// before it gives up on them.
+const maxBatchAttempts = 8
+
+// PutBatch writes the records using BatchWriteItem, in batches of 25 records. Records DynamoDB
+// doesn't process, for example because the table is throttled, are sent again with an exponential
+// backoff. Unlike Put, PutBatch replaces the complete item.
+func (d *DynamoDBStore) PutBatch(ctx context.Context, recs []Record) error {
+	var failed []Record
+	var lastErr error
+
+	for start := 0; start < len(recs); start += 25 {
+		end := start + 25
+		if end > len(recs) {
+			end = len(recs)
+		}
+
+		batch := make(map[string]Record)
+		reqs := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, rec := range recs[start:end] {
+			batch[rec.PK+"#"+rec.SK] = rec
+			reqs = append(reqs, &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{
+					Item: itemFromRecord(rec),
+				},
+			})
+		}
+
+		unprocessed, err := d.batchWrite(ctx, reqs)
+		if err != nil {
+			lastErr = err
+		}
+		for _, req := range unprocessed {
+			rec := RecordFromItem(req.PutRequest.Item)
+			failed = append(failed, batch[rec.PK+"#"+rec.SK])
+		}
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed, Err: lastErr}
+	}
+
+	return nil
+}
+
+// batchWrite sends the write requests until all are processed or maxBatchAttempts is reached, and
+// returns the requests that were not processed.
+func (d *DynamoDBStore) batchWrite(ctx context.Context, reqs []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	backoff := 50 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		bwo, err := d.dbs.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{d.table: reqs},
+		})
+		if err != nil {
+			return reqs, err
+		}
+
+		reqs = bwo.UnprocessedItems[d.table]
+		if len(reqs) == 0 {
+			return nil, nil
+		}
+
+		if attempt == maxBatchAttempts {
+			return reqs, fmt.Errorf("unprocessed after %d attempts", attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return reqs, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// itemFromRecord converts a Record into a DynamoDB item.
+func itemFromRecord(rec Record) map[string]*dynamodb.AttributeValue {
+	item := keys(rec.PK, rec.SK)
+	item["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(rec.Payload),
+	}
+	if len(rec.KeyID) > 0 {
+		item["KeyID"] = &dynamodb.AttributeValue{
+			S: aws.String(rec.KeyID),
+		}
+	}
+	if rec.Version > 0 {
+		item["Version"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(rec.Version, 10)),
+		}
+	}
+	if rec.TTL > 0 {
+		item["TTL"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(rec.TTL, 10)),
+		}
+	}
+	return item
+}
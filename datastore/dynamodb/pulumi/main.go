@@ -33,8 +33,38 @@ type DynamoConfig struct {
 
 	// The number of read units for this table
 	ReadCapacity pulumi.Int `json:"readcapacity"`
+
+	// Additional global secondary indexes for this table
+	Indexes []IndexConfig `json:"indexes"`
 }
 
+// IndexConfig contains the key-value pairs for the configuration of a global secondary index
+type IndexConfig struct {
+	// The name of the index
+	Name string `json:"name"`
+
+	// The name of the hash key of the index, which is created as a string attribute if it isn't one yet
+	HashKey string `json:"hashkey"`
+
+	// The name of the range key of the index (optional), which is created as a string attribute if it isn't one yet
+	RangeKey string `json:"rangekey"`
+
+	// The attributes projected into the index, either ALL or KEYS_ONLY (defaults to ALL)
+	ProjectionType string `json:"projectiontype"`
+}
+
+// KeyIDIndex is the name of the global secondary index to look up records by their KeyID, like users
+// by their username and orders by their userid
+const KeyIDIndex = "KeyID-index"
+
+// PendingIndex is the name of the sparse global secondary index of the messages in the outbox that
+// haven't been published yet, and the sagas that are running or compensating
+const PendingIndex = "Pending-index"
+
+// TTLAttribute is the number attribute that contains the time, in seconds since the epoch, after
+// which DynamoDB removes an item
+const TTLAttribute = "TTL"
+
 func main() {
 	pulumi.Run(func(ctx *pulumi.Context) error {
 		// Read the configuration data from Pulumi.<stack>.yaml
@@ -60,26 +90,73 @@ func main() {
 		tagMap["Stage"] = pulumi.String(ctx.Stack())
 
 		// The table attributes represent a list of attributes that describe the key schema for the table and indexes
-		tableAttributeInput := []dynamodb.TableAttributeInput{
-			dynamodb.TableAttributeArgs{
-				Name: pulumi.String("PK"),
-				Type: pulumi.String("S"),
-			}, dynamodb.TableAttributeArgs{
-				Name: pulumi.String("SK"),
+		tableAttributeInput := []dynamodb.TableAttributeInput{}
+		attributes := make(map[string]bool)
+		addAttribute := func(name string) {
+			if len(name) == 0 || attributes[name] {
+				return
+			}
+			attributes[name] = true
+			tableAttributeInput = append(tableAttributeInput, dynamodb.TableAttributeArgs{
+				Name: pulumi.String(name),
 				Type: pulumi.String("S"),
-			},
+			})
+		}
+		addAttribute("PK")
+		addAttribute("SK")
+
+		// The KeyID and Pending indexes are always created, the other indexes come from the configuration file
+		indexes := append([]IndexConfig{
+			{Name: KeyIDIndex, HashKey: "KeyID", RangeKey: "PK"},
+			{Name: PendingIndex, HashKey: "Pending", RangeKey: "SK"},
+		}, dynamoConfig.Indexes...)
+
+		// The global secondary indexes of the table, which need a read and write capacity when the
+		// table uses provisioned throughput
+		indexInput := []dynamodb.TableGlobalSecondaryIndexInput{}
+		for _, idx := range indexes {
+			addAttribute(idx.HashKey)
+			addAttribute(idx.RangeKey)
+
+			projection := idx.ProjectionType
+			if len(projection) == 0 {
+				projection = "ALL"
+			}
+
+			args := dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String(idx.Name),
+				HashKey:        pulumi.String(idx.HashKey),
+				ProjectionType: pulumi.String(projection),
+			}
+			if len(idx.RangeKey) > 0 {
+				args.RangeKey = pulumi.String(idx.RangeKey)
+			}
+			if dynamoConfig.BillingMode == "PROVISIONED" {
+				args.ReadCapacity = dynamoConfig.ReadCapacity
+				args.WriteCapacity = dynamoConfig.WriteCapacity
+			}
+			indexInput = append(indexInput, args)
 		}
 
 		// The set of arguments for constructing an Amazon DynamoDB Table resource
 		tableArgs := &dynamodb.TableArgs{
-			Attributes:    dynamodb.TableAttributeArray(tableAttributeInput),
-			BillingMode:   pulumi.StringPtrInput(dynamoConfig.BillingMode),
-			HashKey:       pulumi.String("PK"),
-			RangeKey:      pulumi.String("SK"),
-			Tags:          pulumi.Map(tagMap),
-			Name:          pulumi.String(fmt.Sprintf("%s-%s", ctx.Stack(), ctx.Project())),
-			ReadCapacity:  dynamoConfig.ReadCapacity,
-			WriteCapacity: dynamoConfig.WriteCapacity,
+			Attributes:             dynamodb.TableAttributeArray(tableAttributeInput),
+			BillingMode:            pulumi.StringPtrInput(dynamoConfig.BillingMode),
+			GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray(indexInput),
+			HashKey:                pulumi.String("PK"),
+			RangeKey:               pulumi.String("SK"),
+			Tags:                   pulumi.Map(tagMap),
+			Name:                   pulumi.String(fmt.Sprintf("%s-%s", ctx.Stack(), ctx.Project())),
+			ReadCapacity:           dynamoConfig.ReadCapacity,
+			WriteCapacity:          dynamoConfig.WriteCapacity,
+			// Carts, idempotency records, and sent outbox messages are removed when their TTL passes
+			Ttl: &dynamodb.TableTtlArgs{
+				AttributeName: pulumi.String(TTLAttribute),
+				Enabled:       pulumi.Bool(true),
+			},
+			// The stream processor turns changes into events, for which it needs both images of an item
+			StreamEnabled:  pulumi.Bool(true),
+			StreamViewType: pulumi.String("NEW_AND_OLD_IMAGES"),
 		}
 
 		// NewTable registers a new resource with the given unique name, arguments, and options
@@ -88,9 +165,10 @@ func main() {
 			return err
 		}
 
-		// Export the ARN and Name of the table
+		// Export the ARN and Name of the table, and the ARN of its stream
 		ctx.Export("Table::Arn", table.Arn)
 		ctx.Export("Table::Name", table.Name)
+		ctx.Export("Table::StreamArn", table.StreamArn)
 
 		return nil
 	})
@@ -0,0 +1,362 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix starts the value of every encrypted field.
+const encryptedPrefix = "enc:v1:"
+
+// DefaultEncryptedFields are the fields of the payload that an EncryptedStore encrypts in each
+// partition: the passwords and email addresses of users, and the email addresses, delivery
+// addresses, and credit cards of orders.
+var DefaultEncryptedFields = map[string][]string{
+	PartitionUser:  {"password", "email"},
+	PartitionOrder: {"email", "address", "card"},
+}
+
+// EncryptedStore is a Store that encrypts selected fields of the payload of records before they are
+// written to the Store it wraps, and decrypts them when they are read, so the repositories built on
+// top of it only see the data in clear text. Each record is encrypted with a new data key from the
+// KeyProvider using AES-GCM, and each encrypted field is replaced by a string that contains the
+// encrypted data key and the encrypted JSON value of the field. The partition and sort key of the
+// record are authenticated with each field, so an encrypted value can't be moved to another record.
+//
+// Fields that aren't encrypted are read as they are, so existing data is encrypted the next time it
+// is written. The KeyID of a record is never encrypted, as it is used for lookups.
+type EncryptedStore struct {
+	store  Store
+	keys   KeyProvider
+	fields map[string][]string
+}
+
+// NewEncryptedStore creates an EncryptedStore that wraps s, and encrypts the DefaultEncryptedFields
+// with data keys from keys.
+func NewEncryptedStore(s Store, keys KeyProvider) *EncryptedStore {
+	fields := make(map[string][]string, len(DefaultEncryptedFields))
+	for pk, f := range DefaultEncryptedFields {
+		fields[pk] = f
+	}
+
+	return &EncryptedStore{
+		store:  s,
+		keys:   keys,
+		fields: fields,
+	}
+}
+
+// WithFields sets the fields of the payload that are encrypted in a partition. Without fields,
+// the records of the partition aren't encrypted.
+func (e *EncryptedStore) WithFields(pk string, fields ...string) *EncryptedStore {
+	if len(fields) == 0 {
+		delete(e.fields, pk)
+	} else {
+		e.fields[pk] = fields
+	}
+	return e
+}
+
+// Get returns the decrypted record with the given partition and sort key, or ErrNotFound.
+func (e *EncryptedStore) Get(ctx context.Context, pk string, sk string) (Record, error) {
+	rec, err := e.store.Get(ctx, pk, sk)
+	if err != nil {
+		return rec, err
+	}
+
+	return e.Decrypt(ctx, rec)
+}
+
+// Put encrypts the record and creates or replaces it.
+func (e *EncryptedStore) Put(ctx context.Context, rec Record) error {
+	enc, err := e.Encrypt(ctx, rec)
+	if err != nil {
+		return err
+	}
+
+	return e.store.Put(ctx, enc)
+}
+
+// Delete removes a record.
+func (e *EncryptedStore) Delete(ctx context.Context, pk string, sk string) error {
+	return e.store.Delete(ctx, pk, sk)
+}
+
+// List returns all decrypted records in a partition.
+func (e *EncryptedStore) List(ctx context.Context, pk string) ([]Record, error) {
+	recs, err := e.store.List(ctx, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decryptAll(ctx, recs)
+}
+
+// QueryKeyID returns all decrypted records in a partition with the given KeyID.
+func (e *EncryptedStore) QueryKeyID(ctx context.Context, pk string, keyID string) ([]Record, error) {
+	recs, err := e.store.QueryKeyID(ctx, pk, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decryptAll(ctx, recs)
+}
+
+// PutIfVersion encrypts the record and writes it if the stored record has the Version of rec. The
+// wrapped store must be a VersionedStore.
+func (e *EncryptedStore) PutIfVersion(ctx context.Context, rec Record) (Record, error) {
+	vs, ok := e.store.(VersionedStore)
+	if !ok {
+		return Record{}, fmt.Errorf("datastore: %T doesn't support versioned writes", e.store)
+	}
+
+	enc, err := e.Encrypt(ctx, rec)
+	if err != nil {
+		return Record{}, err
+	}
+
+	written, err := vs.PutIfVersion(ctx, enc)
+	if err != nil {
+		return written, err
+	}
+
+	written.Payload = rec.Payload
+	return written, nil
+}
+
+// DeleteIfVersion removes the record if it has the given version. The wrapped store must be a
+// VersionedStore.
+func (e *EncryptedStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	vs, ok := e.store.(VersionedStore)
+	if !ok {
+		return fmt.Errorf("datastore: %T doesn't support versioned writes", e.store)
+	}
+
+	return vs.DeleteIfVersion(ctx, pk, sk, version)
+}
+
+// PutBatch encrypts the records and writes them. When the wrapped store isn't a BatchWriter, the
+// records are written one by one. The failed records of a *BatchError are the records as they
+// were passed to PutBatch.
+func (e *EncryptedStore) PutBatch(ctx context.Context, recs []Record) error {
+	encs := make([]Record, len(recs))
+	originals := make(map[[2]string]Record, len(recs))
+	for i, rec := range recs {
+		enc, err := e.Encrypt(ctx, rec)
+		if err != nil {
+			return err
+		}
+		encs[i] = enc
+		originals[[2]string{rec.PK, rec.SK}] = rec
+	}
+
+	bw, ok := e.store.(BatchWriter)
+	if !ok {
+		for _, enc := range encs {
+			if err := e.store.Put(ctx, enc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := bw.PutBatch(ctx, encs)
+	if be, ok := err.(*BatchError); ok {
+		failed := make([]Record, len(be.Failed))
+		for i, rec := range be.Failed {
+			failed[i] = originals[[2]string{rec.PK, rec.SK}]
+		}
+		return &BatchError{Failed: failed, Err: be.Err}
+	}
+
+	return err
+}
+
+// Drop removes all records of the partition. The wrapped store must be a Dropper.
+func (e *EncryptedStore) Drop(ctx context.Context, pk string) error {
+	d, ok := e.store.(Dropper)
+	if !ok {
+		return fmt.Errorf("datastore: %T can't drop partitions", e.store)
+	}
+
+	return d.Drop(ctx, pk)
+}
+
+// Encrypt returns the record with the fields of its partition encrypted. Fields that are missing,
+// null, or already encrypted are left as they are.
+func (e *EncryptedStore) Encrypt(ctx context.Context, rec Record) (Record, error) {
+	fields := e.fields[rec.PK]
+	if len(fields) == 0 {
+		return rec, nil
+	}
+
+	var key, encryptedKey []byte
+	payload, err := rewriteFields(rec.Payload, fields, func(name string, value json.RawMessage) (json.RawMessage, error) {
+		if bytes.Equal(value, []byte("null")) || isEncrypted(value) {
+			return value, nil
+		}
+
+		// All fields of a record share a single data key
+		if key == nil {
+			var err error
+			if key, encryptedKey, err = e.keys.GenerateDataKey(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed, err := seal(aead, value, additionalData(rec, name))
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(encryptedPrefix + base64.StdEncoding.EncodeToString(encryptedKey) + ":" + base64.StdEncoding.EncodeToString(sealed))
+	})
+	if err != nil {
+		return rec, fmt.Errorf("error encrypting %s %s: %s", rec.PK, rec.SK, err.Error())
+	}
+
+	rec.Payload = payload
+	return rec, nil
+}
+
+// Decrypt returns the record with all encrypted fields of its partition decrypted.
+func (e *EncryptedStore) Decrypt(ctx context.Context, rec Record) (Record, error) {
+	fields := e.fields[rec.PK]
+	if len(fields) == 0 {
+		return rec, nil
+	}
+
+	keys := make(map[string][]byte)
+	payload, err := rewriteFields(rec.Payload, fields, func(name string, value json.RawMessage) (json.RawMessage, error) {
+		if !isEncrypted(value) {
+			return value, nil
+		}
+
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+
+		parts := strings.Split(strings.TrimPrefix(s, encryptedPrefix), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("field %s is not a valid encrypted value", name)
+		}
+
+		key, ok := keys[parts[0]]
+		if !ok {
+			encryptedKey, err := base64.StdEncoding.DecodeString(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			if key, err = e.keys.DecryptDataKey(ctx, encryptedKey); err != nil {
+				return nil, err
+			}
+			keys[parts[0]] = key
+		}
+
+		sealed, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return open(aead, sealed, additionalData(rec, name))
+	})
+	if err != nil {
+		return rec, fmt.Errorf("error decrypting %s %s: %s", rec.PK, rec.SK, err.Error())
+	}
+
+	rec.Payload = payload
+	return rec, nil
+}
+
+// decryptAll decrypts the records.
+func (e *EncryptedStore) decryptAll(ctx context.Context, recs []Record) ([]Record, error) {
+	for i, rec := range recs {
+		dec, err := e.Decrypt(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		recs[i] = dec
+	}
+
+	return recs, nil
+}
+
+// additionalData binds an encrypted field to its record.
+func additionalData(rec Record, field string) []byte {
+	return []byte(rec.PK + "\x00" + rec.SK + "\x00" + field)
+}
+
+// isEncrypted reports whether a JSON value is an encrypted field.
+func isEncrypted(value json.RawMessage) bool {
+	return bytes.HasPrefix(value, []byte(`"`+encryptedPrefix))
+}
+
+// rewriteFields replaces the values of the named top level fields of a JSON object with the result
+// of fn. The order of the fields and all other values are kept as they are, and a payload without
+// changes is returned unchanged, so checksums of records that don't need to change stay the same.
+// Payloads that aren't JSON objects, like carts, are returned unchanged.
+func rewriteFields(payload string, fields []string, fn func(name string, value json.RawMessage) (json.RawMessage, error)) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(payload))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return payload, nil
+	}
+
+	selected := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		selected[f] = true
+	}
+
+	var buf bytes.Buffer
+	changed := false
+	buf.WriteByte('{')
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return payload, err
+		}
+		name, _ := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return payload, err
+		}
+
+		if selected[name] {
+			v, err := fn(name, value)
+			if err != nil {
+				return payload, err
+			}
+			changed = changed || !bytes.Equal(v, value)
+			value = v
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	if !changed {
+		return payload, nil
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,142 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func newTestKeyProvider(t *testing.T, b byte) *LocalKeyProvider {
+	t.Helper()
+
+	keys, err := NewLocalKeyProvider(bytes.Repeat([]byte{b}, DataKeySize))
+	if err != nil {
+		t.Fatalf("error creating key provider: %s", err.Error())
+	}
+	return keys
+}
+
+// rewriteField changes the encrypted value of a field in the payload of the stored record.
+func rewriteField(t *testing.T, s Store, pk string, sk string, field string, fn func(value string) string) {
+	t.Helper()
+	ctx := context.Background()
+
+	rec, err := s.Get(ctx, pk, sk)
+	if err != nil {
+		t.Fatalf("error reading record: %s", err.Error())
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(rec.Payload), &payload); err != nil {
+		t.Fatalf("error reading payload: %s", err.Error())
+	}
+	payload[field] = fn(payload[field].(string))
+
+	data, _ := json.Marshal(payload)
+	rec.Payload = string(data)
+	if err := s.Put(ctx, rec); err != nil {
+		t.Fatalf("error writing record: %s", err.Error())
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStore()
+	keys := newTestKeyProvider(t, 1)
+	users := NewRepositories(NewEncryptedStore(mem, keys)).Users
+
+	jdoe := acmeserverless.User{ID: "user-1", Username: "jdoe", Password: "secret", Firstname: "John", Lastname: "Doe", Email: "jdoe@example.com"}
+	if err := users.Put(ctx, jdoe); err != nil {
+		t.Fatalf("error storing user: %s", err.Error())
+	}
+
+	// Only the encrypted fields are unreadable in the wrapped store
+	rec, _ := mem.Get(ctx, PartitionUser, "user-1")
+	if strings.Contains(rec.Payload, "secret") || strings.Contains(rec.Payload, "jdoe@example.com") {
+		t.Fatalf("stored payload %s contains the password or email", rec.Payload)
+	}
+	if !strings.Contains(rec.Payload, `"firstname":"John"`) || rec.KeyID != "jdoe" {
+		t.Fatalf("stored record %+v, want the firstname and KeyID in clear text", rec)
+	}
+
+	got, err := users.Get(ctx, "user-1")
+	if err != nil || got != jdoe {
+		t.Fatalf("user is %+v (error %v), want %+v", got, err, jdoe)
+	}
+	found, err := users.FindByUsername(ctx, "jdoe")
+	if err != nil || len(found) != 1 || found[0] != jdoe {
+		t.Fatalf("users named jdoe are %+v (error %v), want %+v", found, err, jdoe)
+	}
+
+	// Records written before encryption was enabled are read as they are
+	plain := acmeserverless.User{ID: "user-2", Username: "jane", Password: "old", Email: "jane@example.com"}
+	if err := NewUserRepository(mem).Put(ctx, plain); err != nil {
+		t.Fatalf("error storing user: %s", err.Error())
+	}
+	if got, err := users.Get(ctx, "user-2"); err != nil || got != plain {
+		t.Fatalf("unencrypted user is %+v (error %v), want %+v", got, err, plain)
+	}
+}
+
+func TestEncryptedStoreRejectsChangedData(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, mem *MemoryStore)
+		keys   byte
+	}{
+		{
+			name: "changed ciphertext",
+			change: func(t *testing.T, mem *MemoryStore) {
+				rewriteField(t, mem, PartitionUser, "user-1", "password", func(value string) string {
+					parts := strings.Split(value, ":")
+					sealed, _ := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+					sealed[len(sealed)-1] ^= 1
+					parts[len(parts)-1] = base64.StdEncoding.EncodeToString(sealed)
+					return strings.Join(parts, ":")
+				})
+			},
+			keys: 1,
+		},
+		{
+			name: "value of another record",
+			change: func(t *testing.T, mem *MemoryStore) {
+				other, _ := mem.Get(ctx, PartitionUser, "user-2")
+				var payload map[string]interface{}
+				json.Unmarshal([]byte(other.Payload), &payload)
+				rewriteField(t, mem, PartitionUser, "user-1", "password", func(string) string {
+					return payload["password"].(string)
+				})
+			},
+			keys: 1,
+		},
+		{
+			name:   "other master key",
+			change: func(t *testing.T, mem *MemoryStore) {},
+			keys:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		mem := NewMemoryStore()
+		users := NewRepositories(NewEncryptedStore(mem, newTestKeyProvider(t, 1))).Users
+		for _, u := range []acmeserverless.User{{ID: "user-1", Password: "secret"}, {ID: "user-2", Password: "other"}} {
+			if err := users.Put(ctx, u); err != nil {
+				t.Fatalf("%s: error storing user: %s", tt.name, err.Error())
+			}
+		}
+
+		tt.change(t, mem)
+
+		reader := NewRepositories(NewEncryptedStore(mem, newTestKeyProvider(t, tt.keys))).Users
+		if got, err := reader.Get(ctx, "user-1"); err == nil {
+			t.Errorf("%s: read user %+v, want an error", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Export reads all records of the selected entities using the repositories.
+func Export(ctx context.Context, repos *Repositories, entities []string) (*SeedData, error) {
+	data := &SeedData{}
+
+	for _, e := range entities {
+		var err error
+		switch e {
+		case EntityUsers:
+			data.Users, err = repos.Users.List(ctx)
+		case EntityCatalog:
+			data.Catalog, err = repos.Catalog.List(ctx)
+		case EntityOrders:
+			data.Orders, err = repos.Orders.List(ctx)
+		case EntityCarts:
+			data.Carts, err = repos.Carts.List(ctx)
+		default:
+			return nil, fmt.Errorf("unknown entity %q", e)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", e, err.Error())
+		}
+	}
+
+	return data, nil
+}
+
+// WriteSeedData writes the files of the selected entities to dir, in the same format LoadSeedData
+// reads them. Existing files are replaced.
+func WriteSeedData(dir string, data *SeedData, entities []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %s", dir, err.Error())
+	}
+
+	for _, e := range entities {
+		var v interface{}
+		switch e {
+		case EntityUsers:
+			v = data.Users
+		case EntityCatalog:
+			v = data.Catalog
+		case EntityOrders:
+			v = data.Orders
+		case EntityCarts:
+			v = data.Carts
+		default:
+			return fmt.Errorf("unknown entity %q", e)
+		}
+
+		b, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return fmt.Errorf("error encoding %s: %s", e, err.Error())
+		}
+
+		file := filepath.Join(dir, SeedFiles[e])
+		if err := ioutil.WriteFile(file, append(b, '\n'), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %s", file, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/target"
+)
+
+var (
+	outDir string
+	only   string
+	config target.Config
+)
+
+func main() {
+	// Read flags
+	config.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&outDir, "out-dir", ".", "The directory to write the data files to (defaults to the current directory)")
+	flag.StringVar(&only, "only", "", "A comma separated list of entities to export: users, catalog, orders, carts (defaults to all)")
+	flag.Parse()
+
+	entities, err := datastore.ParseEntities(only)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	// Initialize the database connection
+	t, err := target.Open(ctx, config)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+	defer t.Close()
+
+	// Read all data before writing any file, so a failure doesn't leave a partial export behind
+	data, err := datastore.Export(ctx, t.Repositories, entities)
+	if err != nil {
+		t.Close()
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	if err := datastore.WriteSeedData(outDir, data, entities); err != nil {
+		t.Close()
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	log.Printf("exported %d users, %d products, %d orders, and %d carts from %s to %s", len(data.Users), len(data.Catalog), len(data.Orders), len(data.Carts), config.Target, outDir)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/generate"
+)
+
+var (
+	schemaDir string
+	outDir    string
+	rows      string
+	seed      int64
+)
+
+func main() {
+	// Read flags
+	flag.StringVar(&schemaDir, "schema-dir", "../seed", "The directory containing the schema files (defaults to ../seed)")
+	flag.StringVar(&outDir, "out-dir", ".", "The directory to write the data files to (defaults to the current directory)")
+	flag.StringVar(&rows, "rows", "", "A comma separated list of the number of rows per entity, like users=100,orders=500 (defaults to num_rows of the schema)")
+	flag.Int64Var(&seed, "seed", 0, "The seed of the random generator, the same seed always results in the same data (defaults to a random seed)")
+	flag.Parse()
+
+	counts, err := parseRows(rows)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	data, err := generate.SeedData(generate.New(seed), schemaDir, counts)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	if err := datastore.WriteSeedData(outDir, data, datastore.Entities); err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	log.Printf("generated %d users, %d products, %d orders, and %d carts in %s (seed %d)", len(data.Users), len(data.Catalog), len(data.Orders), len(data.Carts), outDir, seed)
+}
+
+// parseRows parses a list like users=100,orders=500.
+func parseRows(list string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(strings.TrimSpace(list)) == 0 {
+		return counts, nil
+	}
+
+	for _, pair := range strings.Split(list, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rows %q, must be like users=100", pair)
+		}
+
+		entities, err := datastore.ParseEntities(kv[0])
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid number of rows %q for %s", kv[1], kv[0])
+		}
+		counts[entities[0]] = n
+	}
+
+	return counts, nil
+}
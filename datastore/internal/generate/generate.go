@@ -0,0 +1,443 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// Row is a single generated row.
+type Row map[string]interface{}
+
+// Generator creates rows from schemas. Rows generated earlier are kept as datasets, so columns of
+// later schemas can refer to them using the Dataset Column type.
+type Generator struct {
+	rnd      *rand.Rand
+	datasets map[string][]Row
+	unused   map[string][]int
+	unique   map[string]map[string]bool
+}
+
+// New creates a Generator. The same seed always results in the same data.
+func New(seed int64) *Generator {
+	return &Generator{
+		rnd:      rand.New(rand.NewSource(seed)),
+		datasets: make(map[string][]Row),
+		unused:   make(map[string][]int),
+		unique:   make(map[string]map[string]bool),
+	}
+}
+
+// WithUnique makes the Dataset Column with the given name use every row of its dataset at most once,
+// when the rows of the dataset are generated.
+func (g *Generator) WithUnique(dataset string, column string) *Generator {
+	if g.unique[dataset] == nil {
+		g.unique[dataset] = make(map[string]bool)
+	}
+	g.unique[dataset][column] = true
+	return g
+}
+
+// scope contains the choices shared by the columns of a single row, or a single item of a JSON
+// Array, so that for example the name and price of an item come from the same product.
+type scope struct {
+	row      int
+	picked   map[string]int
+	cardType string
+}
+
+// Generate creates the rows of the schema and keeps them as the dataset with the given name.
+func (g *Generator) Generate(s *Schema, dataset string, rows int) ([]Row, error) {
+	out := make([]Row, 0, rows)
+
+	cols := make([]Column, len(s.Columns))
+	for i, col := range s.Columns {
+		col.Unique = g.unique[dataset][col.Name]
+		cols[i] = col
+	}
+
+	for i := 0; i < rows; i++ {
+		row, err := g.object(cols, i)
+		if err != nil {
+			return nil, fmt.Errorf("error generating %s: %s", dataset, err.Error())
+		}
+		out = append(out, row)
+	}
+
+	g.datasets[dataset] = out
+	return out, nil
+}
+
+// object creates a single row, or a single item of a JSON Array, from the columns.
+func (g *Generator) object(cols []Column, index int) (Row, error) {
+	row := make(Row)
+	sc := &scope{row: index, picked: make(map[string]int)}
+	children := make(map[string]bool)
+
+	for _, col := range cols {
+		if children[col.Name] {
+			continue
+		}
+
+		if col.Type == "JSON Array" {
+			var sub []Column
+			for _, c := range cols {
+				if strings.HasPrefix(c.Name, col.Name+".") {
+					children[c.Name] = true
+					c.Name = strings.TrimPrefix(c.Name, col.Name+".")
+					sub = append(sub, c)
+				}
+			}
+
+			n := col.MinItems
+			if col.MaxItems > col.MinItems {
+				n += g.rnd.Intn(col.MaxItems - col.MinItems + 1)
+			}
+
+			items := make([]interface{}, 0, n)
+			for i := 0; i < n; i++ {
+				item, err := g.object(sub, i)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			set(row, col.Name, items)
+			continue
+		}
+
+		name, count, err := arrayName(col.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if count < 0 {
+			v, err := g.column(col, sc)
+			if err != nil {
+				return nil, err
+			}
+			set(row, name, v)
+			continue
+		}
+
+		values := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			v, err := g.column(col, sc)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		set(row, name, values)
+	}
+
+	return row, nil
+}
+
+// arrayRegexp matches names like tags[3].
+var arrayRegexp = regexp.MustCompile(`^(.+)\[(\d+)\]$`)
+
+// arrayName splits a name like tags[3] into tags and 3. For other names the count is -1.
+func arrayName(name string) (string, int, error) {
+	m := arrayRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return name, -1, nil
+	}
+
+	count, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid array size in %q", name)
+	}
+
+	return m[1], count, nil
+}
+
+// set stores the value in the row, creating the nested objects of names like address.street.
+func set(row Row, name string, v interface{}) {
+	parts := strings.Split(name, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := row[p].(Row)
+		if !ok {
+			next = make(Row)
+			row[p] = next
+		}
+		row = next
+	}
+	row[parts[len(parts)-1]] = v
+}
+
+// column creates the value of a column and applies its formula.
+func (g *Generator) column(col Column, sc *scope) (interface{}, error) {
+	if col.NullPercentage > 0 && g.rnd.Float64()*100 < col.NullPercentage {
+		return nil, nil
+	}
+
+	v, err := g.value(col, sc)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %s", col.Name, err.Error())
+	}
+
+	v, err = formula(col, v)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %s", col.Name, err.Error())
+	}
+
+	return v, nil
+}
+
+// value creates a value of the type of the column.
+func (g *Generator) value(col Column, sc *scope) (interface{}, error) {
+	switch col.Type {
+	case "Row Number":
+		return sc.row + 1, nil
+	case "GUID":
+		return g.guid(), nil
+	case "Boolean":
+		return g.rnd.Intn(2) == 1, nil
+	case "Number":
+		return g.number(col), nil
+	case "Custom List":
+		if len(col.Values) == 0 {
+			return nil, fmt.Errorf("custom list has no values")
+		}
+		return g.pick(col.Values), nil
+	case "Words":
+		min, max := 1, 10
+		if col.Min != nil {
+			min = int(*col.Min)
+		}
+		if col.Max != nil {
+			max = int(*col.Max)
+		}
+		return g.words(min, max), nil
+	case "First Name":
+		return g.pick(firstNames), nil
+	case "Last Name":
+		return g.pick(lastNames), nil
+	case "Username":
+		return strings.ToLower(g.pick(firstNames)[:1]+g.pick(lastNames)) + strconv.Itoa(g.rnd.Intn(100)), nil
+	case "Email Address":
+		return strings.ToLower(g.pick(firstNames)[:1]+g.pick(lastNames)) + strconv.Itoa(g.rnd.Intn(100)) + "@" + g.pick(domains), nil
+	case "Password":
+		return g.password(), nil
+	case "Gender":
+		return g.pick([]string{"Male", "Female"}), nil
+	case "IP Address v4":
+		return fmt.Sprintf("%d.%d.%d.%d", 1+g.rnd.Intn(223), g.rnd.Intn(256), g.rnd.Intn(256), 1+g.rnd.Intn(254)), nil
+	case "Street Name":
+		return g.pick(streets), nil
+	case "City":
+		return g.pick(cities), nil
+	case "Postal Code":
+		return fmt.Sprintf("%05d", g.rnd.Intn(100000)), nil
+	case "State (abbrev)":
+		return g.pick(states), nil
+	case "Country":
+		if len(col.Countries) > 0 {
+			return g.pick(col.Countries), nil
+		}
+		return g.pick(countries), nil
+	case "Product (Grocery)":
+		return g.pick(products), nil
+	case "Slogan":
+		return g.pick(sloganVerbs) + " " + g.pick(sloganAdjectives) + " " + g.pick(sloganNouns), nil
+	case "Movie Genres":
+		n := 1 + g.rnd.Intn(3)
+		genres := make([]string, 0, n)
+		for _, i := range g.rnd.Perm(len(movieGenres))[:n] {
+			genres = append(genres, movieGenres[i])
+		}
+		return strings.Join(genres, "|"), nil
+	case "Dummy Image URL":
+		return fmt.Sprintf("http://dummyimage.com/%dx%d.%s/%s/ffffff", between(g.rnd, col.MinWidth, col.MaxWidth, 100), between(g.rnd, col.MinHeight, col.MaxHeight, 100), g.pick(imageFormats), g.pick(imageColors)), nil
+	case "Credit Card Type":
+		sc.cardType = g.pick(cardTypes)
+		return sc.cardType, nil
+	case "Credit Card #":
+		if len(sc.cardType) == 0 {
+			sc.cardType = g.pick(cardTypes)
+		}
+		return g.cardNumber(sc.cardType), nil
+	case "Dataset Column":
+		return g.datasetColumn(col, sc)
+	default:
+		return nil, fmt.Errorf("unsupported type %q", col.Type)
+	}
+}
+
+// datasetColumn returns the field of a row in the dataset. All columns in the same scope that refer
+// to the same dataset use the same row.
+func (g *Generator) datasetColumn(col Column, sc *scope) (interface{}, error) {
+	rows, ok := g.datasets[col.Dataset]
+	if !ok {
+		return nil, fmt.Errorf("dataset %q must be generated first", col.Dataset)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("dataset %q is empty", col.Dataset)
+	}
+
+	i, ok := sc.picked[col.Dataset]
+	if !ok {
+		if col.Unique {
+			unused, ok := g.unused[col.Dataset]
+			if !ok {
+				unused = g.rnd.Perm(len(rows))
+			}
+			if len(unused) == 0 {
+				return nil, fmt.Errorf("all %d rows of dataset %q are used", len(rows), col.Dataset)
+			}
+			i, g.unused[col.Dataset] = unused[0], unused[1:]
+		} else {
+			i = g.rnd.Intn(len(rows))
+		}
+		sc.picked[col.Dataset] = i
+	}
+
+	v, ok := rows[i][col.Column]
+	if !ok {
+		return nil, fmt.Errorf("dataset %q has no column %q", col.Dataset, col.Column)
+	}
+
+	return v, nil
+}
+
+// guid returns a random version 4 UUID, using the random source of the Generator.
+func (g *Generator) guid() string {
+	b := make([]byte, 16)
+	g.rnd.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return uuid.FromBytesOrNil(b).String()
+}
+
+// number returns an int when the column has no decimals, and a float64 otherwise. The number is
+// between the min and max of the column, which default to 0 and 100.
+func (g *Generator) number(col Column) interface{} {
+	min, max := 0.0, 100.0
+	if col.Min != nil {
+		min = *col.Min
+	}
+	if col.Max != nil {
+		max = *col.Max
+	}
+
+	// Like between, a maximum below the minimum, which can be the default maximum, returns the minimum
+	if max < min {
+		max = min
+	}
+
+	if col.Decimals == 0 {
+		return int(min) + g.rnd.Intn(int(max)-int(min)+1)
+	}
+
+	pow := math.Pow(10, float64(col.Decimals))
+	return math.Round((min+g.rnd.Float64()*(max-min))*pow) / pow
+}
+
+// words returns between min and max lorem ipsum words.
+func (g *Generator) words(min int, max int) string {
+	n := between(g.rnd, min, max, 1)
+	words := make([]string, n)
+	for i := range words {
+		words[i] = g.pick(lorem)
+	}
+	return strings.Join(words, " ")
+}
+
+// password returns a random password of eight letters and digits.
+func (g *Generator) password() string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = chars[g.rnd.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// cardNumber returns a card number of the type that passes the Luhn check.
+func (g *Generator) cardNumber(cardType string) string {
+	format := cardFormats[cardType]
+	number := []byte(g.pick(format.prefixes))
+	for len(number) < format.length-1 {
+		number = append(number, byte('0'+g.rnd.Intn(10)))
+	}
+
+	// Calculate the check digit, doubling every second digit from the right
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if (len(number)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return string(append(number, byte('0'+(10-sum%10)%10)))
+}
+
+// pick returns a random element of the list.
+func (g *Generator) pick(list []string) string {
+	return list[g.rnd.Intn(len(list))]
+}
+
+// between returns a random number between min and max, or def when neither is set.
+func between(rnd *rand.Rand, min int, max int, def int) int {
+	if min == 0 && max == 0 {
+		return def
+	}
+	if max <= min {
+		return min
+	}
+	return min + rnd.Intn(max-min+1)
+}
+
+// formula applies the formula of the column to the value.
+func formula(col Column, v interface{}) (interface{}, error) {
+	f := strings.TrimSpace(col.Formula)
+
+	switch {
+	case len(f) == 0:
+		return v, nil
+	case f == "lower(this)":
+		return strings.ToLower(toString(col, v)), nil
+	case f == "upper(this)":
+		return strings.ToUpper(toString(col, v)), nil
+	case strings.HasPrefix(f, "concat(") && strings.HasSuffix(f, ")"):
+		var sb strings.Builder
+		for _, arg := range strings.Split(f[len("concat("):len(f)-1], ",") {
+			arg = strings.TrimSpace(arg)
+			switch {
+			case arg == "this":
+				sb.WriteString(toString(col, v))
+			case len(arg) >= 2 && strings.HasPrefix(arg, `"`) && strings.HasSuffix(arg, `"`):
+				sb.WriteString(arg[1 : len(arg)-1])
+			default:
+				return nil, fmt.Errorf("unsupported argument %q in formula %q", arg, f)
+			}
+		}
+		return sb.String(), nil
+	default:
+		return nil, fmt.Errorf("unsupported formula %q", f)
+	}
+}
+
+// toString formats the value, using the decimals of the column for numbers.
+func toString(col Column, v interface{}) string {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'f', col.Decimals, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
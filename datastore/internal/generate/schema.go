@@ -0,0 +1,165 @@
+// Package generate creates synthetic seed data from the Mockaroo schema files in the seed directory.
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/retgits/acme-serverless/datastore"
+)
+
+// Schema is a Mockaroo schema that describes the rows of a single data file.
+type Schema struct {
+	// Name is the name of the schema.
+	Name string `json:"name"`
+
+	// NumRows is the default number of rows to generate.
+	NumRows int `json:"num_rows"`
+
+	// Columns describe the fields of each row.
+	Columns []Column `json:"columns"`
+}
+
+// Column describes a single field of a row. A name like address.street creates a nested object, a
+// name like tags[3] creates an array of three values, and a column named cart.id describes the
+// field id of the items of the JSON Array column cart.
+type Column struct {
+	// Name is the name of the field.
+	Name string `json:"name"`
+
+	// Type is the Mockaroo type of the field, like GUID or First Name.
+	Type string `json:"type"`
+
+	// NullPercentage is the chance, between 0 and 100, that the field is null.
+	NullPercentage float64 `json:"null_percentage"`
+
+	// Formula is applied to the generated value. Only lower(this), upper(this), and concat with
+	// strings and this are supported.
+	Formula string `json:"formula"`
+
+	// Min is the lowest value of a Number, or the lowest number of words of Words.
+	Min *float64 `json:"min,omitempty"`
+
+	// Max is the highest value of a Number, or the highest number of words of Words.
+	Max *float64 `json:"max,omitempty"`
+
+	// Decimals is the number of decimals of a Number.
+	Decimals int `json:"decimals"`
+
+	// Values are the values of a Custom List.
+	Values []string `json:"values,omitempty"`
+
+	// Countries limits the values of a Country.
+	Countries []string `json:"countries,omitempty"`
+
+	// MinItems is the lowest number of items of a JSON Array.
+	MinItems int `json:"minItems"`
+
+	// MaxItems is the highest number of items of a JSON Array.
+	MaxItems int `json:"maxItems"`
+
+	// MinWidth, MaxWidth, MinHeight, and MaxHeight are the size of a Dummy Image URL.
+	MinWidth  int `json:"minWidth"`
+	MaxWidth  int `json:"maxWidth"`
+	MinHeight int `json:"minHeight"`
+	MaxHeight int `json:"maxHeight"`
+
+	// Dataset is the name of the data a Dataset Column refers to, like users or catalog.
+	Dataset string `json:"dataset,omitempty"`
+
+	// Column is the field of the dataset a Dataset Column refers to, like id.
+	Column string `json:"column,omitempty"`
+
+	// Unique makes a Dataset Column use every row of the dataset at most once. It isn't part of the
+	// Mockaroo format, so it isn't read from the schema file but set with Generator.WithUnique.
+	Unique bool `json:"-"`
+}
+
+// LoadSchema reads a schema file.
+func LoadSchema(file string) (*Schema, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", file, err.Error())
+	}
+
+	s := &Schema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", file, err.Error())
+	}
+
+	return s, nil
+}
+
+// SchemaFiles maps each kind of seed data to the name of its schema file.
+var SchemaFiles = map[string]string{
+	datastore.EntityUsers:   "user-schema.json",
+	datastore.EntityCatalog: "catalog-schema.json",
+	datastore.EntityOrders:  "order-schema.json",
+	datastore.EntityCarts:   "cart-schema.json",
+}
+
+// UniqueColumns are the Dataset Columns that use every row of their dataset at most once, by the
+// entity they belong to, so that every user has only one cart.
+var UniqueColumns = map[string][]string{
+	datastore.EntityCarts: {"userid"},
+}
+
+// SeedData generates the rows of all entities, using the schema files in dir. The entities are
+// generated in the order of datastore.Entities, so orders and carts can refer to users and products.
+// The number of rows of an entity is taken from rows, or from the schema when it isn't set.
+func SeedData(g *Generator, dir string, rows map[string]int) (*datastore.SeedData, error) {
+	data := &datastore.SeedData{}
+
+	for e, cols := range UniqueColumns {
+		for _, col := range cols {
+			g.WithUnique(e, col)
+		}
+	}
+
+	for _, e := range datastore.Entities {
+		s, err := LoadSchema(filepath.Join(dir, SchemaFiles[e]))
+		if err != nil {
+			return nil, err
+		}
+
+		n, ok := rows[e]
+		if !ok {
+			n = s.NumRows
+		}
+
+		out, err := g.Generate(s, e, n)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		switch e {
+		case datastore.EntityUsers:
+			v = &data.Users
+		case datastore.EntityCatalog:
+			v = &data.Catalog
+		case datastore.EntityOrders:
+			v = &data.Orders
+		case datastore.EntityCarts:
+			v = &data.Carts
+		}
+
+		// Convert the rows into the types of the shop, any field of the schema that doesn't match
+		// those types is an error
+		b, err := json.Marshal(out)
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return nil, fmt.Errorf("the rows of %s don't match the data of the shop: %s", SchemaFiles[e], err.Error())
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package generate
+
+// The lists below are the values the Generator picks from. They are small compared to those of
+// Mockaroo, but large enough to create realistic looking test data.
+
+var firstNames = []string{
+	"Aaron", "Abbey", "Adela", "Alden", "Alvina", "Ambrose", "Annabal", "Ardis", "Barnabe", "Bebe",
+	"Benson", "Brana", "Bryn", "Carlin", "Channa", "Cherida", "Clem", "Corny", "Dael", "Darbie",
+	"Delia", "Dov", "Elnora", "Emmit", "Evvie", "Fidel", "Florie", "Gasper", "Gwenny", "Haskell",
+	"Hedda", "Ingar", "Jaime", "Jeanna", "Kassi", "Kenn", "Lanette", "Lind", "Marrilee", "Moe",
+	"Nerte", "Odell", "Pavia", "Quent", "Rori", "Saree", "Teddy", "Ulick", "Vinny", "Wynn",
+}
+
+var lastNames = []string{
+	"Abrahamoff", "Bartrap", "Borwick", "Brisard", "Cattemull", "Crofthwaite", "Dabnot", "Eddis",
+	"Fairbrother", "Gookes", "Hallwood", "Huckabe", "Iacovozzo", "Jirka", "Kiddey", "Lamyman",
+	"MacCorkell", "Mattiello", "Nano", "Oxtoby", "Pedroni", "Quinnelly", "Rathborne", "Scoggans",
+	"Sweet", "Tomsett", "Uphill", "Vasyatkin", "Wyldbore", "Yurkov",
+}
+
+var domains = []string{
+	"businessinsider.com", "example.com", "example.net", "example.org", "ox.ac.uk", "sitemeter.com",
+	"acmeserverless.io", "mail.test",
+}
+
+var streets = []string{
+	"Anzinger", "Bluejay", "Clarendon", "Dayton", "Esker", "Fordem", "Golf View", "Hanson", "Upham",
+	"Kinsman", "Lakewood", "Merrick", "Nobel", "Oak Valley", "Pankratz", "Ridge Oak", "Swallow",
+	"Talisman", "Vernon", "Waywood",
+}
+
+var cities = []string{
+	"Austin", "Boise", "Charlotte", "Des Moines", "El Paso", "Fresno", "Houston", "Indianapolis",
+	"Jacksonville", "Kansas City", "Manassas", "Omaha", "Portland", "Sacramento", "Tampa", "Wichita",
+}
+
+var states = []string{
+	"AL", "AZ", "CA", "CO", "FL", "GA", "IA", "ID", "IL", "IN", "KS", "MN", "NC", "NE", "NY", "OR",
+	"TX", "VA", "WA", "WI",
+}
+
+var countries = []string{
+	"Brazil", "Canada", "China", "France", "Germany", "Indonesia", "Japan", "Netherlands", "Philippines",
+	"Poland", "Portugal", "Russia", "Sweden", "United States",
+}
+
+var products = []string{
+	"7up Diet, 355 Ml", "Allspice - Jamaican", "Apple - Delicious, Golden", "Beef - Outside, Round",
+	"Bread - Multigrain", "Cheese - Brie", "Coffee - Espresso", "Flour - Whole Wheat",
+	"Ginger - Crystalized", "Juice - Orange", "Lettuce - Romaine", "Oats - Rolled", "Pasta - Penne",
+	"Rice - Basmati", "Salmon - Fillet", "Tea - Green", "Tofu - Firm", "Walnuts - Halves",
+	"Water - Sparkling", "Wine - Tio Pepe Sherry Fino", "Yogurt - Plain",
+}
+
+var sloganVerbs = []string{
+	"aggregate", "deliver", "embrace", "empower", "engage", "envisioneer", "incubate", "innovate",
+	"leverage", "matrix", "orchestrate", "reinvent", "scale", "streamline", "transform",
+}
+
+var sloganAdjectives = []string{
+	"B2C", "back-end", "best-of-breed", "cross-platform", "dynamic", "front-end", "holistic",
+	"intuitive", "proactive", "real-time", "robust", "seamless", "sticky", "turn-key", "viral",
+}
+
+var sloganNouns = []string{
+	"bandwidth", "channels", "communities", "deliverables", "e-markets", "e-tailers", "experiences",
+	"functionalities", "markets", "metrics", "networks", "platforms", "solutions", "synergies",
+}
+
+var movieGenres = []string{
+	"Action", "Adventure", "Animation", "Comedy", "Crime", "Documentary", "Drama", "Fantasy",
+	"Horror", "Musical", "Mystery", "Romance", "Sci-Fi", "Thriller", "War", "Western",
+}
+
+var lorem = []string{
+	"ac", "aliquam", "aliquet", "amet", "ante", "at", "augue", "blandit", "consectetuer", "convallis",
+	"cras", "cubilia", "curae", "dictumst", "donec", "duis", "eget", "eleifend", "erat", "faucibus",
+	"habitasse", "hac", "in", "integer", "ipsum", "lacinia", "lacus", "lectus", "lobortis", "lorem",
+	"luctus", "magna", "mi", "nec", "neque", "nisi", "non", "nunc", "odio", "orci", "pede",
+	"pharetra", "platea", "posuere", "potenti", "praesent", "primis", "quam", "rutrum", "sapien",
+	"sed", "sit", "sollicitudin", "suspendisse", "tincidunt", "tortor", "ultrices", "vel",
+	"vestibulum", "vitae",
+}
+
+var imageFormats = []string{"bmp", "jpg", "png"}
+
+var imageColors = []string{"5fa2dd", "cc0000", "dddddd", "ff4444"}
+
+// cardFormat is the format of the card numbers of a card type.
+type cardFormat struct {
+	prefixes []string
+	length   int
+}
+
+var cardTypes = []string{"americanexpress", "diners-club-carte-blanche", "jcb", "mastercard", "visa"}
+
+var cardFormats = map[string]cardFormat{
+	"americanexpress":           {prefixes: []string{"34", "37"}, length: 15},
+	"diners-club-carte-blanche": {prefixes: []string{"300", "301", "302", "303", "304", "305"}, length: 14},
+	"jcb":                       {prefixes: []string{"3528", "3545", "3566", "3589"}, length: 16},
+	"mastercard":                {prefixes: []string{"51", "52", "53", "54", "55"}, length: 16},
+	"visa":                      {prefixes: []string{"4"}, length: 16},
+}
@@ -0,0 +1,276 @@
+// Package target connects the datastore commands to the datastore selected with the target flag.
+package target
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+	_ "github.com/lib/pq"           // registers the postgres driver
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+	"github.com/retgits/acme-serverless/datastore"
+)
+
+const (
+	// DynamoDB is the Amazon DynamoDB table used by the AWS deployment.
+	DynamoDB = "dynamodb"
+
+	// MongoDB is the MongoDB database used by the Google Cloud Run deployment.
+	MongoDB = "mongodb"
+
+	// SQL is a SQLite or PostgreSQL database.
+	SQL = "sql"
+
+	// Memory is an in-memory datastore that is discarded when the command exits.
+	Memory = "memory"
+)
+
+// Config contains the flags that select and connect to a datastore.
+type Config struct {
+	// Target is one of DynamoDB, MongoDB, SQL, or Memory.
+	Target string
+
+	// Region is the AWS region of the DynamoDB table.
+	Region string
+
+	// Table is the name of the DynamoDB table.
+	Table string
+
+	// Endpoint is an optional endpoint URL for DynamoDB, like DynamoDB Local.
+	Endpoint string
+
+	// KeyIDIndex is the global secondary index of the DynamoDB table to look up records by KeyID.
+	KeyIDIndex string
+
+	// Mongo contains the settings to connect to MongoDB. Settings that aren't set with a flag are
+	// read from the MONGO_ environment variables.
+	Mongo datastore.MongoConfig
+
+	// Native stores the payload of MongoDB records as BSON documents instead of JSON strings.
+	Native bool
+
+	// Dialect is the SQL dialect, either sqlite3 or postgres.
+	Dialect string
+
+	// DSN is the data source name of the SQL database, like a filename for SQLite.
+	DSN string
+
+	// KeyFile is a file with the master key to encrypt sensitive fields of records with, for
+	// development.
+	KeyFile string
+
+	// KMSKeyID is the AWS KMS key to encrypt sensitive fields of records with.
+	KMSKeyID string
+
+	prefix string
+}
+
+// RegisterFlags registers the flags of the Config with the FlagSet.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	c.RegisterPrefixedFlags(fs, "")
+}
+
+// RegisterPrefixedFlags registers the flags of the Config with the FlagSet, with the prefix in front
+// of each flag name, like source-target. This allows a command to connect to more than one datastore.
+func (c *Config) RegisterPrefixedFlags(fs *flag.FlagSet, prefix string) {
+	c.prefix = prefix
+	fs.StringVar(&c.Target, prefix+"target", "", "The datastore to use: dynamodb, mongodb, sql, or memory (required)")
+	fs.StringVar(&c.Region, prefix+"region", "", "The region to send requests to (required for dynamodb)")
+	fs.StringVar(&c.Table, prefix+"table", "", "The Amazon DynamoDB table to use (required for dynamodb)")
+	fs.StringVar(&c.Endpoint, prefix+"endpoint", "", "An optional endpoint URL (optional, hostname only or fully qualified URI)")
+	fs.StringVar(&c.KeyIDIndex, prefix+"keyid-index", datastore.KeyIDIndex, "The index to look up records by KeyID, empty for tables without that index (optional)")
+	fs.StringVar(&c.Mongo.URI, prefix+"uri", "", "The connection string of MongoDB, like mongodb://localhost:27017 (required for mongodb, unless hostname is set)")
+	fs.StringVar(&c.Mongo.Username, prefix+"username", "", "The username to connect to MongoDB")
+	fs.StringVar(&c.Mongo.Password, prefix+"password", "", "The password to connect to MongoDB")
+	fs.StringVar(&c.Mongo.PasswordFile, prefix+"password-file", "", "A file that contains the password to connect to MongoDB (optional)")
+	fs.StringVar(&c.Mongo.AuthSource, prefix+"auth-source", "", "The database that contains the MongoDB user (optional, defaults to admin)")
+	fs.StringVar(&c.Mongo.Hostname, prefix+"hostname", "", "The hostname of the MongoDB server (required for mongodb, unless uri is set)")
+	fs.StringVar(&c.Mongo.Port, prefix+"port", "", "The port number of the MongoDB server, without a port the hostname is a mongodb+srv record (optional)")
+	fs.StringVar(&c.Mongo.Database, prefix+"database", "", "The MongoDB database to use (optional, defaults to the database in the uri or "+datastore.DefaultMongoDatabase+")")
+	fs.BoolVar(&c.Mongo.TLS, prefix+"tls", false, "Connect to MongoDB using TLS (optional)")
+	fs.StringVar(&c.Mongo.CAFile, prefix+"ca-file", "", "A PEM file with the certificate authorities to trust for MongoDB, enables TLS (optional)")
+	fs.BoolVar(&c.Mongo.TLSInsecure, prefix+"tls-insecure", false, "Don't verify the certificate of the MongoDB server (optional)")
+	fs.DurationVar(&c.Mongo.Timeout, prefix+"timeout", datastore.DefaultMongoTimeout, "The time to connect to MongoDB (optional)")
+	fs.BoolVar(&c.Native, prefix+"native", false, "Store MongoDB records as native BSON documents and create their indexes (optional)")
+	fs.StringVar(&c.Dialect, prefix+"dialect", string(datastore.SQLite), "The SQL dialect: sqlite3 or postgres")
+	fs.StringVar(&c.DSN, prefix+"dsn", "acmeserverless.db", "The data source name of the SQL database, like the filename for sqlite3")
+	fs.StringVar(&c.KeyFile, prefix+"key-file", "", "A file with the master key to encrypt sensitive fields with, for development (optional)")
+	fs.StringVar(&c.KMSKeyID, prefix+"kms-key-id", "", "The AWS KMS key to encrypt sensitive fields with, like alias/acmeserverless (optional)")
+}
+
+// flag returns the name of the flag as it was registered.
+func (c Config) flag(name string) string {
+	return c.prefix + name
+}
+
+// Target is an open connection to a datastore.
+type Target struct {
+	// Repositories give access to the data in the datastore.
+	Repositories *datastore.Repositories
+
+	// Store gives access to the single table layout of the datastore. It is nil for SQL
+	// datastores, which don't use that layout.
+	Store datastore.Store
+
+	closer func() error
+}
+
+// Close closes the connection to the datastore.
+func (t *Target) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+	return t.closer()
+}
+
+// Open connects to the datastore selected in the Config. When a key is set, the sensitive fields of
+// records are encrypted before they are written and decrypted when they are read.
+func Open(ctx context.Context, c Config) (*Target, error) {
+	keys, err := keyProvider(c)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := open(ctx, c)
+	if err != nil || keys == nil {
+		return t, err
+	}
+
+	if t.Store == nil {
+		t.Close()
+		return nil, fmt.Errorf("the %s target doesn't support encryption", c.Target)
+	}
+
+	s := datastore.NewEncryptedStore(t.Store, keys)
+	t.Store = s
+	t.Repositories = datastore.NewRepositories(s)
+	return t, nil
+}
+
+// keyProvider creates the KeyProvider selected with the key-file or kms-key-id flag, or returns nil
+// when neither is set.
+func keyProvider(c Config) (datastore.KeyProvider, error) {
+	switch {
+	case len(c.KeyFile) > 0 && len(c.KMSKeyID) > 0:
+		return nil, fmt.Errorf("only one of the '%s' and '%s' flags can be set", c.flag("key-file"), c.flag("kms-key-id"))
+	case len(c.KeyFile) > 0:
+		return datastore.LoadKeyFile(c.KeyFile)
+	case len(c.KMSKeyID) > 0:
+		awsSession, err := session.NewSession(&aws.Config{
+			Region: aws.String(c.Region),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return datastore.NewKMSKeyProvider(kms.New(awsSession), c.KMSKeyID), nil
+	default:
+		return nil, nil
+	}
+}
+
+// open connects to the datastore selected in the Config.
+func open(ctx context.Context, c Config) (*Target, error) {
+	switch c.Target {
+	case DynamoDB:
+		return openDynamoDB(c)
+	case MongoDB:
+		return openMongoDB(ctx, c)
+	case SQL:
+		s, err := datastore.OpenSQL(ctx, datastore.Dialect(c.Dialect), c.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("error opening %s database: %s", c.Dialect, err.Error())
+		}
+		return &Target{Repositories: s.Repositories(), closer: s.Close}, nil
+	case Memory:
+		s := datastore.NewMemoryStore()
+		return &Target{Repositories: datastore.NewRepositories(s), Store: s}, nil
+	case "":
+		return nil, fmt.Errorf("the '%s' flag must be set", c.flag("target"))
+	default:
+		return nil, fmt.Errorf("unknown target %q, must be one of dynamodb, mongodb, sql, or memory", c.Target)
+	}
+}
+
+// openDynamoDB creates the connection to DynamoDB. If the endpoint is set, the connection is made to
+// that URL instead of relying on the AWS SDK to provide the URL.
+func openDynamoDB(c Config) (*Target, error) {
+	if len(c.Region) < 1 {
+		return nil, fmt.Errorf("the '%s' flag must be set", c.flag("region"))
+	}
+
+	if len(c.Table) < 1 {
+		return nil, fmt.Errorf("the '%s' flag must be set", c.flag("table"))
+	}
+
+	awsSession, err := session.NewSession(&aws.Config{
+		Region: aws.String(c.Region),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Endpoint) > 0 {
+		awsSession.Config.Endpoint = aws.String(c.Endpoint)
+	}
+
+	s := datastore.NewDynamoDBStore(dynamodb.New(awsSession), c.Table).WithKeyIDIndex(c.KeyIDIndex)
+	return &Target{Repositories: datastore.NewRepositories(s), Store: s}, nil
+}
+
+// openMongoDB creates the connection to MongoDB. The connection is checked with a ping, so wrong
+// settings are reported before any data is read or written.
+func openMongoDB(ctx context.Context, c Config) (*Target, error) {
+	mc := withEnv(c.Mongo, datastore.MongoConfigFromEnv())
+	if len(mc.URI) < 1 && len(mc.Hostname) < 1 {
+		return nil, fmt.Errorf("either the '%s' or the '%s' flag must be set", c.flag("uri"), c.flag("hostname"))
+	}
+
+	client, err := datastore.ConnectMongo(ctx, mc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := datastore.NewMongoStore(client.Database(mc.DatabaseName())).WithNativeDocuments(c.Native)
+	if c.Native {
+		if err := s.EnsureIndexes(ctx); err != nil {
+			client.Disconnect(context.Background())
+			return nil, err
+		}
+	}
+	return &Target{
+		Repositories: datastore.NewRepositories(s),
+		Store:        s,
+		closer: func() error {
+			return client.Disconnect(context.Background())
+		},
+	}, nil
+}
+
+// withEnv fills the settings that weren't set with a flag from the environment. The server and the
+// credentials are taken from the environment as a whole, so flags and environment variables aren't
+// mixed into a connection to the wrong server.
+func withEnv(c datastore.MongoConfig, env datastore.MongoConfig) datastore.MongoConfig {
+	if len(c.URI) == 0 && len(c.Hostname) == 0 {
+		c.URI, c.Hostname, c.Port = env.URI, env.Hostname, env.Port
+	}
+	if len(c.Username) == 0 {
+		c.Username, c.Password, c.PasswordFile, c.AuthSource = env.Username, env.Password, env.PasswordFile, env.AuthSource
+	}
+	if len(c.Database) == 0 {
+		c.Database = env.Database
+	}
+	if !c.TLS {
+		c.TLS = env.TLS
+	}
+	if len(c.CAFile) == 0 {
+		c.CAFile = env.CAFile
+	}
+	if !c.TLSInsecure {
+		c.TLSInsecure = env.TLSInsecure
+	}
+	return c
+}
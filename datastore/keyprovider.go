@@ -0,0 +1,168 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// DataKeySize is the size, in bytes, of the data keys and of the master key of a LocalKeyProvider.
+const DataKeySize = 32
+
+// KeyProvider creates and decrypts the data keys of envelope encryption. Every record is encrypted
+// with its own data key, and only the data key encrypted with the master key of the KeyProvider is
+// stored with the record.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key of DataKeySize bytes, and that data key encrypted with
+	// the master key.
+	GenerateDataKey(ctx context.Context) (key []byte, encrypted []byte, err error)
+
+	// DecryptDataKey returns the data key of an encrypted data key.
+	DecryptDataKey(ctx context.Context, encrypted []byte) ([]byte, error)
+}
+
+// LocalKeyProvider is a KeyProvider with a master key that is kept in a file. It is meant for
+// development, where no key management service is available.
+type LocalKeyProvider struct {
+	aead cipher.AEAD
+}
+
+// NewLocalKeyProvider creates a LocalKeyProvider with a master key of DataKeySize bytes.
+func NewLocalKeyProvider(key []byte) (*LocalKeyProvider, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("datastore: master key must be %d bytes, got %d", DataKeySize, len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalKeyProvider{aead: aead}, nil
+}
+
+// LoadKeyFile creates a LocalKeyProvider with the master key in a file, which contains either the
+// base64 encoding of the key or the key itself. A key can be created with
+//
+//	head -c 32 /dev/urandom | base64 > acmeserverless.key
+func LoadKeyFile(filename string) (*LocalKeyProvider, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %s", err.Error())
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil {
+		data = key
+	}
+
+	return NewLocalKeyProvider(data)
+}
+
+// GenerateDataKey returns a new random data key, and the data key encrypted with the master key.
+func (l *LocalKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+
+	encrypted, err := seal(l.aead, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, encrypted, nil
+}
+
+// DecryptDataKey returns the data key of an encrypted data key.
+func (l *LocalKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte) ([]byte, error) {
+	return open(l.aead, encrypted, nil)
+}
+
+// KMSAPI contains the operations of AWS KMS that a KMSKeyProvider uses. It is implemented by
+// *kms.KMS, and can be implemented by other key management services with the same semantics.
+type KMSAPI interface {
+	GenerateDataKeyWithContext(ctx aws.Context, input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error)
+	DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error)
+}
+
+// KMSKeyProvider is a KeyProvider that uses a customer master key in AWS KMS, so the master key
+// never leaves KMS.
+type KMSKeyProvider struct {
+	svc   KMSAPI
+	keyID string
+}
+
+// NewKMSKeyProvider creates a KMSKeyProvider for the customer master key with the given ID, ARN, or
+// alias, like alias/acmeserverless.
+func NewKMSKeyProvider(svc KMSAPI, keyID string) *KMSKeyProvider {
+	return &KMSKeyProvider{
+		svc:   svc,
+		keyID: keyID,
+	}
+}
+
+// GenerateDataKey asks KMS for a new data key.
+func (k *KMSKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := k.svc.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error generating data key: %s", err.Error())
+	}
+
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// DecryptDataKey asks KMS to decrypt a data key. The encrypted data key contains the master key it
+// was encrypted with, so records encrypted with an older master key can still be read.
+func (k *KMSKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte) ([]byte, error) {
+	out, err := k.svc.DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob: encrypted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %s", err.Error())
+	}
+
+	return out.Plaintext, nil
+}
+
+// newAEAD creates an AES-GCM cipher with the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext, and returns the random nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts the output of seal.
+func open(aead cipher.AEAD, sealed []byte, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("datastore: ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
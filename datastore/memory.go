@@ -0,0 +1,183 @@
+package datastore
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Snapshot is a copy of all records in a MemoryStore, ordered by partition and sort key.
+type Snapshot []Record
+
+// MemoryStore is a Store that keeps all records in memory. It supports the same access patterns as
+// the DynamoDB and MongoDB stores, so code that uses a Store can be tested without a database. It is
+// safe for concurrent use. Records are kept after their TTL passed, until a CartSweeper removes them.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	partitions map[string]map[string]Record
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		partitions: make(map[string]map[string]Record),
+	}
+}
+
+// Get returns the record with the given partition and sort key, or ErrNotFound.
+func (m *MemoryStore) Get(ctx context.Context, pk string, sk string) (Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.partitions[pk][sk]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+
+	return rec, nil
+}
+
+// Put creates or replaces a record.
+func (m *MemoryStore) Put(ctx context.Context, rec Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.partitions[rec.PK]
+	if !ok {
+		p = make(map[string]Record)
+		m.partitions[rec.PK] = p
+	}
+	p[rec.SK] = rec
+
+	return nil
+}
+
+// PutIfVersion writes the record if the stored record has the Version of rec, and returns the
+// record with its new Version.
+func (m *MemoryStore) PutIfVersion(ctx context.Context, rec Record) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.partitions[rec.PK]
+	if !ok {
+		p = make(map[string]Record)
+		m.partitions[rec.PK] = p
+	}
+
+	if p[rec.SK].Version != rec.Version {
+		return Record{}, &ConflictError{PK: rec.PK, SK: rec.SK, Version: rec.Version}
+	}
+
+	rec.Version++
+	p[rec.SK] = rec
+
+	return rec, nil
+}
+
+// DeleteIfVersion removes the record if it has the given version.
+func (m *MemoryStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.partitions[pk][sk]
+	if !ok || rec.Version != version {
+		return &ConflictError{PK: pk, SK: sk, Version: version}
+	}
+
+	delete(m.partitions[pk], sk)
+	return nil
+}
+
+// Delete removes a record.
+func (m *MemoryStore) Delete(ctx context.Context, pk string, sk string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.partitions[pk], sk)
+	return nil
+}
+
+// List returns all records in a partition, ordered by sort key.
+func (m *MemoryStore) List(ctx context.Context, pk string) ([]Record, error) {
+	return m.filter(pk, func(Record) bool { return true }), nil
+}
+
+// QueryKeyID returns all records in a partition with the given KeyID, ordered by sort key.
+func (m *MemoryStore) QueryKeyID(ctx context.Context, pk string, keyID string) ([]Record, error) {
+	return m.filter(pk, func(rec Record) bool { return rec.KeyID == keyID }), nil
+}
+
+// Snapshot returns a copy of all records in the store.
+func (m *MemoryStore) Snapshot() Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var s Snapshot
+	for _, p := range m.partitions {
+		for _, rec := range p {
+			s = append(s, rec)
+		}
+	}
+
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].PK != s[j].PK {
+			return s[i].PK < s[j].PK
+		}
+		return s[i].SK < s[j].SK
+	})
+
+	return s
+}
+
+// Restore replaces all records in the store with the records in the snapshot.
+func (m *MemoryStore) Restore(s Snapshot) {
+	partitions := make(map[string]map[string]Record)
+	for _, rec := range s {
+		p, ok := partitions[rec.PK]
+		if !ok {
+			p = make(map[string]Record)
+			partitions[rec.PK] = p
+		}
+		p[rec.SK] = rec
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.partitions = partitions
+}
+
+// filter returns the records in a partition for which keep returns true, ordered by sort key.
+func (m *MemoryStore) filter(pk string, keep func(Record) bool) []Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	recs := make([]Record, 0, len(m.partitions[pk]))
+	for _, rec := range m.partitions[pk] {
+		if keep(rec) {
+			recs = append(recs, rec)
+		}
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].SK < recs[j].SK
+	})
+
+	return recs
+}
+
+// PutBatch writes all records at once.
+func (m *MemoryStore) PutBatch(ctx context.Context, recs []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, rec := range recs {
+		p, ok := m.partitions[rec.PK]
+		if !ok {
+			p = make(map[string]Record)
+			m.partitions[rec.PK] = p
+		}
+		p[rec.SK] = rec
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package datastore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// sortKeys returns the sort keys of the records.
+func sortKeys(recs []Record) []string {
+	sks := []string{}
+	for _, rec := range recs {
+		sks = append(sks, rec.SK)
+	}
+	return sks
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	recs := []Record{
+		{PK: PartitionOrder, SK: "order-3", KeyID: "user-1", Payload: `{"_id":"order-3"}`},
+		{PK: PartitionOrder, SK: "order-1", KeyID: "user-1", Payload: `{"_id":"order-1"}`},
+		{PK: PartitionOrder, SK: "order-2", KeyID: "user-2", Payload: `{"_id":"order-2"}`},
+		{PK: PartitionUser, SK: "user-1", KeyID: "jdoe", Payload: `{"id":"user-1"}`},
+	}
+	for _, rec := range recs {
+		if err := s.Put(ctx, rec); err != nil {
+			t.Fatalf("error storing record: %s", err.Error())
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query func() ([]Record, error)
+		want  []string
+	}{
+		{"list a partition", func() ([]Record, error) { return s.List(ctx, PartitionOrder) }, []string{"order-1", "order-2", "order-3"}},
+		{"list an empty partition", func() ([]Record, error) { return s.List(ctx, PartitionCart) }, []string{}},
+		{"query a KeyID", func() ([]Record, error) { return s.QueryKeyID(ctx, PartitionOrder, "user-1") }, []string{"order-1", "order-3"}},
+		{"query a KeyID of another partition", func() ([]Record, error) { return s.QueryKeyID(ctx, PartitionOrder, "jdoe") }, []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.query()
+		if err != nil {
+			t.Errorf("%s: error reading records: %s", tt.name, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(sortKeys(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, sortKeys(got), tt.want)
+		}
+	}
+
+	rec, err := s.Get(ctx, PartitionUser, "user-1")
+	if err != nil || !reflect.DeepEqual(rec, recs[3]) {
+		t.Fatalf("record is %+v (error %v), want %+v", rec, err, recs[3])
+	}
+	if err := s.Delete(ctx, PartitionUser, "user-1"); err != nil {
+		t.Fatalf("error removing record: %s", err.Error())
+	}
+	if _, err := s.Get(ctx, PartitionUser, "user-1"); err != ErrNotFound {
+		t.Fatalf("error getting removed record is %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	s.Put(ctx, Record{PK: PartitionUser, SK: "user-2", Payload: `{"id":"user-2"}`})
+	s.Put(ctx, Record{PK: PartitionCart, SK: "user-1", Payload: `[]`})
+	s.Put(ctx, Record{PK: PartitionUser, SK: "user-1", Payload: `{"id":"user-1"}`})
+
+	snap := s.Snapshot()
+	want := []string{PartitionCart + "/user-1", PartitionUser + "/user-1", PartitionUser + "/user-2"}
+	got := []string{}
+	for _, rec := range snap {
+		got = append(got, rec.PK+"/"+rec.SK)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot has %v, want %v ordered by partition and sort key", got, want)
+	}
+
+	// Changes after the snapshot are undone by Restore
+	s.Put(ctx, Record{PK: PartitionUser, SK: "user-1", Payload: `{"id":"user-1","username":"changed"}`})
+	s.Put(ctx, Record{PK: PartitionOrder, SK: "order-1", Payload: `{}`})
+	s.Delete(ctx, PartitionUser, "user-2")
+
+	s.Restore(snap)
+	if restored := s.Snapshot(); !reflect.DeepEqual(restored, snap) {
+		t.Fatalf("store has %+v after restoring, want %+v", restored, snap)
+	}
+
+	// Changing the store doesn't change the snapshot
+	s.Put(ctx, Record{PK: PartitionUser, SK: "user-3", Payload: `{}`})
+	if len(snap) != 3 {
+		t.Fatalf("snapshot has %d records after the store changed, want 3", len(snap))
+	}
+}
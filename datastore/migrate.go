@@ -0,0 +1,316 @@
+package datastore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Partitions contains the partitions of the shop.
+var Partitions = []string{PartitionUser, PartitionProduct, PartitionOrder, PartitionCart}
+
+// Checksum returns the SHA-256 checksum of the keys, KeyID, and Payload of the record. The Version
+// isn't part of the checksum, as it doesn't change the data.
+func Checksum(rec Record) string {
+	h := sha256.New()
+	for _, f := range []string{rec.PK, rec.SK, rec.KeyID, rec.Payload} {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PartitionProgress is the progress of the migration of a single partition.
+type PartitionProgress struct {
+	// LastSK is the sort key of the last record that was migrated. Records are migrated in the
+	// order of their sort keys, so a resumed migration continues after this record.
+	LastSK string `json:"lastSK,omitempty"`
+
+	// Count is the number of records that were migrated.
+	Count int `json:"count"`
+
+	// Checksum is the XOR of the checksums of all migrated records, which doesn't depend on the
+	// order of the records.
+	Checksum string `json:"checksum,omitempty"`
+
+	// Done is true when all records of the partition were migrated.
+	Done bool `json:"done"`
+}
+
+// Checkpoint contains the progress of a migration, so it can be resumed after it was interrupted.
+type Checkpoint struct {
+	// Partitions maps each partition to its progress.
+	Partitions map[string]*PartitionProgress `json:"partitions"`
+}
+
+// NewCheckpoint creates a Checkpoint of a migration that hasn't started yet.
+func NewCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Partitions: make(map[string]*PartitionProgress),
+	}
+}
+
+// LoadCheckpoint reads the checkpoint from the file. If the file doesn't exist, it returns a new
+// Checkpoint.
+func LoadCheckpoint(file string) (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return NewCheckpoint(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %s", file, err.Error())
+	}
+
+	c := NewCheckpoint()
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint %s: %s", file, err.Error())
+	}
+
+	return c, nil
+}
+
+// Save writes the checkpoint to the file. The checkpoint is written to a temporary file first, so
+// an interrupted Save doesn't corrupt an existing checkpoint.
+func (c *Checkpoint) Save(file string) error {
+	b, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(file+".tmp", b, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint %s: %s", file, err.Error())
+	}
+
+	return os.Rename(file+".tmp", file)
+}
+
+// progress returns the progress of the partition, creating it when needed.
+func (c *Checkpoint) progress(pk string) *PartitionProgress {
+	p, ok := c.Partitions[pk]
+	if !ok {
+		p = &PartitionProgress{}
+		c.Partitions[pk] = p
+	}
+	return p
+}
+
+// MigrateOptions configure how Migrate copies the records.
+type MigrateOptions struct {
+	// Partitions are the partitions to migrate. Defaults to Partitions.
+	Partitions []string
+
+	// BatchSize is the number of records written before the checkpoint is saved. Defaults to 25.
+	BatchSize int
+
+	// Checkpoint is the progress of an earlier migration to resume. Partitions that are done are
+	// skipped. Defaults to a new Checkpoint.
+	Checkpoint *Checkpoint
+
+	// OnCheckpoint, when set, is called after every batch, for example to save the checkpoint.
+	OnCheckpoint func(c *Checkpoint) error
+}
+
+// Migrate copies all records of the partitions from src to dst. Records are copied in the order of
+// their sort keys and in batches, using PutBatch when dst implements BatchWriter. After every batch
+// the checkpoint is updated, so an interrupted migration can be resumed using that checkpoint.
+// Records are upserted, so migrating the same records twice doesn't duplicate anything.
+func Migrate(ctx context.Context, src Store, dst Store, opts MigrateOptions) (*Checkpoint, error) {
+	if len(opts.Partitions) == 0 {
+		opts.Partitions = Partitions
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 25
+	}
+
+	c := opts.Checkpoint
+	if c == nil {
+		c = NewCheckpoint()
+	}
+
+	for _, pk := range opts.Partitions {
+		p := c.progress(pk)
+		if p.Done {
+			continue
+		}
+
+		recs, err := src.List(ctx, pk)
+		if err != nil {
+			return c, fmt.Errorf("error reading %s: %s", pk, err.Error())
+		}
+
+		sort.Slice(recs, func(i, j int) bool { return recs[i].SK < recs[j].SK })
+
+		// Skip the records that were migrated before the checkpoint was saved
+		start := sort.Search(len(recs), func(i int) bool { return recs[i].SK > p.LastSK })
+		if len(p.LastSK) == 0 {
+			start = 0
+		}
+
+		for start < len(recs) {
+			end := start + opts.BatchSize
+			if end > len(recs) {
+				end = len(recs)
+			}
+
+			batch := recs[start:end]
+			if err := putAll(ctx, dst, batch); err != nil {
+				return c, fmt.Errorf("error writing %s: %s", pk, err.Error())
+			}
+
+			for _, rec := range batch {
+				p.Checksum = xorHex(p.Checksum, Checksum(rec))
+			}
+			p.Count += len(batch)
+			p.LastSK = batch[len(batch)-1].SK
+			start = end
+
+			if err := checkpoint(opts, c); err != nil {
+				return c, err
+			}
+		}
+
+		p.Done = true
+		if err := checkpoint(opts, c); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// checkpoint calls OnCheckpoint, if it is set.
+func checkpoint(opts MigrateOptions, c *Checkpoint) error {
+	if opts.OnCheckpoint == nil {
+		return nil
+	}
+
+	if err := opts.OnCheckpoint(c); err != nil {
+		return fmt.Errorf("error saving checkpoint: %s", err.Error())
+	}
+
+	return nil
+}
+
+// putAll writes all records, using PutBatch when s implements BatchWriter.
+func putAll(ctx context.Context, s Store, recs []Record) error {
+	if bw, ok := s.(BatchWriter); ok {
+		return bw.PutBatch(ctx, recs)
+	}
+
+	for _, rec := range recs {
+		if err := s.Put(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xorHex returns the XOR of two hex encoded checksums. An empty checksum is treated as all zeroes.
+func xorHex(a string, b string) string {
+	x, _ := hex.DecodeString(a)
+	y, _ := hex.DecodeString(b)
+	if len(x) < len(y) {
+		x, y = y, x
+	}
+
+	out := make([]byte, len(x))
+	copy(out, x)
+	for i := range y {
+		out[i] ^= y[i]
+	}
+
+	return hex.EncodeToString(out)
+}
+
+const (
+	// MismatchMissing means the record exists in the source but not in the destination.
+	MismatchMissing = "missing"
+
+	// MismatchChanged means the record in the destination differs from the record in the source.
+	MismatchChanged = "changed"
+
+	// MismatchExtra means the record exists in the destination but not in the source.
+	MismatchExtra = "extra"
+)
+
+// Mismatch is a difference between the source and the destination of a migration.
+type Mismatch struct {
+	// PK is the partition key of the record.
+	PK string
+
+	// SK is the sort key of the record.
+	SK string
+
+	// Kind is one of MismatchMissing, MismatchChanged, or MismatchExtra.
+	Kind string
+}
+
+// Verify compares the checksums of all records of the partitions in src and dst, and returns the
+// records that differ.
+func Verify(ctx context.Context, src Store, dst Store, partitions []string) ([]Mismatch, error) {
+	if len(partitions) == 0 {
+		partitions = Partitions
+	}
+
+	var mismatches []Mismatch
+
+	for _, pk := range partitions {
+		want, err := checksums(ctx, src, pk)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from source: %s", pk, err.Error())
+		}
+
+		got, err := checksums(ctx, dst, pk)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from destination: %s", pk, err.Error())
+		}
+
+		var sks []string
+		for sk := range want {
+			sks = append(sks, sk)
+		}
+		for sk := range got {
+			if _, ok := want[sk]; !ok {
+				sks = append(sks, sk)
+			}
+		}
+		sort.Strings(sks)
+
+		for _, sk := range sks {
+			w, inSrc := want[sk]
+			g, inDst := got[sk]
+			switch {
+			case !inDst:
+				mismatches = append(mismatches, Mismatch{PK: pk, SK: sk, Kind: MismatchMissing})
+			case !inSrc:
+				mismatches = append(mismatches, Mismatch{PK: pk, SK: sk, Kind: MismatchExtra})
+			case w != g:
+				mismatches = append(mismatches, Mismatch{PK: pk, SK: sk, Kind: MismatchChanged})
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// checksums returns the checksums of all records in the partition, by sort key.
+func checksums(ctx context.Context, s Store, pk string) (map[string]string, error) {
+	recs, err := s.List(ctx, pk)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string, len(recs))
+	for _, rec := range recs {
+		sums[rec.SK] = Checksum(rec)
+	}
+
+	return sums, nil
+}
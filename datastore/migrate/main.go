@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/target"
+)
+
+var (
+	partitions     string
+	checkpointFile string
+	batchSize      int
+	verify         bool
+	verifyOnly     bool
+	source         target.Config
+	dest           target.Config
+)
+
+func main() {
+	// Read flags
+	source.RegisterPrefixedFlags(flag.CommandLine, "source-")
+	dest.RegisterPrefixedFlags(flag.CommandLine, "dest-")
+	flag.StringVar(&partitions, "partitions", strings.Join(datastore.Partitions, ","), "A comma separated list of partitions to migrate")
+	flag.StringVar(&checkpointFile, "checkpoint", "migrate-checkpoint.json", "The file to save the progress to, an existing checkpoint is resumed")
+	flag.IntVar(&batchSize, "batch-size", 25, "The number of records written before the checkpoint is saved (defaults to 25)")
+	flag.BoolVar(&verify, "verify", true, "Compare the source and destination after the migration")
+	flag.BoolVar(&verifyOnly, "verify-only", false, "Only compare the source and destination, without migrating")
+	flag.Parse()
+
+	var pks []string
+	for _, pk := range strings.Split(partitions, ",") {
+		if pk = strings.TrimSpace(pk); len(pk) > 0 {
+			pks = append(pks, pk)
+		}
+	}
+
+	ctx := context.Background()
+
+	// Initialize the database connections
+	src, err := open(ctx, source)
+	if err != nil {
+		log.Fatalf("Error opening source: %s", err.Error())
+	}
+	defer src.Close()
+
+	dst, err := open(ctx, dest)
+	if err != nil {
+		src.Close()
+		log.Fatalf("Error opening destination: %s", err.Error())
+	}
+	defer dst.Close()
+
+	if !verifyOnly {
+		cp, err := datastore.LoadCheckpoint(checkpointFile)
+		if err != nil {
+			exit(src, dst, "Error: %s", err.Error())
+		}
+
+		opts := datastore.MigrateOptions{
+			Partitions: pks,
+			BatchSize:  batchSize,
+			Checkpoint: cp,
+			OnCheckpoint: func(c *datastore.Checkpoint) error {
+				return c.Save(checkpointFile)
+			},
+		}
+
+		cp, err = datastore.Migrate(ctx, src.Store, dst.Store, opts)
+		if err != nil {
+			exit(src, dst, "Error: %s (run the same command again to resume from %s)", err.Error(), checkpointFile)
+		}
+
+		for _, pk := range pks {
+			p := cp.Partitions[pk]
+			log.Printf("%s: %d records migrated, checksum %s", pk, p.Count, p.Checksum)
+		}
+	}
+
+	if !verify && !verifyOnly {
+		return
+	}
+
+	mismatches, err := datastore.Verify(ctx, src.Store, dst.Store, pks)
+	if err != nil {
+		exit(src, dst, "Error: %s", err.Error())
+	}
+
+	for _, m := range mismatches {
+		log.Printf("%s %s: %s", m.PK, m.SK, m.Kind)
+	}
+
+	if len(mismatches) > 0 {
+		exit(src, dst, "verification failed: %d records differ", len(mismatches))
+	}
+
+	log.Printf("verification passed, all records are the same in %s and %s", source.Target, dest.Target)
+
+	// The migration is complete, so a next run starts over
+	if !verifyOnly {
+		os.Remove(checkpointFile)
+	}
+}
+
+// open connects to a datastore that uses the single table layout.
+func open(ctx context.Context, c target.Config) (*target.Target, error) {
+	t, err := target.Open(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Store == nil {
+		t.Close()
+		return nil, fmt.Errorf("the %s target doesn't use the single table layout and can't be migrated", c.Target)
+	}
+
+	return t, nil
+}
+
+// exit closes the connections and stops the app with an error.
+func exit(src *target.Target, dst *target.Target, format string, v ...interface{}) {
+	src.Close()
+	dst.Close()
+	log.Fatalf(format, v...)
+}
@@ -0,0 +1,212 @@
+package datastore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+const (
+	// DefaultMongoDatabase is the database used when neither the config nor the URI selects one.
+	DefaultMongoDatabase = "acmeserverless"
+
+	// DefaultMongoTimeout is the time to connect to MongoDB and ping it.
+	DefaultMongoTimeout = 10 * time.Second
+)
+
+// MongoConfig contains the settings to connect to MongoDB. The server is either selected by a URI,
+// like mongodb://localhost:27017 or mongodb+srv://cluster.gcp.mongodb.net, or by a Hostname and an
+// optional Port. Without a Port the hostname is looked up as a mongodb+srv record, which is what
+// MongoDB Atlas uses.
+type MongoConfig struct {
+	// URI is the connection string. When it is set, Hostname and Port are ignored.
+	URI string
+
+	// Hostname is the hostname of the MongoDB server.
+	Hostname string
+
+	// Port is the port number of the MongoDB server.
+	Port string
+
+	// Username is the username to connect with. It overrides the username in the URI.
+	Username string
+
+	// Password is the password to connect with.
+	Password string
+
+	// PasswordFile is a file that contains the password, like a mounted secret. It is used when
+	// Password isn't set.
+	PasswordFile string
+
+	// AuthSource is the database that contains the user, which defaults to admin.
+	AuthSource string
+
+	// Database is the database to use. It defaults to the database in the URI, or
+	// DefaultMongoDatabase.
+	Database string
+
+	// TLS enables TLS, which is always enabled for mongodb+srv URIs.
+	TLS bool
+
+	// CAFile is a PEM file with the certificates of the authorities that are trusted to sign the
+	// certificate of the server. It enables TLS.
+	CAFile string
+
+	// TLSInsecure disables the verification of the certificate of the server.
+	TLSInsecure bool
+
+	// Timeout is the time to connect to MongoDB and ping it. Defaults to DefaultMongoTimeout.
+	Timeout time.Duration
+}
+
+// MongoConfigFromEnv creates a MongoConfig from the environment variables MONGO_URI,
+// MONGO_HOSTNAME, MONGO_PORT, MONGO_USERNAME, MONGO_PASSWORD, MONGO_PASSWORD_FILE,
+// MONGO_AUTH_SOURCE, MONGO_DATABASE, MONGO_TLS, MONGO_CA_FILE, and MONGO_TLS_INSECURE.
+func MongoConfigFromEnv() MongoConfig {
+	tlsEnabled, _ := strconv.ParseBool(os.Getenv("MONGO_TLS"))
+	insecure, _ := strconv.ParseBool(os.Getenv("MONGO_TLS_INSECURE"))
+
+	return MongoConfig{
+		URI:          os.Getenv("MONGO_URI"),
+		Hostname:     os.Getenv("MONGO_HOSTNAME"),
+		Port:         os.Getenv("MONGO_PORT"),
+		Username:     os.Getenv("MONGO_USERNAME"),
+		Password:     os.Getenv("MONGO_PASSWORD"),
+		PasswordFile: os.Getenv("MONGO_PASSWORD_FILE"),
+		AuthSource:   os.Getenv("MONGO_AUTH_SOURCE"),
+		Database:     os.Getenv("MONGO_DATABASE"),
+		TLS:          tlsEnabled,
+		CAFile:       os.Getenv("MONGO_CA_FILE"),
+		TLSInsecure:  insecure,
+	}
+}
+
+// uri returns the connection string, built from the Hostname and Port when URI isn't set.
+func (c MongoConfig) uri() (string, error) {
+	if len(c.URI) > 0 {
+		if !strings.HasPrefix(c.URI, "mongodb://") && !strings.HasPrefix(c.URI, "mongodb+srv://") {
+			return "", fmt.Errorf("the MongoDB URI must start with mongodb:// or mongodb+srv://")
+		}
+		return c.URI, nil
+	}
+
+	if len(c.Hostname) == 0 {
+		return "", fmt.Errorf("either the URI or the hostname of MongoDB must be set")
+	}
+
+	if len(c.Port) == 0 {
+		return "mongodb+srv://" + c.Hostname, nil
+	}
+
+	return "mongodb://" + c.Hostname + ":" + c.Port, nil
+}
+
+// DatabaseName returns the name of the database to use.
+func (c MongoConfig) DatabaseName() string {
+	if len(c.Database) > 0 {
+		return c.Database
+	}
+
+	if uri, err := c.uri(); err == nil {
+		if cs, err := connstring.Parse(uri); err == nil && len(cs.Database) > 0 {
+			return cs.Database
+		}
+	}
+
+	return DefaultMongoDatabase
+}
+
+// ClientOptions converts the config into the options of the MongoDB client. The credentials are set
+// separately from the URI, so passwords don't need to be escaped.
+func (c MongoConfig) ClientOptions() (*options.ClientOptions, error) {
+	uri, err := c.uri()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Client().ApplyURI(uri)
+
+	password := c.Password
+	if len(password) == 0 && len(c.PasswordFile) > 0 {
+		b, err := ioutil.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading MongoDB password file: %s", err.Error())
+		}
+		password = strings.TrimSpace(string(b))
+	}
+
+	if len(c.Username) > 0 {
+		opts.SetAuth(options.Credential{
+			Username:   c.Username,
+			Password:   password,
+			AuthSource: c.AuthSource,
+		})
+	}
+
+	if c.TLS || len(c.CAFile) > 0 || c.TLSInsecure {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: c.TLSInsecure,
+		}
+
+		if len(c.CAFile) > 0 {
+			pem, err := ioutil.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading MongoDB CA file: %s", err.Error())
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("error reading MongoDB CA file: no certificates found in %s", c.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("error in MongoDB settings: %s", err.Error())
+	}
+
+	return opts, nil
+}
+
+// ConnectMongo connects to MongoDB and pings the primary, so a wrong hostname or password is
+// reported right away rather than on the first query.
+func ConnectMongo(ctx context.Context, c MongoConfig) (*mongo.Client, error) {
+	opts, err := c.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMongoTimeout
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := mongo.Connect(cctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to MongoDB: %s", err.Error())
+	}
+
+	if err := client.Ping(cctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("error connecting to MongoDB: %s", err.Error())
+	}
+
+	return client, nil
+}
@@ -0,0 +1,532 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collections maps the partition keys of the shop to the MongoDB collections they are stored in.
+// Records of other partitions are stored in a collection named after the lowercase partition key.
+var Collections = map[string]string{
+	PartitionUser:    "user",
+	PartitionProduct: "catalog",
+	PartitionOrder:   "order",
+	PartitionCart:    "cart",
+}
+
+// ExpiryGrace is how long MongoDB keeps a record after its TTL passed, which gives the CartSweeper
+// time to emit the events of expired carts before MongoDB removes them.
+const ExpiryGrace = 24 * time.Hour
+
+// MongoIndexes contains the fields that are indexed in the collection of each partition, in
+// addition to PK and SK. Fields inside the Payload can only be indexed in native mode.
+var MongoIndexes = map[string][]string{
+	PartitionUser:    {"KeyID", "Payload.username"},
+	PartitionProduct: {"Payload.tags"},
+	PartitionOrder:   {"KeyID", "Payload.userid"},
+}
+
+// mongoRecord is a Record as it is stored in MongoDB.
+type mongoRecord struct {
+	PK      string     `bson:"PK"`
+	SK      string     `bson:"SK"`
+	KeyID   string     `bson:"KeyID,omitempty"`
+	Payload string     `bson:"Payload"`
+	Version int64      `bson:"Version,omitempty"`
+	TTL     *time.Time `bson:"TTL,omitempty"`
+}
+
+// newMongoRecord converts a Record into a mongoRecord.
+func newMongoRecord(rec Record) mongoRecord {
+	return mongoRecord{
+		PK:      rec.PK,
+		SK:      rec.SK,
+		KeyID:   rec.KeyID,
+		Payload: rec.Payload,
+		Version: rec.Version,
+		TTL:     ttlTime(rec.TTL),
+	}
+}
+
+// mongoDocument is a Record as it is stored in MongoDB in native mode, with the sort key as _id and
+// the Payload as a BSON document or array.
+type mongoDocument struct {
+	ID      string      `bson:"_id"`
+	PK      string      `bson:"PK"`
+	SK      string      `bson:"SK"`
+	KeyID   string      `bson:"KeyID,omitempty"`
+	Payload interface{} `bson:"Payload"`
+	Version int64       `bson:"Version,omitempty"`
+	TTL     *time.Time  `bson:"TTL,omitempty"`
+}
+
+// mongoReader can decode both kinds of documents, as the Payload can be a string or a document.
+type mongoReader struct {
+	PK      string        `bson:"PK"`
+	SK      string        `bson:"SK"`
+	KeyID   string        `bson:"KeyID,omitempty"`
+	Payload bson.RawValue `bson:"Payload"`
+	Version int64         `bson:"Version,omitempty"`
+	TTL     *time.Time    `bson:"TTL,omitempty"`
+}
+
+// ttlTime converts a TTL into the date MongoDB stores, as a TTL index only works on dates.
+func ttlTime(ttl int64) *time.Time {
+	if ttl == 0 {
+		return nil
+	}
+
+	t := time.Unix(ttl, 0).UTC()
+	return &t
+}
+
+// ttlSeconds converts a date stored by MongoDB back into a TTL.
+func ttlSeconds(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+// MongoStore is a Store backed by a MongoDB database with a collection per partition.
+//
+// By default the Payload is stored as a JSON string, like the Payload attribute in DynamoDB. In
+// native mode the Payload is stored as a BSON document, so the fields of users, products, and
+// orders can be queried and indexed. A MongoStore reads both kinds of documents, so a database can
+// switch to native mode without converting the existing documents first.
+type MongoStore struct {
+	dbs    *mongo.Database
+	native bool
+}
+
+// NewMongoStore creates a MongoStore that uses the given database.
+func NewMongoStore(dbs *mongo.Database) *MongoStore {
+	return &MongoStore{
+		dbs: dbs,
+	}
+}
+
+// WithNativeDocuments sets whether records are written in native mode.
+func (m *MongoStore) WithNativeDocuments(native bool) *MongoStore {
+	m.native = native
+	return m
+}
+
+// Collection returns the collection that stores the records of the partition.
+func (m *MongoStore) Collection(pk string) *mongo.Collection {
+	name, ok := Collections[pk]
+	if !ok {
+		name = strings.ToLower(pk)
+	}
+
+	return m.dbs.Collection(name)
+}
+
+// Get returns the record with the given partition and sort key, or ErrNotFound.
+func (m *MongoStore) Get(ctx context.Context, pk string, sk string) (Record, error) {
+	var doc mongoReader
+
+	err := m.Collection(pk).FindOne(ctx, filter(pk, sk)).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	return doc.record()
+}
+
+// Put creates or replaces a record. In native mode a document of the record that was written
+// with the Payload as a string is replaced as well.
+func (m *MongoStore) Put(ctx context.Context, rec Record) error {
+	if !m.native {
+		_, err := m.Collection(rec.PK).ReplaceOne(ctx, filter(rec.PK, rec.SK), newMongoRecord(rec), options.Replace().SetUpsert(true))
+		return err
+	}
+
+	doc, err := nativeDocument(rec)
+	if err != nil {
+		return err
+	}
+
+	coll := m.Collection(rec.PK)
+	if _, err := coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: rec.SK}}, doc, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	_, err = coll.DeleteMany(ctx, legacyFilter(rec.PK, rec.SK))
+	return err
+}
+
+// PutIfVersion writes the record if the stored document has the Version of rec, and returns the
+// record with its new Version. The document is replaced using a filter on the version, and a record
+// that doesn't exist yet is inserted with the sort key as _id, so a concurrent insert of the same
+// record fails with a duplicate key.
+func (m *MongoStore) PutIfVersion(ctx context.Context, rec Record) (Record, error) {
+	next := rec
+	next.Version++
+
+	var doc interface{} = newMongoRecord(next)
+	if m.native {
+		nd, err := nativeDocument(next)
+		if err != nil {
+			return Record{}, err
+		}
+		doc = nd
+	}
+
+	// The replacement has no _id, so documents that were written with a generated ObjectId keep it
+	replacement, err := withoutID(doc)
+	if err != nil {
+		return Record{}, err
+	}
+
+	version := bson.E{Key: "Version", Value: rec.Version}
+	if rec.Version == 0 {
+		version.Value = bson.D{{Key: "$exists", Value: false}}
+	}
+
+	coll := m.Collection(rec.PK)
+	res, err := coll.ReplaceOne(ctx, append(filter(rec.PK, rec.SK), version), replacement)
+	if err != nil {
+		return Record{}, err
+	}
+	if res.MatchedCount == 1 {
+		return next, nil
+	}
+
+	conflict := &ConflictError{PK: rec.PK, SK: rec.SK, Version: rec.Version}
+	if rec.Version > 0 {
+		return Record{}, conflict
+	}
+
+	// The record either doesn't exist, or exists with a version
+	n, err := coll.CountDocuments(ctx, filter(rec.PK, rec.SK))
+	if err != nil {
+		return Record{}, err
+	}
+	if n > 0 {
+		return Record{}, conflict
+	}
+
+	_, err = coll.InsertOne(ctx, append(bson.D{{Key: "_id", Value: rec.SK}}, replacement...))
+	if isDuplicateKey(err) {
+		return Record{}, conflict
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	return next, nil
+}
+
+// DeleteIfVersion removes the record if the stored document has the given version.
+func (m *MongoStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	v := bson.E{Key: "Version", Value: version}
+	if version == 0 {
+		v.Value = bson.D{{Key: "$exists", Value: false}}
+	}
+
+	res, err := m.Collection(pk).DeleteOne(ctx, append(filter(pk, sk), v))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return &ConflictError{PK: pk, SK: sk, Version: version}
+	}
+
+	return nil
+}
+
+// withoutID converts a document into a bson.D without its _id.
+func withoutID(doc interface{}) (bson.D, error) {
+	b, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var d bson.D
+	if err := bson.Unmarshal(b, &d); err != nil {
+		return nil, err
+	}
+
+	out := d[:0]
+	for _, e := range d {
+		if e.Key != "_id" {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+// isDuplicateKey reports whether err is caused by a document with the same _id.
+func isDuplicateKey(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == 11000
+	}
+
+	return false
+}
+
+// Delete removes a record.
+func (m *MongoStore) Delete(ctx context.Context, pk string, sk string) error {
+	_, err := m.Collection(pk).DeleteOne(ctx, filter(pk, sk))
+	return err
+}
+
+// List returns all records in a partition.
+func (m *MongoStore) List(ctx context.Context, pk string) ([]Record, error) {
+	return m.find(ctx, pk, bson.D{{Key: "PK", Value: pk}})
+}
+
+// QueryKeyID returns all records in a partition with the given KeyID.
+func (m *MongoStore) QueryKeyID(ctx context.Context, pk string, keyID string) ([]Record, error) {
+	return m.find(ctx, pk, bson.D{{Key: "PK", Value: pk}, {Key: "KeyID", Value: keyID}})
+}
+
+// find returns all records in the collection of the partition that match the filter.
+func (m *MongoStore) find(ctx context.Context, pk string, f bson.D) ([]Record, error) {
+	cur, err := m.Collection(pk).Find(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var recs []Record
+	for cur.Next(ctx) {
+		var doc mongoReader
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		rec, err := doc.record()
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+
+	return recs, cur.Err()
+}
+
+// filter returns the filter that matches a single record.
+func filter(pk string, sk string) bson.D {
+	return bson.D{{Key: "PK", Value: pk}, {Key: "SK", Value: sk}}
+}
+
+// legacyFilter returns the filter that matches the document of a record that was written with the
+// Payload as a string, which has a generated ObjectId as _id.
+func legacyFilter(pk string, sk string) bson.D {
+	return append(filter(pk, sk), bson.E{Key: "_id", Value: bson.D{{Key: "$type", Value: "objectId"}}})
+}
+
+// deleteLegacy removes the documents of the records that were written with the Payload as a string.
+func (m *MongoStore) deleteLegacy(ctx context.Context, coll *mongo.Collection, pk string, recs []Record) error {
+	sks := make([]string, len(recs))
+	for i, rec := range recs {
+		sks[i] = rec.SK
+	}
+
+	_, err := coll.DeleteMany(ctx, bson.D{
+		{Key: "PK", Value: pk},
+		{Key: "SK", Value: bson.D{{Key: "$in", Value: sks}}},
+		{Key: "_id", Value: bson.D{{Key: "$type", Value: "objectId"}}},
+	})
+	return err
+}
+
+// nativeDocument converts a record into a document with the Payload as a BSON document or array.
+func nativeDocument(rec Record) (mongoDocument, error) {
+	// The payload is wrapped in a document, as it can be a JSON array as well
+	var d bson.D
+	if err := bson.UnmarshalExtJSON([]byte(`{"v":`+rec.Payload+`}`), false, &d); err != nil || len(d) != 1 {
+		return mongoDocument{}, fmt.Errorf("error converting the payload of %s %s into BSON: %v", rec.PK, rec.SK, err)
+	}
+
+	return mongoDocument{
+		ID:      rec.SK,
+		PK:      rec.PK,
+		SK:      rec.SK,
+		KeyID:   rec.KeyID,
+		Payload: d[0].Value,
+		Version: rec.Version,
+		TTL:     ttlTime(rec.TTL),
+	}, nil
+}
+
+// record converts a document into a Record. The Payload of a document written in native mode is
+// converted back into JSON.
+func (doc mongoReader) record() (Record, error) {
+	rec := Record{
+		PK:      doc.PK,
+		SK:      doc.SK,
+		KeyID:   doc.KeyID,
+		Version: doc.Version,
+		TTL:     ttlSeconds(doc.TTL),
+	}
+
+	if s, ok := doc.Payload.StringValueOK(); ok {
+		rec.Payload = s
+		return rec, nil
+	}
+
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: doc.Payload}}, false, false)
+	if err != nil {
+		return Record{}, fmt.Errorf("error converting the payload of %s %s into JSON: %s", doc.PK, doc.SK, err.Error())
+	}
+
+	var w struct {
+		V json.RawMessage `json:"v"`
+	}
+	if err := json.Unmarshal(b, &w); err != nil {
+		return Record{}, fmt.Errorf("error converting the payload of %s %s into JSON: %s", doc.PK, doc.SK, err.Error())
+	}
+
+	rec.Payload = string(w.V)
+	return rec, nil
+}
+
+// EnsureIndexes creates an index on PK and SK in the collection of each partition of the shop, an
+// index on each of the fields in MongoIndexes, and a TTL index that removes records ExpiryGrace after
+// their TTL passed. Creating an index that already exists does nothing.
+func (m *MongoStore) EnsureIndexes(ctx context.Context) error {
+	for _, pk := range Partitions {
+		models := []mongo.IndexModel{
+			{Keys: bson.D{{Key: "PK", Value: 1}, {Key: "SK", Value: 1}}},
+		}
+		for _, field := range MongoIndexes[pk] {
+			models = append(models, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}})
+		}
+		models = append(models, mongo.IndexModel{
+			Keys:    bson.D{{Key: "TTL", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(ExpiryGrace.Seconds())),
+		})
+
+		if _, err := m.Collection(pk).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("error creating indexes for %s: %s", pk, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Upgrade converts all documents of the partition that were written with the Payload as a string
+// into native documents, and returns the number of documents that were converted.
+func (m *MongoStore) Upgrade(ctx context.Context, pk string) (int, error) {
+	cur, err := m.Collection(pk).Find(ctx, bson.D{{Key: "Payload", Value: bson.D{{Key: "$type", Value: "string"}}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	native := &MongoStore{dbs: m.dbs, native: true}
+	n := 0
+	for cur.Next(ctx) {
+		var doc mongoReader
+		if err := cur.Decode(&doc); err != nil {
+			return n, err
+		}
+		rec, err := doc.record()
+		if err != nil {
+			return n, err
+		}
+		if err := native.Put(ctx, rec); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, cur.Err()
+}
+
+// PutBatch upserts the records using BulkWrite, with a single request per collection. The writes
+// are unordered, so a failing record doesn't stop the other records from being written.
+func (m *MongoStore) PutBatch(ctx context.Context, recs []Record) error {
+	var pks []string
+	batches := make(map[string][]Record)
+	for _, rec := range recs {
+		if _, ok := batches[rec.PK]; !ok {
+			pks = append(pks, rec.PK)
+		}
+		batches[rec.PK] = append(batches[rec.PK], rec)
+	}
+
+	var failed []Record
+	var lastErr error
+
+	for _, pk := range pks {
+		batch := batches[pk]
+		// written contains the record of each model, as records that can't be converted into a
+		// native document are left out
+		models := make([]mongo.WriteModel, 0, len(batch))
+		written := make([]Record, 0, len(batch))
+		for _, rec := range batch {
+			if !m.native {
+				models = append(models, mongo.NewReplaceOneModel().SetFilter(filter(rec.PK, rec.SK)).SetReplacement(newMongoRecord(rec)).SetUpsert(true))
+				written = append(written, rec)
+				continue
+			}
+
+			doc, err := nativeDocument(rec)
+			if err != nil {
+				lastErr = err
+				failed = append(failed, rec)
+				continue
+			}
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: rec.SK}}).SetReplacement(doc).SetUpsert(true))
+			written = append(written, rec)
+		}
+
+		if len(models) == 0 {
+			continue
+		}
+
+		coll := m.Collection(pk)
+		_, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err == nil {
+			if m.native {
+				if err := m.deleteLegacy(ctx, coll, pk, written); err != nil {
+					lastErr = err
+					failed = append(failed, written...)
+				}
+			}
+			continue
+		}
+
+		lastErr = err
+		if bwe, ok := err.(mongo.BulkWriteException); ok && len(bwe.WriteErrors) > 0 {
+			for _, we := range bwe.WriteErrors {
+				failed = append(failed, written[we.Index])
+			}
+			continue
+		}
+		failed = append(failed, written...)
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Failed: failed, Err: lastErr}
+	}
+
+	return nil
+}
+
+// Drop removes the collection that stores the records of the partition.
+func (m *MongoStore) Drop(ctx context.Context, pk string) error {
+	return m.Collection(pk).Drop(ctx)
+}
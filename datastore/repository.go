@@ -0,0 +1,381 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// UserRepository stores the users of the shop.
+type UserRepository interface {
+	// Get returns the user with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (acmeserverless.User, error)
+
+	// List returns all users.
+	List(ctx context.Context) ([]acmeserverless.User, error)
+
+	// Put creates or replaces a user.
+	Put(ctx context.Context, user acmeserverless.User) error
+
+	// Delete removes the user with the given ID.
+	Delete(ctx context.Context, id string) error
+
+	// FindByUsername returns the users with the given username.
+	FindByUsername(ctx context.Context, username string) ([]acmeserverless.User, error)
+}
+
+// CatalogRepository stores the products in the catalog of the shop.
+type CatalogRepository interface {
+	// Get returns the product with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (acmeserverless.CatalogItem, error)
+
+	// List returns all products.
+	List(ctx context.Context) ([]acmeserverless.CatalogItem, error)
+
+	// Put creates or replaces a product.
+	Put(ctx context.Context, product acmeserverless.CatalogItem) error
+
+	// Delete removes the product with the given ID.
+	Delete(ctx context.Context, id string) error
+}
+
+// OrderRepository stores the orders of the shop.
+type OrderRepository interface {
+	// Get returns the order with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (acmeserverless.Order, error)
+
+	// List returns all orders.
+	List(ctx context.Context) (acmeserverless.Orders, error)
+
+	// Put creates or replaces an order.
+	Put(ctx context.Context, order acmeserverless.Order) error
+
+	// Delete removes the order with the given ID.
+	Delete(ctx context.Context, id string) error
+
+	// ListByUser returns all orders of the user with the given userid.
+	ListByUser(ctx context.Context, userID string) (acmeserverless.Orders, error)
+}
+
+// CartRepository stores the shopping carts of the users of the shop.
+type CartRepository interface {
+	// Get returns the items in the cart of the user, or ErrNotFound.
+	Get(ctx context.Context, userID string) (acmeserverless.CartItems, error)
+
+	// List returns all carts.
+	List(ctx context.Context) (acmeserverless.Carts, error)
+
+	// Put creates or replaces the items in the cart of the user.
+	Put(ctx context.Context, userID string, items acmeserverless.CartItems) error
+
+	// Update replaces the items in the cart of the user with the items fn returns. fn gets the
+	// current items, which are nil when the user has no cart. When the cart is changed by someone
+	// else before the new items are written, fn is called again with the latest items, so updates
+	// never overwrite each other. It returns the items that were written.
+	Update(ctx context.Context, userID string, fn func(items acmeserverless.CartItems) (acmeserverless.CartItems, error)) (acmeserverless.CartItems, error)
+
+	// Delete removes the cart of the user.
+	Delete(ctx context.Context, userID string) error
+}
+
+// Repositories contains a repository for each kind of data in the shop.
+type Repositories struct {
+	Users   UserRepository
+	Catalog CatalogRepository
+	Orders  OrderRepository
+	Carts   CartRepository
+}
+
+// NewRepositories creates the repositories for all kinds of data, stored in s.
+func NewRepositories(s Store) *Repositories {
+	return &Repositories{
+		Users:   NewUserRepository(s),
+		Catalog: NewCatalogRepository(s),
+		Orders:  NewOrderRepository(s),
+		Carts:   NewCartRepository(s),
+	}
+}
+
+type userRepository struct {
+	store Store
+}
+
+// NewUserRepository creates a UserRepository that stores users in the USER partition of s, with
+// the username as KeyID.
+func NewUserRepository(s Store) UserRepository {
+	return &userRepository{store: s}
+}
+
+func (r *userRepository) Get(ctx context.Context, id string) (acmeserverless.User, error) {
+	rec, err := r.store.Get(ctx, PartitionUser, id)
+	if err != nil {
+		return acmeserverless.User{}, err
+	}
+
+	return acmeserverless.UnmarshalUser(rec.Payload)
+}
+
+func (r *userRepository) List(ctx context.Context) ([]acmeserverless.User, error) {
+	recs, err := r.store.List(ctx, PartitionUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.unmarshal(recs)
+}
+
+func (r *userRepository) Put(ctx context.Context, user acmeserverless.User) error {
+	rec, err := UserRecord(user)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Put(ctx, rec)
+}
+
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, PartitionUser, id)
+}
+
+func (r *userRepository) FindByUsername(ctx context.Context, username string) ([]acmeserverless.User, error) {
+	recs, err := r.store.QueryKeyID(ctx, PartitionUser, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.unmarshal(recs)
+}
+
+func (r *userRepository) unmarshal(recs []Record) ([]acmeserverless.User, error) {
+	users := make([]acmeserverless.User, 0, len(recs))
+	for _, rec := range recs {
+		user, err := acmeserverless.UnmarshalUser(rec.Payload)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+type catalogRepository struct {
+	store Store
+}
+
+// NewCatalogRepository creates a CatalogRepository that stores products in the PRODUCT partition
+// of s.
+func NewCatalogRepository(s Store) CatalogRepository {
+	return &catalogRepository{store: s}
+}
+
+func (r *catalogRepository) Get(ctx context.Context, id string) (acmeserverless.CatalogItem, error) {
+	rec, err := r.store.Get(ctx, PartitionProduct, id)
+	if err != nil {
+		return acmeserverless.CatalogItem{}, err
+	}
+
+	return acmeserverless.UnmarshalCatalogItem(rec.Payload)
+}
+
+func (r *catalogRepository) List(ctx context.Context) ([]acmeserverless.CatalogItem, error) {
+	recs, err := r.store.List(ctx, PartitionProduct)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]acmeserverless.CatalogItem, 0, len(recs))
+	for _, rec := range recs {
+		product, err := acmeserverless.UnmarshalCatalogItem(rec.Payload)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+func (r *catalogRepository) Put(ctx context.Context, product acmeserverless.CatalogItem) error {
+	rec, err := ProductRecord(product)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Put(ctx, rec)
+}
+
+func (r *catalogRepository) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, PartitionProduct, id)
+}
+
+type orderRepository struct {
+	store Store
+}
+
+// NewOrderRepository creates an OrderRepository that stores orders in the ORDER partition of s,
+// with the userid as KeyID.
+func NewOrderRepository(s Store) OrderRepository {
+	return &orderRepository{store: s}
+}
+
+func (r *orderRepository) Get(ctx context.Context, id string) (acmeserverless.Order, error) {
+	rec, err := r.store.Get(ctx, PartitionOrder, id)
+	if err != nil {
+		return acmeserverless.Order{}, err
+	}
+
+	return acmeserverless.UnmarshalOrder(rec.Payload)
+}
+
+func (r *orderRepository) List(ctx context.Context) (acmeserverless.Orders, error) {
+	recs, err := r.store.List(ctx, PartitionOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.unmarshal(recs)
+}
+
+func (r *orderRepository) Put(ctx context.Context, order acmeserverless.Order) error {
+	rec, err := OrderRecord(order)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Put(ctx, rec)
+}
+
+func (r *orderRepository) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, PartitionOrder, id)
+}
+
+func (r *orderRepository) ListByUser(ctx context.Context, userID string) (acmeserverless.Orders, error) {
+	recs, err := r.store.QueryKeyID(ctx, PartitionOrder, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.unmarshal(recs)
+}
+
+func (r *orderRepository) unmarshal(recs []Record) (acmeserverless.Orders, error) {
+	orders := make(acmeserverless.Orders, 0, len(recs))
+	for _, rec := range recs {
+		order, err := acmeserverless.UnmarshalOrder(rec.Payload)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// DefaultCartTTL is how long a cart is kept after it was last changed.
+const DefaultCartTTL = 7 * 24 * time.Hour
+
+type cartRepository struct {
+	store Store
+	ttl   time.Duration
+}
+
+// NewCartRepository creates a CartRepository that stores carts in the CART partition of s, with
+// the userid as sort key. Carts expire DefaultCartTTL after they were last changed.
+func NewCartRepository(s Store) CartRepository {
+	return NewCartRepositoryWithTTL(s, DefaultCartTTL)
+}
+
+// NewCartRepositoryWithTTL creates a CartRepository of which the carts expire ttl after they were
+// last changed. With a ttl of 0 carts never expire.
+func NewCartRepositoryWithTTL(s Store, ttl time.Duration) CartRepository {
+	return &cartRepository{store: s, ttl: ttl}
+}
+
+// expiry returns the TTL of a cart that is written now.
+func (r *cartRepository) expiry() int64 {
+	if r.ttl <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(r.ttl).Unix()
+}
+
+func (r *cartRepository) Get(ctx context.Context, userID string) (acmeserverless.CartItems, error) {
+	rec, err := r.store.Get(ctx, PartitionCart, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return acmeserverless.UnmarshalItems(rec.Payload)
+}
+
+func (r *cartRepository) List(ctx context.Context) (acmeserverless.Carts, error) {
+	recs, err := r.store.List(ctx, PartitionCart)
+	if err != nil {
+		return nil, err
+	}
+
+	carts := make(acmeserverless.Carts, 0, len(recs))
+	for _, rec := range recs {
+		items, err := acmeserverless.UnmarshalItems(rec.Payload)
+		if err != nil {
+			return nil, err
+		}
+		carts = append(carts, acmeserverless.Cart{
+			UserID: rec.SK,
+			Items:  items,
+		})
+	}
+
+	return carts, nil
+}
+
+func (r *cartRepository) Put(ctx context.Context, userID string, items acmeserverless.CartItems) error {
+	rec, err := CartRecord(userID, items)
+	if err != nil {
+		return err
+	}
+	rec.TTL = r.expiry()
+
+	return r.store.Put(ctx, rec)
+}
+
+func (r *cartRepository) Update(ctx context.Context, userID string, fn func(items acmeserverless.CartItems) (acmeserverless.CartItems, error)) (acmeserverless.CartItems, error) {
+	var updated acmeserverless.CartItems
+
+	_, err := UpdateRecord(ctx, r.store, PartitionCart, userID, func(rec *Record, exists bool) error {
+		var items acmeserverless.CartItems
+		if exists {
+			var err error
+			if items, err = acmeserverless.UnmarshalItems(rec.Payload); err != nil {
+				return err
+			}
+		}
+
+		items, err := fn(items)
+		if err != nil {
+			return err
+		}
+
+		payload, err := items.Marshal()
+		if err != nil {
+			return err
+		}
+
+		rec.Payload = string(payload)
+		rec.TTL = r.expiry()
+		updated = items
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (r *cartRepository) Delete(ctx context.Context, userID string) error {
+	return r.store.Delete(ctx, PartitionCart, userID)
+}
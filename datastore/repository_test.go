@@ -0,0 +1,146 @@
+package datastore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+// testRepositories creates, reads, updates, and removes data of each kind with the repositories,
+// which must be empty.
+func testRepositories(t *testing.T, repos *Repositories) {
+	t.Helper()
+	ctx := context.Background()
+
+	// Users
+	jdoe := acmeserverless.User{ID: "user-1", Username: "jdoe", Firstname: "John", Lastname: "Doe", Email: "jdoe@example.com"}
+	jane := acmeserverless.User{ID: "user-2", Username: "jane", Firstname: "Jane", Lastname: "Doe", Email: "jane@example.com"}
+	for _, u := range []acmeserverless.User{jdoe, jane} {
+		if err := repos.Users.Put(ctx, u); err != nil {
+			t.Fatalf("error storing user: %s", err.Error())
+		}
+	}
+
+	user, err := repos.Users.Get(ctx, "user-1")
+	if err != nil || !reflect.DeepEqual(user, jdoe) {
+		t.Fatalf("user is %+v (error %v), want %+v", user, err, jdoe)
+	}
+	users, err := repos.Users.FindByUsername(ctx, "jane")
+	if err != nil || len(users) != 1 || users[0].ID != "user-2" {
+		t.Fatalf("users named jane are %+v (error %v), want user-2", users, err)
+	}
+
+	jdoe.Email = "john@example.com"
+	if err := repos.Users.Put(ctx, jdoe); err != nil {
+		t.Fatalf("error replacing user: %s", err.Error())
+	}
+	if user, _ := repos.Users.Get(ctx, "user-1"); user.Email != "john@example.com" {
+		t.Fatalf("user has email %s after it was replaced", user.Email)
+	}
+
+	if err := repos.Users.Delete(ctx, "user-2"); err != nil {
+		t.Fatalf("error removing user: %s", err.Error())
+	}
+	if _, err := repos.Users.Get(ctx, "user-2"); err != ErrNotFound {
+		t.Fatalf("error getting removed user is %v, want ErrNotFound", err)
+	}
+	if users, err := repos.Users.List(ctx); err != nil || len(users) != 1 {
+		t.Fatalf("users are %+v (error %v), want only user-1", users, err)
+	}
+
+	// Products
+	mat := acmeserverless.CatalogItem{ID: "product-1", Name: "Yoga mat", ShortDescription: "Mat", Price: 62.5, Tags: []string{"mat"}}
+	if err := repos.Catalog.Put(ctx, mat); err != nil {
+		t.Fatalf("error storing product: %s", err.Error())
+	}
+	product, err := repos.Catalog.Get(ctx, "product-1")
+	if err != nil || !reflect.DeepEqual(product, mat) {
+		t.Fatalf("product is %+v (error %v), want %+v", product, err, mat)
+	}
+	if err := repos.Catalog.Delete(ctx, "product-1"); err != nil {
+		t.Fatalf("error removing product: %s", err.Error())
+	}
+	if products, err := repos.Catalog.List(ctx); err != nil || len(products) != 0 {
+		t.Fatalf("products are %+v (error %v), want none", products, err)
+	}
+
+	// Orders
+	items := []acmeserverless.CartItem{
+		{ItemID: str("product-1"), Name: "Yoga mat", Description: "Mat", Price: 62.5, Quantity: 2},
+		{ItemID: str("product-2"), Name: "Water bottle", Description: "Bottle", Price: 10, Quantity: 1},
+	}
+	orders := []acmeserverless.Order{
+		{OrderID: "order-1", UserID: "user-1", Status: str("Pending"), Delivery: "UPS/Fedex", Cart: items, Total: "135"},
+		{OrderID: "order-2", UserID: "user-1", Status: str("Pending"), Delivery: "UPS/Fedex", Cart: items[1:], Total: "10"},
+		{OrderID: "order-3", UserID: "user-2", Status: str("Pending"), Delivery: "UPS/Fedex", Cart: items[:1], Total: "125"},
+	}
+	for _, o := range orders {
+		if err := repos.Orders.Put(ctx, o); err != nil {
+			t.Fatalf("error storing order: %s", err.Error())
+		}
+	}
+
+	order, err := repos.Orders.Get(ctx, "order-1")
+	if err != nil || !reflect.DeepEqual(order, orders[0]) {
+		t.Fatalf("order is %+v (error %v), want %+v", order, err, orders[0])
+	}
+	byUser, err := repos.Orders.ListByUser(ctx, "user-1")
+	if err != nil || len(byUser) != 2 || byUser[0].UserID != "user-1" || byUser[1].UserID != "user-1" {
+		t.Fatalf("orders of user-1 are %+v (error %v), want order-1 and order-2", byUser, err)
+	}
+
+	orders[0].Status = str("Shipped")
+	orders[0].Cart = items[:1]
+	if err := repos.Orders.Put(ctx, orders[0]); err != nil {
+		t.Fatalf("error replacing order: %s", err.Error())
+	}
+	if order, _ := repos.Orders.Get(ctx, "order-1"); !reflect.DeepEqual(order, orders[0]) {
+		t.Fatalf("order is %+v after it was replaced, want %+v", order, orders[0])
+	}
+
+	if err := repos.Orders.Delete(ctx, "order-3"); err != nil {
+		t.Fatalf("error removing order: %s", err.Error())
+	}
+	if all, err := repos.Orders.List(ctx); err != nil || len(all) != 2 {
+		t.Fatalf("orders are %+v (error %v), want order-1 and order-2", all, err)
+	}
+
+	// Carts
+	if err := repos.Carts.Put(ctx, "user-1", items); err != nil {
+		t.Fatalf("error storing cart: %s", err.Error())
+	}
+	cart, err := repos.Carts.Get(ctx, "user-1")
+	if err != nil || !reflect.DeepEqual(cart, acmeserverless.CartItems(items)) {
+		t.Fatalf("cart is %+v (error %v), want %+v", cart, err, items)
+	}
+
+	updated, err := repos.Carts.Update(ctx, "user-2", func(current acmeserverless.CartItems) (acmeserverless.CartItems, error) {
+		if current != nil {
+			t.Fatalf("cart of a user without a cart is %+v", current)
+		}
+		return append(current, items[0]), nil
+	})
+	if err != nil || len(updated) != 1 {
+		t.Fatalf("updated cart is %+v (error %v), want one item", updated, err)
+	}
+	if carts, err := repos.Carts.List(ctx); err != nil || len(carts) != 2 {
+		t.Fatalf("carts are %+v (error %v), want the carts of user-1 and user-2", carts, err)
+	}
+
+	if err := repos.Carts.Delete(ctx, "user-1"); err != nil {
+		t.Fatalf("error removing cart: %s", err.Error())
+	}
+	if _, err := repos.Carts.Get(ctx, "user-1"); err != ErrNotFound {
+		t.Fatalf("error getting removed cart is %v, want ErrNotFound", err)
+	}
+}
+
+func TestRepositories(t *testing.T) {
+	testRepositories(t, NewRepositories(NewMemoryStore()))
+}
@@ -0,0 +1,143 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+)
+
+// EntityPartitions maps each kind of seed data to the partition it is stored in.
+var EntityPartitions = map[string]string{
+	EntityUsers:   PartitionUser,
+	EntityCatalog: PartitionProduct,
+	EntityOrders:  PartitionOrder,
+	EntityCarts:   PartitionCart,
+}
+
+// Dropper is implemented by stores that can remove all records of a partition at once.
+type Dropper interface {
+	// Drop removes all records of the partition.
+	Drop(ctx context.Context, pk string) error
+}
+
+// ResetPlan contains the IDs of the records of each entity that Reset deletes.
+type ResetPlan map[string][]string
+
+// Count returns the number of records in the plan.
+func (p ResetPlan) Count() int {
+	n := 0
+	for _, ids := range p {
+		n += len(ids)
+	}
+	return n
+}
+
+// PlanReset lists the IDs of all records of the entities. When store is set the records are listed
+// from the single table layout, so records with a payload that can't be parsed are included.
+// Otherwise they are listed using the repositories.
+func PlanReset(ctx context.Context, repos *Repositories, store Store, entities []string) (ResetPlan, error) {
+	plan := make(ResetPlan)
+
+	for _, e := range entities {
+		pk, ok := EntityPartitions[e]
+		if !ok {
+			return nil, fmt.Errorf("unknown entity %q", e)
+		}
+
+		if store == nil {
+			ids, err := listIDs(ctx, repos, e)
+			if err != nil {
+				return nil, fmt.Errorf("error listing %s: %s", e, err.Error())
+			}
+			plan[e] = ids
+			continue
+		}
+
+		recs, err := store.List(ctx, pk)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %s", e, err.Error())
+		}
+		ids := make([]string, 0, len(recs))
+		for _, rec := range recs {
+			ids = append(ids, rec.SK)
+		}
+		plan[e] = ids
+	}
+
+	return plan, nil
+}
+
+// listIDs lists the IDs of all records of the entity using the repositories.
+func listIDs(ctx context.Context, repos *Repositories, entity string) ([]string, error) {
+	var ids []string
+
+	switch entity {
+	case EntityUsers:
+		users, err := repos.Users.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, usr := range users {
+			ids = append(ids, usr.ID)
+		}
+	case EntityCatalog:
+		products, err := repos.Catalog.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+	case EntityOrders:
+		orders, err := repos.Orders.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ord := range orders {
+			ids = append(ids, ord.OrderID)
+		}
+	case EntityCarts:
+		carts, err := repos.Carts.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, crt := range carts {
+			ids = append(ids, crt.UserID)
+		}
+	}
+
+	return ids, nil
+}
+
+// Reset deletes the records in the plan, using store when it is set and the repositories otherwise.
+// Reset doesn't stop at the first record that can't be deleted, but reports every failed record and
+// returns the number of failed records.
+func Reset(ctx context.Context, repos *Repositories, store Store, plan ResetPlan, report func(entity string, id string, err error)) int {
+	failed := 0
+
+	for _, e := range Entities {
+		for _, id := range plan[e] {
+			var err error
+			switch {
+			case store != nil:
+				err = store.Delete(ctx, EntityPartitions[e], id)
+			case e == EntityUsers:
+				err = repos.Users.Delete(ctx, id)
+			case e == EntityCatalog:
+				err = repos.Catalog.Delete(ctx, id)
+			case e == EntityOrders:
+				err = repos.Orders.Delete(ctx, id)
+			case e == EntityCarts:
+				err = repos.Carts.Delete(ctx, id)
+			}
+
+			if err != nil {
+				failed++
+				if report != nil {
+					report(e, id, err)
+				}
+			}
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/target"
+)
+
+var (
+	only   string
+	dryRun bool
+	yes    bool
+	drop   bool
+	config target.Config
+)
+
+func main() {
+	// Read flags
+	config.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&only, "only", "", "A comma separated list of entities to remove: users, catalog, orders, carts (defaults to all)")
+	flag.BoolVar(&dryRun, "dry-run", false, "List the records that would be removed without removing them")
+	flag.BoolVar(&yes, "yes", false, "Remove the records without asking for confirmation")
+	flag.BoolVar(&drop, "drop", false, "Drop the collections instead of deleting the records one by one (mongodb only)")
+	flag.Parse()
+
+	entities, err := datastore.ParseEntities(only)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	// Initialize the database connection
+	t, err := target.Open(ctx, config)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+	defer t.Close()
+
+	dropper, ok := t.Store.(datastore.Dropper)
+	if drop && !ok {
+		t.Close()
+		log.Fatalf("Error: the 'drop' flag can only be used with the mongodb target")
+	}
+
+	plan, err := datastore.PlanReset(ctx, t.Repositories, t.Store, entities)
+	if err != nil {
+		t.Close()
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	for _, entity := range entities {
+		fmt.Printf("%s: %d records\n", entity, len(plan[entity]))
+		if dryRun {
+			for _, id := range plan[entity] {
+				fmt.Printf("  %s\n", id)
+			}
+		}
+	}
+
+	if dryRun {
+		return
+	}
+
+	if !drop && plan.Count() == 0 {
+		log.Printf("nothing to remove")
+		return
+	}
+
+	if !yes && !confirm(fmt.Sprintf("This removes %d records from %s. Type %q to continue: ", plan.Count(), config.Target, config.Target), config.Target) {
+		log.Printf("aborted, nothing was removed")
+		return
+	}
+
+	if drop {
+		for _, entity := range entities {
+			if err := dropper.Drop(ctx, datastore.EntityPartitions[entity]); err != nil {
+				t.Close()
+				log.Fatalf("error dropping %s: %s", entity, err.Error())
+			}
+			log.Printf("dropped %s", entity)
+		}
+		return
+	}
+
+	failed := datastore.Reset(ctx, t.Repositories, t.Store, plan, func(entity string, id string, err error) {
+		log.Printf("error removing %s %s: %s", entity, id, err.Error())
+	})
+
+	log.Printf("removed %d records from %s (%d failed)", plan.Count()-failed, config.Target, failed)
+
+	if failed > 0 {
+		t.Close()
+		os.Exit(1)
+	}
+}
+
+// confirm asks the question and returns true when the answer is the expected text.
+func confirm(question string, expected string) bool {
+	fmt.Print(question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(answer) == expected
+}
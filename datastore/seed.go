@@ -0,0 +1,365 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// EntityUsers selects the users in user-data.json.
+	EntityUsers = "users"
+
+	// EntityCatalog selects the products in catalog-data.json.
+	EntityCatalog = "catalog"
+
+	// EntityOrders selects the orders in order-data.json.
+	EntityOrders = "orders"
+
+	// EntityCarts selects the carts in cart-data.json.
+	EntityCarts = "carts"
+)
+
+// Entities contains all kinds of seed data, in the order they are written.
+var Entities = []string{EntityUsers, EntityCatalog, EntityOrders, EntityCarts}
+
+// SeedFiles maps each kind of seed data to the name of the file it is read from.
+var SeedFiles = map[string]string{
+	EntityUsers:   "user-data.json",
+	EntityCatalog: "catalog-data.json",
+	EntityOrders:  "order-data.json",
+	EntityCarts:   "cart-data.json",
+}
+
+// SeedData contains the data to seed a datastore with.
+type SeedData struct {
+	Users   []acmeserverless.User
+	Catalog []acmeserverless.CatalogItem
+	Orders  acmeserverless.Orders
+	Carts   acmeserverless.Carts
+}
+
+// ParseEntities parses a comma separated list of entities, like users,catalog. An empty list
+// selects all entities.
+func ParseEntities(list string) ([]string, error) {
+	if len(strings.TrimSpace(list)) == 0 {
+		return Entities, nil
+	}
+
+	var entities []string
+	for _, e := range strings.Split(list, ",") {
+		e = strings.TrimSpace(e)
+		if _, ok := SeedFiles[e]; !ok {
+			return nil, fmt.Errorf("unknown entity %q, must be one of %s", e, strings.Join(Entities, ","))
+		}
+		entities = append(entities, e)
+	}
+
+	return entities, nil
+}
+
+// LoadSeedData reads the files of the selected entities from dir.
+func LoadSeedData(dir string, entities []string) (*SeedData, error) {
+	data := &SeedData{}
+
+	for _, e := range entities {
+		var v interface{}
+		switch e {
+		case EntityUsers:
+			v = &data.Users
+		case EntityCatalog:
+			v = &data.Catalog
+		case EntityOrders:
+			v = &data.Orders
+		case EntityCarts:
+			v = &data.Carts
+		default:
+			return nil, fmt.Errorf("unknown entity %q", e)
+		}
+
+		file := filepath.Join(dir, SeedFiles[e])
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", file, err.Error())
+		}
+
+		if err := json.Unmarshal(b, v); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", file, err.Error())
+		}
+	}
+
+	return data, nil
+}
+
+// FreshOrders assigns a new random ID to every order and resets the status to Pending Payment, so
+// every time the orders are seeded they are added as new orders.
+func FreshOrders(orders acmeserverless.Orders) {
+	for i := range orders {
+		status := "Pending Payment"
+		orders[i].OrderID = uuid.Must(uuid.NewV4()).String()
+		orders[i].Status = &status
+	}
+}
+
+// DeriveOrderIDs replaces the ID of every order with a name based UUID derived from the namespace
+// and the ID the order has in the seed data. An order without an ID is derived from its JSON
+// encoding instead. An order gets the same ID every time, wherever it is in the list and whichever
+// orders are added or removed around it, while different namespaces result in different IDs.
+func DeriveOrderIDs(orders acmeserverless.Orders, namespace string) error {
+	ns := uuid.NewV5(uuid.NamespaceURL, "https://github.com/retgits/acme-serverless/"+namespace)
+	for i := range orders {
+		name := "id/" + orders[i].OrderID
+		if len(orders[i].OrderID) == 0 {
+			payload, err := orders[i].Marshal()
+			if err != nil {
+				return fmt.Errorf("error deriving ID of order %d: %s", i, err.Error())
+			}
+			name = "order/" + string(payload)
+		}
+		orders[i].OrderID = uuid.NewV5(ns, name).String()
+	}
+
+	return nil
+}
+
+// SeedOptions configure how Seed writes the seed data.
+type SeedOptions struct {
+	// Workers is the number of batches that are written at the same time. Defaults to 4.
+	Workers int
+
+	// BatchSize is the number of records in a batch. Defaults to 25, the maximum number of
+	// records DynamoDB accepts in a single BatchWriteItem request.
+	BatchSize int
+
+	// Progress, when set, is called after every batch with the counts of the entity so far.
+	Progress func(entity string, total int, counts SeedCounts)
+
+	// OnError, when set, is called for every record that could not be written, and for every record
+	// that was skipped because of a duplicate ID.
+	OnError func(entity string, id string, err error)
+}
+
+// SeedCounts contains the number of records that were written, skipped, and failed.
+type SeedCounts struct {
+	// Written is the number of records that were written.
+	Written int
+
+	// Skipped is the number of records that were not written because they have no ID, or an ID
+	// that was already used by an earlier record of the same entity.
+	Skipped int
+
+	// Failed is the number of records that could not be written.
+	Failed int
+}
+
+// SeedReport contains the counts of each entity that was seeded.
+type SeedReport map[string]SeedCounts
+
+// Total returns the sum of the counts of all entities.
+func (r SeedReport) Total() SeedCounts {
+	var t SeedCounts
+	for _, c := range r {
+		t.Written += c.Written
+		t.Skipped += c.Skipped
+		t.Failed += c.Failed
+	}
+	return t
+}
+
+// seedItem is a single record to seed.
+type seedItem struct {
+	id  string
+	rec Record
+	put func(ctx context.Context) error
+}
+
+// seedBatch is a number of records of the same entity that are written together.
+type seedBatch struct {
+	entity string
+	items  []seedItem
+}
+
+// Seed writes the seed data using a pool of workers. When store implements BatchWriter the records
+// are written in batches, otherwise they are written one by one using the repositories. Seed doesn't
+// stop at the first record that can't be written, but reports every failed record to OnError.
+//
+// Records are upserted using their IDs, so seeding the same data twice doesn't duplicate anything.
+func Seed(ctx context.Context, repos *Repositories, store Store, data *SeedData, opts SeedOptions) SeedReport {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 25
+	}
+
+	bw, _ := store.(BatchWriter)
+
+	report := make(SeedReport)
+	totals := make(map[string]int)
+	var mu sync.Mutex
+
+	// count adds the counts of a batch to the report and reports the progress
+	count := func(entity string, c SeedCounts) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		r := report[entity]
+		r.Written += c.Written
+		r.Skipped += c.Skipped
+		r.Failed += c.Failed
+		report[entity] = r
+
+		if opts.Progress != nil {
+			opts.Progress(entity, totals[entity], r)
+		}
+	}
+
+	fail := func(entity string, id string, err error) {
+		if opts.OnError != nil {
+			mu.Lock()
+			opts.OnError(entity, id, err)
+			mu.Unlock()
+		}
+	}
+
+	var batches []seedBatch
+	for _, entity := range Entities {
+		items, failed := seedItems(entity, repos, data, fail)
+		totals[entity] = len(items) + failed.Skipped + failed.Failed
+		if failed.Skipped+failed.Failed > 0 || len(items) > 0 {
+			count(entity, failed)
+		}
+
+		for start := 0; start < len(items); start += opts.BatchSize {
+			end := start + opts.BatchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			batches = append(batches, seedBatch{entity: entity, items: items[start:end]})
+		}
+	}
+
+	jobs := make(chan seedBatch)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				count(b.entity, writeBatch(ctx, bw, b, fail))
+			}
+		}()
+	}
+
+	for _, b := range batches {
+		jobs <- b
+	}
+	close(jobs)
+	wg.Wait()
+
+	return report
+}
+
+// seedItems creates the items to seed for an entity. Records without an ID or with a duplicate ID
+// are skipped and records that can't be converted fail, the counts of those are returned.
+func seedItems(entity string, repos *Repositories, data *SeedData, fail func(string, string, error)) ([]seedItem, SeedCounts) {
+	var items []seedItem
+	var c SeedCounts
+	seen := make(map[string]bool)
+
+	add := func(id string, rec Record, err error, put func(ctx context.Context) error) {
+		switch {
+		case len(id) == 0:
+			c.Skipped++
+		case seen[id]:
+			c.Skipped++
+			fail(entity, id, fmt.Errorf("duplicate ID, only the first record is written"))
+		case err != nil:
+			c.Failed++
+			fail(entity, id, err)
+		default:
+			seen[id] = true
+			items = append(items, seedItem{id: id, rec: rec, put: put})
+		}
+	}
+
+	switch entity {
+	case EntityUsers:
+		for _, usr := range data.Users {
+			usr := usr
+			rec, err := UserRecord(usr)
+			add(usr.ID, rec, err, func(ctx context.Context) error { return repos.Users.Put(ctx, usr) })
+		}
+	case EntityCatalog:
+		for _, product := range data.Catalog {
+			product := product
+			rec, err := ProductRecord(product)
+			add(product.ID, rec, err, func(ctx context.Context) error { return repos.Catalog.Put(ctx, product) })
+		}
+	case EntityOrders:
+		for _, ord := range data.Orders {
+			ord := ord
+			rec, err := OrderRecord(ord)
+			add(ord.OrderID, rec, err, func(ctx context.Context) error { return repos.Orders.Put(ctx, ord) })
+		}
+	case EntityCarts:
+		for _, crt := range data.Carts {
+			crt := crt
+			rec, err := CartRecord(crt.UserID, crt.Items)
+			add(crt.UserID, rec, err, func(ctx context.Context) error { return repos.Carts.Put(ctx, crt.UserID, crt.Items) })
+		}
+	}
+
+	return items, c
+}
+
+// writeBatch writes the items of the batch and returns the counts.
+func writeBatch(ctx context.Context, bw BatchWriter, b seedBatch, fail func(string, string, error)) SeedCounts {
+	var c SeedCounts
+
+	if bw == nil {
+		for _, item := range b.items {
+			if err := item.put(ctx); err != nil {
+				c.Failed++
+				fail(b.entity, item.id, err)
+				continue
+			}
+			c.Written++
+		}
+		return c
+	}
+
+	recs := make([]Record, len(b.items))
+	for i, item := range b.items {
+		recs[i] = item.rec
+	}
+
+	err := bw.PutBatch(ctx, recs)
+	if err == nil {
+		c.Written = len(recs)
+		return c
+	}
+
+	if be, ok := err.(*BatchError); ok {
+		for _, rec := range be.Failed {
+			fail(b.entity, rec.SK, be.Err)
+		}
+		c.Failed = len(be.Failed)
+		c.Written = len(recs) - c.Failed
+		return c
+	}
+
+	for _, item := range b.items {
+		fail(b.entity, item.id, err)
+	}
+	c.Failed = len(recs)
+	return c
+}
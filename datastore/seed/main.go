@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/target"
+)
+
+var (
+	dataDir   string
+	only      string
+	workers   int
+	batchSize int
+	fresh     bool
+	deriveIDs string
+	validate  bool
+	noCheck   bool
+	strict    bool
+	config    target.Config
+)
+
+func main() {
+	// Read flags
+	config.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&dataDir, "data-dir", ".", "The directory containing the seed data files (defaults to the current directory)")
+	flag.StringVar(&only, "only", "", "A comma separated list of entities to seed: users, catalog, orders, carts (defaults to all)")
+	flag.IntVar(&workers, "workers", 4, "The number of batches written at the same time (defaults to 4)")
+	flag.IntVar(&batchSize, "batch-size", 25, "The number of records written in a single request (defaults to 25)")
+	flag.BoolVar(&fresh, "fresh", false, "Assign a new random ID to every order and reset its status, so every run adds new orders")
+	flag.StringVar(&deriveIDs, "derive-ids", "", "Replace the ID of every order with an ID derived from this namespace, the same namespace always results in the same IDs")
+	flag.BoolVar(&validate, "validate", false, "Only validate the data files and report the problems, without connecting to the datastore")
+	flag.BoolVar(&noCheck, "skip-validation", false, "Seed the data without validating it first")
+	flag.BoolVar(&strict, "strict", false, "Treat warnings of the validation, like orders of users that don't exist, as problems")
+	flag.Parse()
+
+	if fresh && len(deriveIDs) > 0 {
+		log.Fatalf("Error: fresh and derive-ids can't be used together")
+	}
+
+	entities, err := datastore.ParseEntities(only)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	// Read all files of the selected entities
+	// if any of the files are not read successfully the app stops before anything is written
+	var data *datastore.SeedData
+	if noCheck && !validate {
+		data, err = datastore.LoadSeedData(dataDir, entities)
+		if err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+	} else {
+		var errs []datastore.ValidationError
+		data, errs = datastore.ValidateSeedData(dataDir, entities)
+		problems, warnings := 0, 0
+		for _, e := range errs {
+			log.Print(e.Error())
+			if e.Warning && !strict {
+				warnings++
+			} else {
+				problems++
+			}
+		}
+
+		switch {
+		case problems > 0 && validate:
+			log.Fatalf("validation failed: %d problems found", problems)
+		case problems > 0:
+			log.Fatalf("validation failed: %d problems found, nothing was written (use -skip-validation to seed anyway)", problems)
+		case validate:
+			log.Printf("validation passed with %d warnings: %d users, %d products, %d orders, and %d carts", warnings, len(data.Users), len(data.Catalog), len(data.Orders), len(data.Carts))
+			return
+		}
+	}
+
+	ctx := context.Background()
+
+	// Initialize the database connection
+	t, err := target.Open(ctx, config)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+	defer t.Close()
+
+	// By default the orders keep the IDs in the data files, so running the seed app again updates
+	// the same orders rather than adding new ones
+	switch {
+	case fresh:
+		datastore.FreshOrders(data.Orders)
+	case len(deriveIDs) > 0:
+		if err := datastore.DeriveOrderIDs(data.Orders, deriveIDs); err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+	}
+
+	opts := datastore.SeedOptions{
+		Workers:   workers,
+		BatchSize: batchSize,
+		Progress: func(entity string, total int, c datastore.SeedCounts) {
+			log.Printf("%s: %d/%d written, %d skipped, %d failed", entity, c.Written, total, c.Skipped, c.Failed)
+		},
+		OnError: func(entity string, id string, err error) {
+			log.Printf("error storing %s %s: %s", entity, id, err.Error())
+		},
+	}
+
+	report := datastore.Seed(ctx, t.Repositories, t.Store, data, opts)
+
+	log.Printf("summary of seeding %s:", config.Target)
+	for _, entity := range entities {
+		c := report[entity]
+		log.Printf("  %-8s %5d written %5d skipped %5d failed", entity, c.Written, c.Skipped, c.Failed)
+	}
+	total := report.Total()
+	log.Printf("  %-8s %5d written %5d skipped %5d failed", "total", total.Written, total.Skipped, total.Failed)
+
+	if total.Failed > 0 {
+		t.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"testing"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func TestDeriveOrderIDs(t *testing.T) {
+	derive := func(namespace string, orders ...acmeserverless.Order) []string {
+		t.Helper()
+		if err := DeriveOrderIDs(orders, namespace); err != nil {
+			t.Fatalf("error deriving order IDs: %s", err.Error())
+		}
+		ids := []string{}
+		for _, o := range orders {
+			ids = append(ids, o.OrderID)
+		}
+		return ids
+	}
+
+	first := acmeserverless.Order{OrderID: "5e5ee0d7a2b5cdf2b5f3c6a8", UserID: "user-1", Total: "10"}
+	second := acmeserverless.Order{OrderID: "5e5ee0d7a2b5cdf2b5f3c6a9", UserID: "user-1", Total: "20"}
+	noID := acmeserverless.Order{UserID: "user-2", Total: "30"}
+
+	ids := derive("test", first, second, noID)
+	if ids[0] == ids[1] || ids[0] == first.OrderID {
+		t.Fatalf("derived %v, want a new and unique ID for every order", ids)
+	}
+
+	// The ID of an order doesn't depend on its position or the other orders
+	if got := derive("test", noID, second); got[0] != ids[2] || got[1] != ids[1] {
+		t.Fatalf("derived %v after reordering, want %v", got, []string{ids[2], ids[1]})
+	}
+
+	// The same order ID in another namespace results in another ID
+	if got := derive("staging", first); got[0] == ids[0] {
+		t.Fatalf("derived %s in both namespaces", got[0])
+	}
+
+	// Orders without an ID are derived from their content
+	changed := noID
+	changed.Total = "31"
+	if got := derive("test", changed); got[0] == ids[2] {
+		t.Fatalf("derived %s for orders with different content", got[0])
+	}
+}
@@ -0,0 +1,685 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/creditcard"
+)
+
+// Dialect is the SQL database the SQLStore connects to.
+type Dialect string
+
+const (
+	// SQLite stores all data in a local file, or in memory, and works without a network
+	// connection. It uses the database/sql driver registered as sqlite3, like
+	// github.com/mattn/go-sqlite3.
+	SQLite Dialect = "sqlite3"
+
+	// PostgreSQL uses the database/sql driver registered as postgres, like github.com/lib/pq.
+	PostgreSQL Dialect = "postgres"
+)
+
+// SQLStore stores the data of the shop in a relational database. Unlike the other stores it doesn't
+// use the single table layout: every kind of data has its own tables, and the items of orders and
+// carts are stored as separate lines instead of a JSON Payload. The TTL of carts isn't stored, so
+// carts never expire.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewSQLStore creates a SQLStore that uses the given database.
+func NewSQLStore(db *sql.DB, dialect Dialect) *SQLStore {
+	return &SQLStore{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// OpenSQL opens the database and applies all schema migrations. The driver for the dialect must be
+// registered by importing it in the main package.
+func OpenSQL(ctx context.Context, dialect Dialect, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows a single writer, and every connection to an in-memory database has its
+	// own database
+	if dialect == SQLite {
+		db.SetMaxOpenConns(1)
+	}
+
+	s := NewSQLStore(db, dialect)
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// DB returns the database the store uses.
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate applies all schema migrations that have not been applied yet.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %s", err.Error())
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %s", err.Error())
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		err := s.tx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range migrations[i] {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), version, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error applying migration %d: %s", version, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Repositories returns the repositories for all kinds of data, stored in the database.
+func (s *SQLStore) Repositories() *Repositories {
+	return &Repositories{
+		Users:   &sqlUserRepository{s},
+		Catalog: &sqlCatalogRepository{s},
+		Orders:  &sqlOrderRepository{s},
+		Carts:   &sqlCartRepository{s},
+	}
+}
+
+// rebind replaces the ? placeholders in the query with the placeholders of the dialect.
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != PostgreSQL {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// tx runs fn in a transaction, which is committed when fn returns nil and rolled back otherwise.
+func (s *SQLStore) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type sqlUserRepository struct {
+	s *SQLStore
+}
+
+const userColumns = `id, username, password, firstname, lastname, email`
+
+func (r *sqlUserRepository) Get(ctx context.Context, id string) (acmeserverless.User, error) {
+	users, err := r.query(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+	if err != nil {
+		return acmeserverless.User{}, err
+	}
+
+	if len(users) == 0 {
+		return acmeserverless.User{}, ErrNotFound
+	}
+
+	return users[0], nil
+}
+
+func (r *sqlUserRepository) List(ctx context.Context) ([]acmeserverless.User, error) {
+	return r.query(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+}
+
+func (r *sqlUserRepository) Put(ctx context.Context, u acmeserverless.User) error {
+	_, err := r.s.db.ExecContext(ctx, r.s.rebind(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET username = excluded.username, password = excluded.password,
+		firstname = excluded.firstname, lastname = excluded.lastname, email = excluded.email`),
+		u.ID, u.Username, u.Password, u.Firstname, u.Lastname, u.Email)
+
+	return err
+}
+
+func (r *sqlUserRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.s.db.ExecContext(ctx, r.s.rebind(`DELETE FROM users WHERE id = ?`), id)
+	return err
+}
+
+func (r *sqlUserRepository) FindByUsername(ctx context.Context, username string) ([]acmeserverless.User, error) {
+	return r.query(ctx, `SELECT `+userColumns+` FROM users WHERE username = ? ORDER BY id`, username)
+}
+
+func (r *sqlUserRepository) query(ctx context.Context, query string, args ...interface{}) ([]acmeserverless.User, error) {
+	rows, err := r.s.db.QueryContext(ctx, r.s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]acmeserverless.User, 0)
+	for rows.Next() {
+		var u acmeserverless.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Password, &u.Firstname, &u.Lastname, &u.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+type sqlCatalogRepository struct {
+	s *SQLStore
+}
+
+const productColumns = `id, name, short_description, description, image_url1, image_url2, image_url3, price`
+
+func (r *sqlCatalogRepository) Get(ctx context.Context, id string) (acmeserverless.CatalogItem, error) {
+	products, err := r.query(ctx, `SELECT `+productColumns+` FROM products WHERE id = ?`, id)
+	if err != nil {
+		return acmeserverless.CatalogItem{}, err
+	}
+
+	if len(products) == 0 {
+		return acmeserverless.CatalogItem{}, ErrNotFound
+	}
+
+	return products[0], nil
+}
+
+func (r *sqlCatalogRepository) List(ctx context.Context) ([]acmeserverless.CatalogItem, error) {
+	return r.query(ctx, `SELECT `+productColumns+` FROM products ORDER BY id`)
+}
+
+func (r *sqlCatalogRepository) Put(ctx context.Context, p acmeserverless.CatalogItem) error {
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, r.s.rebind(`INSERT INTO products (`+productColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name, short_description = excluded.short_description,
+			description = excluded.description, image_url1 = excluded.image_url1, image_url2 = excluded.image_url2,
+			image_url3 = excluded.image_url3, price = excluded.price`),
+			p.ID, p.Name, p.ShortDescription, p.Description, p.ImageURL1, p.ImageURL2, p.ImageURL3, p.Price)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM product_tags WHERE product_id = ?`), p.ID); err != nil {
+			return err
+		}
+
+		for i, tag := range p.Tags {
+			if _, err := tx.ExecContext(ctx, r.s.rebind(`INSERT INTO product_tags (product_id, position, tag) VALUES (?, ?, ?)`), p.ID, i, tag); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *sqlCatalogRepository) Delete(ctx context.Context, id string) error {
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM product_tags WHERE product_id = ?`), id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM products WHERE id = ?`), id)
+		return err
+	})
+}
+
+func (r *sqlCatalogRepository) query(ctx context.Context, query string, args ...interface{}) ([]acmeserverless.CatalogItem, error) {
+	rows, err := r.s.db.QueryContext(ctx, r.s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]acmeserverless.CatalogItem, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var p acmeserverless.CatalogItem
+		if err := rows.Scan(&p.ID, &p.Name, &p.ShortDescription, &p.Description, &p.ImageURL1, &p.ImageURL2, &p.ImageURL3, &p.Price); err != nil {
+			return nil, err
+		}
+		p.Tags = make([]string, 0)
+		index[p.ID] = len(products)
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(products) == 0 {
+		return products, nil
+	}
+
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	err = r.s.inBatches(ids, func(batch []string, args []interface{}) error {
+		tags, err := r.s.db.QueryContext(ctx, r.s.rebind(`SELECT product_id, tag FROM product_tags
+			WHERE product_id IN (`+placeholders(len(batch))+`) ORDER BY product_id, position`), args...)
+		if err != nil {
+			return err
+		}
+		defer tags.Close()
+
+		for tags.Next() {
+			var id, tag string
+			if err := tags.Scan(&id, &tag); err != nil {
+				return err
+			}
+			if i, ok := index[id]; ok {
+				products[i].Tags = append(products[i].Tags, tag)
+			}
+		}
+
+		return tags.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+type sqlOrderRepository struct {
+	s *SQLStore
+}
+
+const orderColumns = `id, status, user_id, firstname, lastname, email, has_address, street, city, zip, state, country,
+	delivery, card_type, card_number, card_expiry_month, card_expiry_year, card_cvv, total`
+
+func (r *sqlOrderRepository) Get(ctx context.Context, id string) (acmeserverless.Order, error) {
+	orders, err := r.query(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id)
+	if err != nil {
+		return acmeserverless.Order{}, err
+	}
+
+	if len(orders) == 0 {
+		return acmeserverless.Order{}, ErrNotFound
+	}
+
+	return orders[0], nil
+}
+
+func (r *sqlOrderRepository) List(ctx context.Context) (acmeserverless.Orders, error) {
+	return r.query(ctx, `SELECT `+orderColumns+` FROM orders ORDER BY id`)
+}
+
+func (r *sqlOrderRepository) Put(ctx context.Context, o acmeserverless.Order) error {
+	a := o.Address
+	if a == nil {
+		a = &acmeserverless.Address{}
+	}
+
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, r.s.rebind(`INSERT INTO orders (`+orderColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET status = excluded.status, user_id = excluded.user_id,
+			firstname = excluded.firstname, lastname = excluded.lastname, email = excluded.email,
+			has_address = excluded.has_address, street = excluded.street, city = excluded.city, zip = excluded.zip,
+			state = excluded.state, country = excluded.country, delivery = excluded.delivery,
+			card_type = excluded.card_type, card_number = excluded.card_number,
+			card_expiry_month = excluded.card_expiry_month, card_expiry_year = excluded.card_expiry_year,
+			card_cvv = excluded.card_cvv, total = excluded.total`),
+			o.OrderID, nullString(o.Status), o.UserID, nullString(o.Firstname), nullString(o.Lastname), nullString(o.Email),
+			o.Address != nil, nullString(a.Street), nullString(a.City), nullString(a.Zip), nullString(a.State), nullString(a.Country),
+			o.Delivery, o.Card.Type, o.Card.Number, o.Card.ExpiryMonth, o.Card.ExpiryYear, o.Card.CVV, o.Total)
+		if err != nil {
+			return err
+		}
+
+		return r.s.putLines(ctx, tx, "order_lines", "order_id", o.OrderID, o.Cart)
+	})
+}
+
+func (r *sqlOrderRepository) Delete(ctx context.Context, id string) error {
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM order_lines WHERE order_id = ?`), id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM orders WHERE id = ?`), id)
+		return err
+	})
+}
+
+func (r *sqlOrderRepository) ListByUser(ctx context.Context, userID string) (acmeserverless.Orders, error) {
+	return r.query(ctx, `SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (r *sqlOrderRepository) query(ctx context.Context, query string, args ...interface{}) (acmeserverless.Orders, error) {
+	rows, err := r.s.db.QueryContext(ctx, r.s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make(acmeserverless.Orders, 0)
+	for rows.Next() {
+		var o acmeserverless.Order
+		var status, firstname, lastname, email, street, city, zip, state, country sql.NullString
+		var hasAddress bool
+		var card creditcard.Card
+
+		err := rows.Scan(&o.OrderID, &status, &o.UserID, &firstname, &lastname, &email, &hasAddress, &street, &city,
+			&zip, &state, &country, &o.Delivery, &card.Type, &card.Number, &card.ExpiryMonth, &card.ExpiryYear, &card.CVV, &o.Total)
+		if err != nil {
+			return nil, err
+		}
+
+		o.Status = stringPtr(status)
+		o.Firstname = stringPtr(firstname)
+		o.Lastname = stringPtr(lastname)
+		o.Email = stringPtr(email)
+		o.Card = card
+		if hasAddress {
+			o.Address = &acmeserverless.Address{
+				Street:  stringPtr(street),
+				City:    stringPtr(city),
+				Zip:     stringPtr(zip),
+				State:   stringPtr(state),
+				Country: stringPtr(country),
+			}
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderID
+	}
+
+	lines, err := r.s.lines(ctx, "order_lines", "order_id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].Cart = lines[orders[i].OrderID]
+	}
+
+	return orders, nil
+}
+
+type sqlCartRepository struct {
+	s *SQLStore
+}
+
+func (r *sqlCartRepository) Get(ctx context.Context, userID string) (acmeserverless.CartItems, error) {
+	var id string
+	err := r.s.db.QueryRowContext(ctx, r.s.rebind(`SELECT user_id FROM carts WHERE user_id = ?`), userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := r.s.lines(ctx, "cart_lines", "user_id", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return lines[userID], nil
+}
+
+func (r *sqlCartRepository) List(ctx context.Context) (acmeserverless.Carts, error) {
+	rows, err := r.s.db.QueryContext(ctx, `SELECT user_id FROM carts ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	carts := make(acmeserverless.Carts, 0)
+	for rows.Next() {
+		var c acmeserverless.Cart
+		if err := rows.Scan(&c.UserID); err != nil {
+			return nil, err
+		}
+		carts = append(carts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(carts))
+	for i, c := range carts {
+		ids[i] = c.UserID
+	}
+
+	lines, err := r.s.lines(ctx, "cart_lines", "user_id", ids...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range carts {
+		carts[i].Items = lines[carts[i].UserID]
+	}
+
+	return carts, nil
+}
+
+func (r *sqlCartRepository) Put(ctx context.Context, userID string, items acmeserverless.CartItems) error {
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, r.s.rebind(`INSERT INTO carts (user_id, version) VALUES (?, 1) ON CONFLICT (user_id) DO UPDATE SET version = carts.version + 1`), userID)
+		if err != nil {
+			return err
+		}
+
+		return r.s.putLines(ctx, tx, "cart_lines", "user_id", userID, items)
+	})
+}
+
+// Update uses the version of the cart, which is incremented by every write. The new items are only
+// written when the version is still the one that was read.
+func (r *sqlCartRepository) Update(ctx context.Context, userID string, fn func(items acmeserverless.CartItems) (acmeserverless.CartItems, error)) (acmeserverless.CartItems, error) {
+	var updated acmeserverless.CartItems
+
+	err := RetryOnConflict(ctx, DefaultAttempts, func() error {
+		var version int64
+		err := r.s.db.QueryRowContext(ctx, r.s.rebind(`SELECT version FROM carts WHERE user_id = ?`), userID).Scan(&version)
+		exists := err == nil
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		var items acmeserverless.CartItems
+		if exists {
+			lines, err := r.s.lines(ctx, "cart_lines", "user_id", userID)
+			if err != nil {
+				return err
+			}
+			items = lines[userID]
+		}
+
+		items, err = fn(items)
+		if err != nil {
+			return err
+		}
+
+		return r.s.tx(ctx, func(tx *sql.Tx) error {
+			var res sql.Result
+			if exists {
+				res, err = tx.ExecContext(ctx, r.s.rebind(`UPDATE carts SET version = version + 1 WHERE user_id = ? AND version = ?`), userID, version)
+			} else {
+				res, err = tx.ExecContext(ctx, r.s.rebind(`INSERT INTO carts (user_id, version) VALUES (?, 1) ON CONFLICT (user_id) DO NOTHING`), userID)
+			}
+			if err != nil {
+				return err
+			}
+
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				return &ConflictError{PK: PartitionCart, SK: userID, Version: version}
+			}
+
+			updated = items
+			return r.s.putLines(ctx, tx, "cart_lines", "user_id", userID, items)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (r *sqlCartRepository) Delete(ctx context.Context, userID string) error {
+	return r.s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM cart_lines WHERE user_id = ?`), userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, r.s.rebind(`DELETE FROM carts WHERE user_id = ?`), userID)
+		return err
+	})
+}
+
+// putLines replaces the lines of an order or cart with the given items.
+func (s *SQLStore) putLines(ctx context.Context, tx *sql.Tx, table string, owner string, id string, items []acmeserverless.CartItem) error {
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM `+table+` WHERE `+owner+` = ?`), id); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO `+table+` (`+owner+`, line, item_id, product_id, name, description, price, quantity)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			id, i, nullString(item.ItemID), nullString(item.ID), item.Name, item.Description, item.Price, item.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lines returns the lines of the orders or carts with the given IDs, by ID. An order or cart without
+// lines has an empty list of lines.
+func (s *SQLStore) lines(ctx context.Context, table string, owner string, ids ...string) (map[string][]acmeserverless.CartItem, error) {
+	lines := make(map[string][]acmeserverless.CartItem, len(ids))
+	for _, id := range ids {
+		lines[id] = make([]acmeserverless.CartItem, 0)
+	}
+
+	err := s.inBatches(ids, func(batch []string, args []interface{}) error {
+		rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT `+owner+`, item_id, product_id, name, description, price, quantity
+			FROM `+table+` WHERE `+owner+` IN (`+placeholders(len(batch))+`) ORDER BY `+owner+`, line`), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			var item acmeserverless.CartItem
+			var itemID, productID sql.NullString
+			if err := rows.Scan(&id, &itemID, &productID, &item.Name, &item.Description, &item.Price, &item.Quantity); err != nil {
+				return err
+			}
+			item.ItemID = stringPtr(itemID)
+			item.ID = stringPtr(productID)
+			lines[id] = append(lines[id], item)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// maxParams is the number of IDs in a single IN clause, which stays below the limit on the number
+// of parameters of a query of SQLite and PostgreSQL.
+const maxParams = 500
+
+// inBatches calls fn for batches of at most maxParams IDs, with the IDs as the arguments of the query.
+func (s *SQLStore) inBatches(ids []string, fn func(batch []string, args []interface{}) error) error {
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > maxParams {
+			n = maxParams
+		}
+
+		batch := ids[:n]
+		args := make([]interface{}, n)
+		for i, id := range batch {
+			args[i] = id
+		}
+		if err := fn(batch, args); err != nil {
+			return err
+		}
+
+		ids = ids[n:]
+	}
+
+	return nil
+}
+
+// placeholders returns n comma separated ? placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nullString converts an optional string to a value that can be stored in a nullable column.
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// stringPtr converts the value of a nullable column to an optional string.
+func stringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,86 @@
+package datastore
+
+// migrations contains the schema of the SQL datastore. Each migration is applied once, in order,
+// and recorded in the schema_migrations table. Existing migrations must never change, new changes
+// to the schema are added as a new migration at the end.
+var migrations = [][]string{
+	// 1: users, catalog, orders with normalised order lines, and carts
+	{
+		`CREATE TABLE users (
+			id TEXT PRIMARY KEY,
+			username TEXT NOT NULL,
+			password TEXT NOT NULL,
+			firstname TEXT NOT NULL,
+			lastname TEXT NOT NULL,
+			email TEXT NOT NULL
+		)`,
+		`CREATE INDEX users_username ON users (username)`,
+		`CREATE TABLE products (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			short_description TEXT NOT NULL,
+			description TEXT NOT NULL,
+			image_url1 TEXT NOT NULL,
+			image_url2 TEXT NOT NULL,
+			image_url3 TEXT NOT NULL,
+			price REAL NOT NULL
+		)`,
+		`CREATE TABLE product_tags (
+			product_id TEXT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (product_id, position)
+		)`,
+		`CREATE TABLE orders (
+			id TEXT PRIMARY KEY,
+			status TEXT,
+			user_id TEXT NOT NULL,
+			firstname TEXT,
+			lastname TEXT,
+			email TEXT,
+			has_address BOOLEAN NOT NULL,
+			street TEXT,
+			city TEXT,
+			zip TEXT,
+			state TEXT,
+			country TEXT,
+			delivery TEXT NOT NULL,
+			card_type TEXT NOT NULL,
+			card_number TEXT NOT NULL,
+			card_expiry_month INTEGER NOT NULL,
+			card_expiry_year INTEGER NOT NULL,
+			card_cvv TEXT NOT NULL,
+			total TEXT NOT NULL
+		)`,
+		`CREATE INDEX orders_user_id ON orders (user_id)`,
+		`CREATE TABLE order_lines (
+			order_id TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+			line INTEGER NOT NULL,
+			item_id TEXT,
+			product_id TEXT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL,
+			price REAL NOT NULL,
+			quantity INTEGER NOT NULL,
+			PRIMARY KEY (order_id, line)
+		)`,
+		`CREATE TABLE carts (
+			user_id TEXT PRIMARY KEY
+		)`,
+		`CREATE TABLE cart_lines (
+			user_id TEXT NOT NULL REFERENCES carts (user_id) ON DELETE CASCADE,
+			line INTEGER NOT NULL,
+			item_id TEXT,
+			product_id TEXT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL,
+			price REAL NOT NULL,
+			quantity INTEGER NOT NULL,
+			PRIMARY KEY (user_id, line)
+		)`,
+	},
+	// 2: the version of carts, for optimistic concurrency control
+	{
+		`ALTER TABLE carts ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+	},
+}
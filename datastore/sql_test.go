@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func newTestSQLStore(t *testing.T) *SQLStore {
+	t.Helper()
+
+	s, err := OpenSQL(context.Background(), SQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("error opening database: %s", err.Error())
+	}
+	return s
+}
+
+func TestSQLRepositories(t *testing.T) {
+	s := newTestSQLStore(t)
+	defer s.Close()
+
+	testRepositories(t, s.Repositories())
+}
+
+func TestSQLMigrate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLStore(t)
+	defer s.Close()
+
+	// Migrations that were applied by OpenSQL aren't applied again
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("error migrating an up to date database: %s", err.Error())
+	}
+
+	var version, applied int
+	if err := s.DB().QueryRowContext(ctx, `SELECT MAX(version), COUNT(*) FROM schema_migrations`).Scan(&version, &applied); err != nil {
+		t.Fatalf("error reading schema version: %s", err.Error())
+	}
+	if version != len(migrations) || applied != len(migrations) {
+		t.Fatalf("schema is at version %d with %d migrations applied, want %d", version, applied, len(migrations))
+	}
+}
+
+func TestSQLOrderLines(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLStore(t)
+	defer s.Close()
+
+	orders := s.Repositories().Orders
+	order := acmeserverless.Order{
+		OrderID:  "order-1",
+		UserID:   "user-1",
+		Delivery: "UPS/Fedex",
+		Cart: []acmeserverless.CartItem{
+			{ItemID: str("product-2"), Name: "Water bottle", Description: "Bottle", Price: 10, Quantity: 1},
+			{ItemID: str("product-1"), Name: "Yoga mat", Description: "Mat", Price: 62.5, Quantity: 2},
+			{ID: str("product-3"), Name: "Yoga block", Description: "Block", Price: 12, Quantity: 3},
+		},
+		Total: "171",
+	}
+	if err := orders.Put(ctx, order); err != nil {
+		t.Fatalf("error storing order: %s", err.Error())
+	}
+
+	lines := func() int {
+		t.Helper()
+		var n int
+		if err := s.DB().QueryRowContext(ctx, `SELECT COUNT(*) FROM order_lines WHERE order_id = ?`, "order-1").Scan(&n); err != nil {
+			t.Fatalf("error counting order lines: %s", err.Error())
+		}
+		return n
+	}
+
+	// Each item is a line, and the lines are read in the order of the cart
+	if n := lines(); n != 3 {
+		t.Fatalf("order has %d lines, want 3", n)
+	}
+	got, err := orders.Get(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("error reading order: %s", err.Error())
+	}
+	for i, item := range got.Cart {
+		if item.Name != order.Cart[i].Name || item.Quantity != order.Cart[i].Quantity {
+			t.Fatalf("line %d is %+v, want %+v", i, item, order.Cart[i])
+		}
+	}
+	if got.Cart[2].ID == nil || *got.Cart[2].ID != "product-3" || got.Cart[2].ItemID != nil {
+		t.Fatalf("line 2 is %+v, want the id without an itemid", got.Cart[2])
+	}
+
+	// Replacing an order replaces its lines, and removing it removes them
+	order.Cart = order.Cart[:1]
+	if err := orders.Put(ctx, order); err != nil {
+		t.Fatalf("error replacing order: %s", err.Error())
+	}
+	if n := lines(); n != 1 {
+		t.Fatalf("order has %d lines after it was replaced, want 1", n)
+	}
+
+	if err := orders.Delete(ctx, "order-1"); err != nil {
+		t.Fatalf("error removing order: %s", err.Error())
+	}
+	if n := lines(); n != 0 {
+		t.Fatalf("removed order has %d lines, want none", n)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/datastore/internal/target"
+	"github.com/retgits/acme-serverless/messaging"
+	"github.com/retgits/acme-serverless/outbox"
+)
+
+var (
+	publisher string
+	queue     string
+	bus       string
+	interval  time.Duration
+	config    target.Config
+)
+
+// logPublisher writes the messages to the log instead of sending them.
+type logPublisher struct{}
+
+func (logPublisher) Publish(ctx context.Context, m outbox.Message) (string, error) {
+	log.Printf("%s: %s", m.Name, string(m.Payload))
+	return m.ID, nil
+}
+
+func main() {
+	// Read flags
+	config.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&publisher, "publisher", "log", "Where to send CartAbandoned events: log, sqs, or eventbridge")
+	flag.StringVar(&queue, "queue", "", "The URL of the Amazon SQS queue (required for sqs)")
+	flag.StringVar(&bus, "bus", "default", "The name of the Amazon EventBridge event bus (optional for eventbridge)")
+	flag.DurationVar(&interval, "interval", 0, "How often to sweep, like 15m (optional, defaults to a single sweep)")
+	flag.Parse()
+
+	p, err := newPublisher()
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	ctx := context.Background()
+
+	// Initialize the database connection
+	t, err := target.Open(ctx, config)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+	defer t.Close()
+
+	if t.Store == nil {
+		t.Close()
+		log.Fatalf("Error: the %s target doesn't store carts with a TTL", config.Target)
+	}
+
+	sweeper := datastore.NewCartSweeper(t.Store, publishWith(p))
+	for {
+		res, err := sweeper.Sweep(ctx)
+		if err != nil {
+			t.Close()
+			log.Fatalf("Error: %s", err.Error())
+		}
+		log.Printf("removed %d abandoned and %d empty carts, kept %d carts that changed during the sweep", res.Abandoned, res.Empty, res.Changed)
+
+		if interval <= 0 {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// publishWith sends the CartAbandoned events of the sweeper as messages of p.
+func publishWith(p outbox.Publisher) datastore.CartAbandonedPublisher {
+	return func(ctx context.Context, event acmeserverless.CartAbandoned) error {
+		m, err := outbox.NewMessage(acmeserverless.CartAbandonedEventName, event.Metadata, event)
+		if err != nil {
+			return err
+		}
+		_, err = p.Publish(ctx, m)
+		return err
+	}
+}
+
+// newPublisher creates the publisher selected with the publisher flag.
+func newPublisher() (outbox.Publisher, error) {
+	switch publisher {
+	case "log":
+		return logPublisher{}, nil
+	case messaging.SQS, messaging.EventBridge:
+		if publisher == messaging.SQS && len(queue) == 0 {
+			return nil, fmt.Errorf("the 'queue' flag must be set")
+		}
+		p, err := messaging.NewPublisher(messaging.Config{
+			Transport: publisher,
+			Region:    config.Region,
+			Queue:     queue,
+			Bus:       bus,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewMessagingPublisher(p), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher %q, must be one of log, sqs, or eventbridge", publisher)
+	}
+}
@@ -0,0 +1,276 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// ValidationError is a problem in a seed data file.
+type ValidationError struct {
+	// File is the name of the file.
+	File string
+
+	// Index is the position of the record in the file, or -1 when the problem is with the file.
+	Index int
+
+	// ID is the ID of the record, if it has one.
+	ID string
+
+	// Message describes the problem.
+	Message string
+
+	// Warning is set for problems that don't stop the record from being written, like an order of a
+	// user that isn't in the user data.
+	Warning bool
+}
+
+func (e ValidationError) Error() string {
+	if e.Warning {
+		e.Warning = false
+		return "warning: " + e.Error()
+	}
+
+	switch {
+	case e.Index < 0:
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	case len(e.ID) > 0:
+		return fmt.Sprintf("%s[%d] (%s): %s", e.File, e.Index, e.ID, e.Message)
+	default:
+		return fmt.Sprintf("%s[%d]: %s", e.File, e.Index, e.Message)
+	}
+}
+
+// ValidateSeedData strictly reads the files of the selected entities from dir. Unlike LoadSeedData
+// every record is checked on its own, so all problems are reported at once. It reports fields that
+// don't exist in the types of the shop or have the wrong type, records without an ID or with an ID
+// that is used by an earlier record, quantities that aren't positive, and negative prices. When the
+// users and catalog are read as well, it also reports orders and carts of users that don't exist and
+// items that aren't in the catalog, as warnings.
+//
+// It returns the records without problems, which includes records with only warnings, and the
+// problems that were found.
+func ValidateSeedData(dir string, entities []string) (*SeedData, []ValidationError) {
+	data := &SeedData{}
+	var errs []ValidationError
+	var loaded []string
+
+	// pos contains the position in the file of each record in data, as records with problems are
+	// left out
+	pos := make(map[string][]int)
+
+	for _, e := range entities {
+		file, ok := SeedFiles[e]
+		if !ok {
+			errs = append(errs, ValidationError{File: e, Index: -1, Message: "unknown entity"})
+			continue
+		}
+
+		recs, err := readRecords(filepath.Join(dir, file))
+		if err != nil {
+			errs = append(errs, ValidationError{File: file, Index: -1, Message: err.Error()})
+			continue
+		}
+		loaded = append(loaded, e)
+
+		// Every check adds its problems to the validator, a record is only kept when none were added
+		v := validator{file: file, seen: make(map[string]bool)}
+		for i, raw := range recs {
+			n := len(v.errs)
+			switch e {
+			case EntityUsers:
+				var usr acmeserverless.User
+				if v.decode(i, raw, &usr) && v.id(i, usr.ID) {
+					data.Users = append(data.Users, usr)
+				}
+			case EntityCatalog:
+				var product acmeserverless.CatalogItem
+				if v.decode(i, raw, &product) && v.id(i, product.ID) {
+					if product.Price < 0 {
+						v.add(i, product.ID, "price must not be negative")
+					}
+					if len(v.errs) == n {
+						data.Catalog = append(data.Catalog, product)
+					}
+				}
+			case EntityOrders:
+				var ord acmeserverless.Order
+				if v.decode(i, raw, &ord) && v.id(i, ord.OrderID) {
+					v.items(i, ord.OrderID, ord.Cart)
+					if len(v.errs) == n {
+						data.Orders = append(data.Orders, ord)
+					}
+				}
+			case EntityCarts:
+				var crt acmeserverless.Cart
+				if v.decode(i, raw, &crt) && v.id(i, crt.UserID) {
+					v.items(i, crt.UserID, crt.Items)
+					if len(v.errs) == n {
+						data.Carts = append(data.Carts, crt)
+					}
+				}
+			}
+			if len(v.errs) == n {
+				pos[e] = append(pos[e], i)
+			}
+		}
+		errs = append(errs, v.errs...)
+	}
+
+	errs = append(errs, checkReferences(data, loaded, pos)...)
+
+	return data, errs
+}
+
+// readRecords reads a file that contains a JSON array, without decoding the records.
+func readRecords(file string) ([]json.RawMessage, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %s", err.Error())
+	}
+
+	var recs []json.RawMessage
+	if err := json.Unmarshal(b, &recs); err != nil {
+		return nil, fmt.Errorf("the file must contain a JSON array: %s", jsonMessage(err))
+	}
+
+	return recs, nil
+}
+
+// validator collects the problems of the records in a single file.
+type validator struct {
+	file string
+	seen map[string]bool
+	errs []ValidationError
+}
+
+func (v *validator) add(index int, id string, format string, a ...interface{}) {
+	v.errs = append(v.errs, ValidationError{File: v.file, Index: index, ID: id, Message: fmt.Sprintf(format, a...)})
+}
+
+// decode strictly decodes the record and returns whether that succeeded.
+func (v *validator) decode(index int, raw json.RawMessage, out interface{}) bool {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		v.add(index, rawID(raw), "%s", jsonMessage(err))
+		return false
+	}
+	return true
+}
+
+// id checks that the ID is set and unique, and returns whether it is.
+func (v *validator) id(index int, id string) bool {
+	if len(id) == 0 {
+		v.add(index, "", "the record has no ID")
+		return false
+	}
+
+	if v.seen[id] {
+		v.add(index, id, "the ID is used by an earlier record")
+		return false
+	}
+
+	v.seen[id] = true
+	return true
+}
+
+// items checks the quantity and price of the items.
+func (v *validator) items(index int, id string, items []acmeserverless.CartItem) {
+	for j, item := range items {
+		if item.Quantity <= 0 {
+			v.add(index, id, "item %d: quantity must be positive, got %d", j, item.Quantity)
+		}
+		if item.Price < 0 {
+			v.add(index, id, "item %d: price must not be negative", j)
+		}
+	}
+}
+
+// checkReferences checks that orders and carts refer to existing users and products, when those
+// entities were read. The data files of the shop are independent samples, so the problems it finds
+// are warnings.
+func checkReferences(data *SeedData, entities []string, pos map[string][]int) []ValidationError {
+	selected := make(map[string]bool)
+	for _, e := range entities {
+		selected[e] = true
+	}
+
+	users := make(map[string]bool)
+	for _, usr := range data.Users {
+		users[usr.ID] = true
+	}
+
+	products := make(map[string]bool)
+	for _, product := range data.Catalog {
+		products[product.ID] = true
+	}
+
+	var errs []ValidationError
+	add := func(entity string, i int, id string, format string, a ...interface{}) {
+		errs = append(errs, ValidationError{File: SeedFiles[entity], Index: pos[entity][i], ID: id, Message: fmt.Sprintf(format, a...), Warning: true})
+	}
+
+	// item checks a single item, which refers to a product using ItemID or ID
+	item := func(entity string, index int, id string, j int, it acmeserverless.CartItem) {
+		ref := it.ItemID
+		if ref == nil {
+			ref = it.ID
+		}
+		switch {
+		case ref == nil:
+			add(entity, index, id, "item %d has no ID", j)
+		case selected[EntityCatalog] && !products[*ref]:
+			add(entity, index, id, "item %d refers to product %s, which isn't in the catalog", j, *ref)
+		}
+	}
+
+	for i, ord := range data.Orders {
+		if selected[EntityUsers] && !users[ord.UserID] {
+			add(EntityOrders, i, ord.OrderID, "userid %q doesn't refer to a user", ord.UserID)
+		}
+		for j, it := range ord.Cart {
+			item(EntityOrders, i, ord.OrderID, j, it)
+		}
+	}
+
+	for i, crt := range data.Carts {
+		if selected[EntityUsers] && !users[crt.UserID] {
+			add(EntityCarts, i, crt.UserID, "userid %q doesn't refer to a user", crt.UserID)
+		}
+		for j, it := range crt.Items {
+			item(EntityCarts, i, crt.UserID, j, it)
+		}
+	}
+
+	return errs
+}
+
+// rawID returns the ID of a record that couldn't be decoded, if it can be found.
+func rawID(raw json.RawMessage) string {
+	var ids struct {
+		ID      string `json:"id"`
+		OrderID string `json:"_id"`
+		UserID  string `json:"userid"`
+	}
+	json.Unmarshal(raw, &ids)
+
+	switch {
+	case len(ids.ID) > 0:
+		return ids.ID
+	case len(ids.OrderID) > 0:
+		return ids.OrderID
+	default:
+		return ids.UserID
+	}
+}
+
+// jsonMessage removes the json: prefix from errors of the encoding/json package.
+func jsonMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
@@ -0,0 +1,71 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// DefaultAttempts is the number of times RetryOnConflict calls its function when attempts isn't
+// positive.
+const DefaultAttempts = 5
+
+// RetryOnConflict calls fn until it returns an error that isn't a *ConflictError, or until it was
+// called attempts times. Between attempts it waits for a random, growing, backoff, so writers that
+// conflict don't keep running into each other. It returns the last error of fn.
+func RetryOnConflict(ctx context.Context, attempts int, fn func() error) error {
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+
+	backoff := 10 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsConflict(err) || attempt == attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff/2 + time.Duration(rand.Int63n(int64(backoff)))):
+		}
+		backoff *= 2
+	}
+}
+
+// UpdateRecord reads the record, lets fn change it, and writes it with PutIfVersion. When the record
+// was changed by someone else in the meantime, the record is read again and fn is called again with
+// the latest version, so fn must not have side effects. When the record doesn't exist, fn is called
+// with a record that only has PK and SK and exists set to false. UpdateRecord returns the record that
+// was written.
+func UpdateRecord(ctx context.Context, s Store, pk string, sk string, fn func(rec *Record, exists bool) error) (Record, error) {
+	vs, ok := s.(VersionedStore)
+	if !ok {
+		return Record{}, fmt.Errorf("datastore: %T doesn't support versioned writes", s)
+	}
+
+	var written Record
+	err := RetryOnConflict(ctx, DefaultAttempts, func() error {
+		rec, err := s.Get(ctx, pk, sk)
+		exists := err == nil
+		if err == ErrNotFound {
+			rec = Record{PK: pk, SK: sk}
+		} else if err != nil {
+			return err
+		}
+
+		if err := fn(&rec, exists); err != nil {
+			return err
+		}
+
+		// fn may change the data, but not which record is written or the version it was based on
+		rec.PK, rec.SK = pk, sk
+		written, err = vs.PutIfVersion(ctx, rec)
+		return err
+	})
+
+	return written, err
+}
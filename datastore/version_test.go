@@ -0,0 +1,115 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPutIfVersion(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	created, err := s.PutIfVersion(ctx, Record{PK: PartitionCart, SK: "user-1", Payload: `[]`})
+	if err != nil || created.Version != 1 {
+		t.Fatalf("created version %d (error %v), want version 1", created.Version, err)
+	}
+
+	tests := []struct {
+		name     string
+		version  int64
+		conflict bool
+	}{
+		{"create an existing record", 0, true},
+		{"write an old version", 1, false},
+		{"write the same old version again", 1, true},
+		{"write a version that doesn't exist yet", 5, true},
+		{"write the current version", 2, false},
+	}
+
+	for _, tt := range tests {
+		_, err := s.PutIfVersion(ctx, Record{PK: PartitionCart, SK: "user-1", Payload: `[]`, Version: tt.version})
+		if IsConflict(err) != tt.conflict {
+			t.Errorf("%s: error is %v, want a conflict %t", tt.name, err, tt.conflict)
+		}
+	}
+
+	if rec, _ := s.Get(ctx, PartitionCart, "user-1"); rec.Version != 3 {
+		t.Fatalf("record has version %d, want 3 after two successful writes", rec.Version)
+	}
+
+	if err := s.DeleteIfVersion(ctx, PartitionCart, "user-1", 2); !IsConflict(err) {
+		t.Fatalf("error deleting an old version is %v, want a conflict", err)
+	}
+	if err := s.DeleteIfVersion(ctx, PartitionCart, "user-1", 3); err != nil {
+		t.Fatalf("error deleting the current version: %s", err.Error())
+	}
+	if err := s.DeleteIfVersion(ctx, PartitionCart, "user-1", 3); !IsConflict(err) {
+		t.Fatalf("error deleting a removed record is %v, want a conflict", err)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	conflict := &ConflictError{PK: PartitionCart, SK: "user-1", Version: 1}
+	failed := errors.New("table not found")
+
+	tests := []struct {
+		name     string
+		attempts int
+		errs     []error
+		calls    int
+		err      error
+	}{
+		{"no conflict", 3, []error{nil}, 1, nil},
+		{"conflict then success", 3, []error{conflict, conflict, nil}, 3, nil},
+		{"wrapped conflict", 3, []error{fmt.Errorf("error updating cart: %w", conflict), nil}, 2, nil},
+		{"conflict on every attempt", 3, []error{conflict, conflict, conflict, nil}, 3, conflict},
+		{"other error", 3, []error{failed, nil}, 1, failed},
+		{"default attempts", 0, []error{conflict, conflict, conflict, conflict, conflict, nil}, DefaultAttempts, conflict},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		err := RetryOnConflict(context.Background(), tt.attempts, func() error {
+			calls++
+			return tt.errs[calls-1]
+		})
+		if err != tt.err {
+			t.Errorf("%s: error is %v, want %v", tt.name, err, tt.err)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: called %d times, want %d", tt.name, calls, tt.calls)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RetryOnConflict(ctx, 3, func() error { return conflict })
+	if err != context.Canceled {
+		t.Fatalf("error is %v, want context.Canceled", err)
+	}
+}
+
+func TestUpdateRecordRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	s.PutIfVersion(ctx, Record{PK: PartitionCart, SK: "user-1", Payload: "a"})
+
+	// Another writer changes the record while fn runs the first time
+	calls := 0
+	written, err := UpdateRecord(ctx, s, PartitionCart, "user-1", func(rec *Record, exists bool) error {
+		calls++
+		if calls == 1 {
+			s.PutIfVersion(ctx, Record{PK: PartitionCart, SK: "user-1", Payload: rec.Payload + "b", Version: rec.Version})
+		}
+		rec.Payload += "c"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error updating record: %s", err.Error())
+	}
+	if calls != 2 || written.Payload != "abc" || written.Version != 3 {
+		t.Fatalf("wrote %q with version %d after %d calls, want abc with version 3 after 2 calls", written.Payload, written.Version, calls)
+	}
+}
@@ -6,6 +6,8 @@ require (
 	github.com/aws/aws-sdk-go v1.30.7
 	github.com/fatih/structs v1.1.0
 	github.com/gofrs/uuid v3.2.0+incompatible
+	github.com/lib/pq v1.4.0
+	github.com/mattn/go-sqlite3 v1.14.15
 	github.com/pulumi/pulumi-aws/sdk v1.31.0
 	github.com/pulumi/pulumi/sdk v1.14.1
 	github.com/retgits/creditcard v0.6.0
@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DynamoDBStore is a Store that keeps records in the Amazon DynamoDB table of the shop, using the
+// access pattern PK = IDEMPOTENCY SK = Key. The expiry is stored as epoch seconds in the TTL
+// attribute, so DynamoDB can remove expired records when TTL is enabled on the table.
+type DynamoDBStore struct {
+	dbs   *dynamodb.DynamoDB
+	table string
+}
+
+// NewDynamoDBStore creates a DynamoDBStore that uses the given table.
+func NewDynamoDBStore(dbs *dynamodb.DynamoDB, table string) *DynamoDBStore {
+	return &DynamoDBStore{
+		dbs:   dbs,
+		table: table,
+	}
+}
+
+// Claim marks the event as in progress until expiresAt, unless a record that has not expired exists.
+func (d *DynamoDBStore) Claim(ctx context.Context, key string, expiresAt time.Time) (*Record, error) {
+	item := d.keys(key)
+	item["Status"] = &dynamodb.AttributeValue{
+		S: aws.String(StatusInProgress),
+	}
+	item["TTL"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10)),
+	}
+
+	// Expired records are not always removed by DynamoDB right away, so they can be claimed too
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":now"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(time.Now().Unix(), 10)),
+	}
+
+	_, err := d.dbs.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(d.table),
+		Item:                      item,
+		ConditionExpression:       aws.String("attribute_not_exists(PK) OR #ttl < :now"),
+		ExpressionAttributeNames:  map[string]*string{"#ttl": aws.String("TTL")},
+		ExpressionAttributeValues: em,
+	})
+	if err == nil {
+		return nil, nil
+	}
+
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil, err
+	}
+
+	gio, err := d.dbs.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            d.keys(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The record was released between the put and the get, so another delivery is still
+	// handling the event
+	if gio.Item == nil {
+		return &Record{Key: key, Status: StatusInProgress}, nil
+	}
+
+	rec := &Record{
+		Key:    key,
+		Status: aws.StringValue(gio.Item["Status"].S),
+	}
+	if v, ok := gio.Item["Payload"]; ok {
+		rec.Result = []byte(aws.StringValue(v.S))
+	}
+	if v, ok := gio.Item["TTL"]; ok {
+		ttl, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		rec.ExpiresAt = time.Unix(ttl, 0)
+	}
+
+	return rec, nil
+}
+
+// Complete stores the result of the event until expiresAt, when the claim that expires at
+// claimedUntil is still held.
+func (d *DynamoDBStore) Complete(ctx context.Context, key string, claimedUntil time.Time, result []byte, expiresAt time.Time) error {
+	item := d.keys(key)
+	item["Status"] = &dynamodb.AttributeValue{
+		S: aws.String(StatusCompleted),
+	}
+	item["TTL"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10)),
+	}
+	// DynamoDB doesn't allow empty strings, so an empty result is stored without Payload
+	if len(result) > 0 {
+		item["Payload"] = &dynamodb.AttributeValue{
+			S: aws.String(string(result)),
+		}
+	}
+
+	cond, en, em := d.claimed(claimedUntil)
+	_, err := d.dbs.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(d.table),
+		Item:                      item,
+		ConditionExpression:       cond,
+		ExpressionAttributeNames:  en,
+		ExpressionAttributeValues: em,
+	})
+
+	return claimErr(err)
+}
+
+// Release removes the claim that expires at claimedUntil, so the event can be processed again.
+func (d *DynamoDBStore) Release(ctx context.Context, key string, claimedUntil time.Time) error {
+	cond, en, em := d.claimed(claimedUntil)
+	_, err := d.dbs.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       d.keys(key),
+		ConditionExpression:       cond,
+		ExpressionAttributeNames:  en,
+		ExpressionAttributeValues: em,
+	})
+
+	return claimErr(err)
+}
+
+// claimed returns the condition that the record is still the claim that expires at claimedUntil.
+func (d *DynamoDBStore) claimed(claimedUntil time.Time) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":inprogress"] = &dynamodb.AttributeValue{
+		S: aws.String(StatusInProgress),
+	}
+	em[":claim"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(claimedUntil.Unix(), 10)),
+	}
+
+	en := map[string]*string{"#status": aws.String("Status"), "#ttl": aws.String("TTL")}
+	return aws.String("#status = :inprogress AND #ttl = :claim"), en, em
+}
+
+// claimErr converts a failed condition on the claim into ErrClaimLost.
+func claimErr(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrClaimLost
+	}
+	return err
+}
+
+// keys creates a map of DynamoDB Attribute Values containing the table keys
+func (d *DynamoDBStore) keys(key string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("IDEMPOTENCY"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(key),
+	}
+	return km
+}
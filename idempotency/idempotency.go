@@ -0,0 +1,169 @@
+// Package idempotency makes event handlers of the ACME Serverless Fitness Shop safe to run more than
+// once for the same event. Amazon SQS delivers messages at least once, so without deduplication a
+// duplicate PaymentRequestedEvent would charge a creditcard twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// StatusInProgress means a handler is processing the event.
+	StatusInProgress = "INPROGRESS"
+
+	// StatusCompleted means a handler has processed the event and the result is stored.
+	StatusCompleted = "COMPLETED"
+
+	// DefaultTTL is how long the result of an event is remembered when no TTL is set.
+	DefaultTTL = 24 * time.Hour
+
+	// DefaultLockTimeout is how long an in progress event blocks other deliveries of the same
+	// event when no LockTimeout is set.
+	DefaultLockTimeout = 5 * time.Minute
+)
+
+// ErrInProgress is returned when another delivery of the same event is still being processed.
+var ErrInProgress = errors.New("idempotency: event is already being processed")
+
+// ErrClaimLost is returned by Complete and Release when the claim expired and the event was claimed
+// by another delivery, or the record was removed.
+var ErrClaimLost = errors.New("idempotency: claim on the event is no longer held")
+
+// Handler processes the payload of an event and returns the result.
+type Handler func(ctx context.Context, payload []byte) ([]byte, error)
+
+// KeyFunc derives the idempotency key from the payload of an event. Two deliveries with the same
+// key are considered the same event.
+type KeyFunc func(payload []byte) (string, error)
+
+// Record is the processing state of a single event.
+type Record struct {
+	// Key is the idempotency key of the event.
+	Key string
+
+	// Status is either StatusInProgress or StatusCompleted.
+	Status string
+
+	// Result is the result returned by the handler.
+	Result []byte
+
+	// ExpiresAt is the time after which the record is no longer used.
+	ExpiresAt time.Time
+}
+
+// Store records which events have been processed.
+type Store interface {
+	// Claim marks the event as in progress until expiresAt. When the key is already claimed or
+	// completed, and that record has not expired, Claim returns that record and doesn't change it.
+	// When the claim succeeds, Claim returns nil.
+	Claim(ctx context.Context, key string, expiresAt time.Time) (*Record, error)
+
+	// Complete stores the result of the event until expiresAt, when the claim that expires at
+	// claimedUntil is still held. Otherwise it returns ErrClaimLost and doesn't change the record.
+	Complete(ctx context.Context, key string, claimedUntil time.Time, result []byte, expiresAt time.Time) error
+
+	// Release removes the claim that expires at claimedUntil, so the event can be processed again.
+	// When that claim isn't held anymore it returns ErrClaimLost and doesn't change the record.
+	Release(ctx context.Context, key string, claimedUntil time.Time) error
+}
+
+// Options configure the behavior of Wrap.
+type Options struct {
+	// TTL is how long the result of an event is remembered. Defaults to DefaultTTL.
+	TTL time.Duration
+
+	// LockTimeout is how long a delivery that is in progress blocks other deliveries of the same
+	// event. It should be longer than the timeout of the function. Defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+}
+
+// Wrap returns a Handler that runs h at most once per key. The first delivery of an event runs h and
+// stores its result, later deliveries return the stored result without running h. When h returns an
+// error the claim is released so the event can be retried. A delivery that arrives while another one
+// is still in progress returns ErrInProgress. When the result can't be stored, the error is logged
+// and the result is returned anyway, as h has already processed the event; later deliveries return
+// ErrInProgress until the claim expires, after which h runs again.
+func Wrap(h Handler, store Store, key KeyFunc, opts Options) Handler {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	if opts.LockTimeout == 0 {
+		opts.LockTimeout = DefaultLockTimeout
+	}
+
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		k, err := key(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error deriving idempotency key: %s", err.Error())
+		}
+
+		// Stores keep the expiry in seconds, and the claim is identified by its expiry
+		claim := time.Now().Add(opts.LockTimeout).Truncate(time.Second)
+
+		rec, err := store.Claim(ctx, k, claim)
+		if err != nil {
+			return nil, fmt.Errorf("error claiming idempotency key: %s", err.Error())
+		}
+
+		if rec != nil {
+			if rec.Status == StatusCompleted {
+				return rec.Result, nil
+			}
+			return nil, ErrInProgress
+		}
+
+		result, err := h(ctx, payload)
+		if err != nil {
+			if rerr := store.Release(ctx, k, claim); rerr != nil {
+				return nil, fmt.Errorf("%s (error releasing idempotency key: %s)", err.Error(), rerr.Error())
+			}
+			return nil, err
+		}
+
+		if err := store.Complete(ctx, k, claim, result, time.Now().Add(opts.TTL)); err != nil {
+			log.Printf("error storing result for idempotency key %s: %s", k, err.Error())
+		}
+
+		return result, nil
+	}
+}
+
+// EventKey is a KeyFunc that combines the type of the event, taken from its metadata, with the
+// SHA-256 hash of the payload. It treats byte-for-byte identical deliveries as the same event.
+func EventKey(payload []byte) (string, error) {
+	var e struct {
+		Metadata acmeserverless.Metadata `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return fmt.Sprintf("%s#%s", e.Metadata.Type, hex.EncodeToString(sum[:])), nil
+}
+
+// PaymentRequestedKey is a KeyFunc for PaymentRequestedEvents. It uses the order ID, so an order can
+// only be charged once regardless of how often the payment is requested.
+func PaymentRequestedKey(payload []byte) (string, error) {
+	e, err := acmeserverless.UnmarshalPaymentRequestedEvent(payload)
+	if err != nil {
+		return "", err
+	}
+
+	if len(e.Data.OrderID) == 0 {
+		return "", errors.New("event has no orderID")
+	}
+
+	return fmt.Sprintf("%s#%s", acmeserverless.PaymentRequestedEventName, e.Data.OrderID), nil
+}
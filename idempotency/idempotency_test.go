@@ -0,0 +1,247 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func staticKey(payload []byte) (string, error) {
+	return "event-1", nil
+}
+
+func TestWrap(t *testing.T) {
+	errFailed := errors.New("handler failed")
+
+	tests := []struct {
+		name string
+		// setup runs against the store before the delivery
+		setup func(s *MemoryStore)
+		// handle is the result of the handler, which is called with the store
+		handle   func(s *MemoryStore) ([]byte, error)
+		result   string
+		err      error
+		calls    int
+		status   string
+		stored   string
+		noRecord bool
+	}{
+		{
+			name:   "first delivery",
+			handle: func(s *MemoryStore) ([]byte, error) { return []byte("charged"), nil },
+			result: "charged",
+			calls:  1,
+			status: StatusCompleted,
+			stored: "charged",
+		},
+		{
+			name: "completed event",
+			setup: func(s *MemoryStore) {
+				claim := time.Now().Add(time.Minute)
+				s.Claim(context.Background(), "event-1", claim)
+				s.Complete(context.Background(), "event-1", claim, []byte("cached"), time.Now().Add(time.Hour))
+			},
+			handle: func(s *MemoryStore) ([]byte, error) { return []byte("charged again"), nil },
+			result: "cached",
+			status: StatusCompleted,
+			stored: "cached",
+		},
+		{
+			name:     "handler error",
+			handle:   func(s *MemoryStore) ([]byte, error) { return nil, errFailed },
+			err:      errFailed,
+			calls:    1,
+			noRecord: true,
+		},
+		{
+			name: "claim held by another delivery",
+			setup: func(s *MemoryStore) {
+				s.Claim(context.Background(), "event-1", time.Now().Add(time.Minute))
+			},
+			handle: func(s *MemoryStore) ([]byte, error) { return []byte("charged"), nil },
+			err:    ErrInProgress,
+			status: StatusInProgress,
+		},
+		{
+			name: "expired claim",
+			setup: func(s *MemoryStore) {
+				s.Claim(context.Background(), "event-1", time.Now().Add(-time.Minute))
+			},
+			handle: func(s *MemoryStore) ([]byte, error) { return []byte("charged"), nil },
+			result: "charged",
+			calls:  1,
+			status: StatusCompleted,
+			stored: "charged",
+		},
+		{
+			// The result is returned even though it can't be stored, and the other claim is kept
+			name: "claim lost while processing",
+			handle: func(s *MemoryStore) ([]byte, error) {
+				s.WithClock(func() time.Time { return time.Now().Add(time.Hour) })
+				s.Claim(context.Background(), "event-1", time.Now().Add(2*time.Hour))
+				return []byte("charged"), nil
+			},
+			result: "charged",
+			calls:  1,
+			status: StatusInProgress,
+		},
+	}
+
+	for _, tt := range tests {
+		s := NewMemoryStore()
+		if tt.setup != nil {
+			tt.setup(s)
+		}
+
+		calls := 0
+		h := Wrap(func(ctx context.Context, payload []byte) ([]byte, error) {
+			calls++
+			return tt.handle(s)
+		}, s, staticKey, Options{LockTimeout: time.Minute})
+
+		result, err := h(context.Background(), []byte(`{}`))
+		if err != tt.err {
+			t.Errorf("%s: error is %v, want %v", tt.name, err, tt.err)
+		}
+		if string(result) != tt.result {
+			t.Errorf("%s: result is %q, want %q", tt.name, result, tt.result)
+		}
+		if calls != tt.calls {
+			t.Errorf("%s: handler ran %d times, want %d", tt.name, calls, tt.calls)
+		}
+
+		rec, ok := s.Get("event-1")
+		if tt.noRecord {
+			if ok {
+				t.Errorf("%s: record is %+v, want the claim released", tt.name, rec)
+			}
+			continue
+		}
+		if !ok || rec.Status != tt.status || string(rec.Result) != tt.stored {
+			t.Errorf("%s: record is %+v, want status %s with result %q", tt.name, rec, tt.status, tt.stored)
+		}
+	}
+}
+
+func TestWrapReplaysResult(t *testing.T) {
+	s := NewMemoryStore()
+
+	calls := 0
+	h := Wrap(func(ctx context.Context, payload []byte) ([]byte, error) {
+		calls++
+		return []byte("charged"), nil
+	}, s, staticKey, Options{})
+
+	for i := 0; i < 3; i++ {
+		result, err := h(context.Background(), []byte(`{}`))
+		if err != nil {
+			t.Fatalf("error handling delivery %d: %s", i+1, err.Error())
+		}
+		if string(result) != "charged" {
+			t.Fatalf("delivery %d returned %q, want the stored result", i+1, result)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestCompleteAndReleaseNeedTheClaim(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	claim := time.Now().Add(time.Minute).Truncate(time.Second)
+	if _, err := s.Claim(ctx, "event-1", claim); err != nil {
+		t.Fatalf("error claiming key: %s", err.Error())
+	}
+
+	other := claim.Add(time.Second)
+	if err := s.Complete(ctx, "event-1", other, []byte("charged"), time.Now().Add(time.Hour)); err != ErrClaimLost {
+		t.Fatalf("error completing another claim is %v, want ErrClaimLost", err)
+	}
+	if err := s.Release(ctx, "event-1", other); err != ErrClaimLost {
+		t.Fatalf("error releasing another claim is %v, want ErrClaimLost", err)
+	}
+	if err := s.Release(ctx, "event-2", claim); err != ErrClaimLost {
+		t.Fatalf("error releasing an unknown key is %v, want ErrClaimLost", err)
+	}
+
+	if err := s.Complete(ctx, "event-1", claim, []byte("charged"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("error completing claim: %s", err.Error())
+	}
+	if err := s.Release(ctx, "event-1", claim); err != ErrClaimLost {
+		t.Fatalf("error releasing a completed event is %v, want ErrClaimLost", err)
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	payload := `{"metadata":{"type":"PaymentRequested"},"data":{"orderID":"order-1"}}`
+
+	tests := []struct {
+		name    string
+		payload string
+		prefix  string
+		same    bool
+		err     bool
+	}{
+		{"identical delivery", payload, "PaymentRequested#", true, false},
+		{"other data", `{"metadata":{"type":"PaymentRequested"},"data":{"orderID":"order-2"}}`, "PaymentRequested#", false, false},
+		{"other formatting", `{"metadata": {"type":"PaymentRequested"},"data":{"orderID":"order-1"}}`, "PaymentRequested#", false, false},
+		{"invalid JSON", `{"metadata":`, "", false, true},
+	}
+
+	want, err := EventKey([]byte(payload))
+	if err != nil {
+		t.Fatalf("error deriving key: %s", err.Error())
+	}
+
+	for _, tt := range tests {
+		got, err := EventKey([]byte(tt.payload))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: key is %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error deriving key: %s", tt.name, err.Error())
+			continue
+		}
+		if !strings.HasPrefix(got, tt.prefix) || (got == want) != tt.same {
+			t.Errorf("%s: key is %q, want prefix %q and same key %t", tt.name, got, tt.prefix, tt.same)
+		}
+	}
+}
+
+func TestPaymentRequestedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		key     string
+		err     bool
+	}{
+		{"order", `{"metadata":{"type":"PaymentRequested"},"data":{"orderID":"order-1","total":"10"}}`, "PaymentRequestedEvent#order-1", false},
+		{"same order requested again", `{"metadata":{"type":"PaymentRequested","status":"retry"},"data":{"orderID":"order-1","total":"12"}}`, "PaymentRequestedEvent#order-1", false},
+		{"no order", `{"metadata":{"type":"PaymentRequested"},"data":{"total":"10"}}`, "", true},
+		{"invalid JSON", `{"data":`, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := PaymentRequestedKey([]byte(tt.payload))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: key is %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error deriving key: %s", tt.name, err.Error())
+			continue
+		}
+		if got != tt.key {
+			t.Errorf("%s: key is %q, want %q", tt.name, got, tt.key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps the records in memory. It is meant for tests and local
+// development, and is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+		now:     time.Now,
+	}
+}
+
+// WithClock sets the function the MemoryStore uses to check whether a record has expired.
+func (m *MemoryStore) WithClock(now func() time.Time) *MemoryStore {
+	m.now = now
+	return m
+}
+
+// Claim marks the event as in progress until expiresAt, unless an unexpired record exists.
+func (m *MemoryStore) Claim(ctx context.Context, key string, expiresAt time.Time) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok && !rec.ExpiresAt.Before(m.now()) {
+		return &rec, nil
+	}
+
+	m.records[key] = Record{
+		Key:       key,
+		Status:    StatusInProgress,
+		ExpiresAt: expiresAt,
+	}
+	return nil, nil
+}
+
+// Complete stores the result of the event until expiresAt, when the claim that expires at
+// claimedUntil is still held.
+func (m *MemoryStore) Complete(ctx context.Context, key string, claimedUntil time.Time, result []byte, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.claimed(key, claimedUntil) {
+		return ErrClaimLost
+	}
+
+	m.records[key] = Record{
+		Key:       key,
+		Status:    StatusCompleted,
+		Result:    append([]byte(nil), result...),
+		ExpiresAt: expiresAt,
+	}
+	return nil
+}
+
+// Release removes the claim that expires at claimedUntil, so the event can be processed again.
+func (m *MemoryStore) Release(ctx context.Context, key string, claimedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.claimed(key, claimedUntil) {
+		return ErrClaimLost
+	}
+
+	delete(m.records, key)
+	return nil
+}
+
+// Get returns the record for the key, whether or not it has expired.
+func (m *MemoryStore) Get(key string) (Record, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.records[key]
+	return rec, ok
+}
+
+// claimed returns whether the record for the key is still the claim that expires at claimedUntil.
+func (m *MemoryStore) claimed(key string, claimedUntil time.Time) bool {
+	rec, ok := m.records[key]
+	return ok && rec.Status == StatusInProgress && rec.ExpiresAt.Equal(claimedUntil)
+}
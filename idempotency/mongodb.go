@@ -0,0 +1,147 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoCollection is the name of the MongoDB collection that contains the records.
+const MongoCollection = "idempotency"
+
+// mongoRecord is a Record as it is stored in MongoDB.
+type mongoRecord struct {
+	Key       string    `bson:"_id"`
+	PK        string    `bson:"PK"`
+	Status    string    `bson:"Status"`
+	Payload   string    `bson:"Payload,omitempty"`
+	ExpiresAt time.Time `bson:"ExpiresAt"`
+}
+
+// MongoStore is a Store that keeps records in the idempotency collection of the MongoDB database of
+// the shop. The key of the event is used as the _id of the document.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore creates a MongoStore that uses the idempotency collection of the database.
+func NewMongoStore(dbs *mongo.Database) *MongoStore {
+	return &MongoStore{
+		coll: dbs.Collection(MongoCollection),
+	}
+}
+
+// EnsureIndexes creates the TTL index that lets MongoDB remove expired records.
+func (m *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ExpiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("ExpiresAt_ttl"),
+	})
+
+	return err
+}
+
+// Claim marks the event as in progress until expiresAt, unless a record that has not expired exists.
+func (m *MongoStore) Claim(ctx context.Context, key string, expiresAt time.Time) (*Record, error) {
+	// The filter only matches an expired record. If a record exists that has not expired the upsert
+	// tries to insert a second document with the same _id, which fails with a duplicate key error.
+	filter := bson.D{
+		{Key: "_id", Value: key},
+		{Key: "ExpiresAt", Value: bson.D{{Key: "$lt", Value: time.Now()}}},
+	}
+	update := bson.D{{Key: "$set", Value: mongoRecord{
+		Key:       key,
+		PK:        "IDEMPOTENCY",
+		Status:    StatusInProgress,
+		ExpiresAt: expiresAt,
+	}}}
+
+	_, err := m.coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return nil, nil
+	}
+
+	if !isDuplicateKey(err) {
+		return nil, err
+	}
+
+	var doc mongoRecord
+	err = m.coll.FindOne(ctx, bson.D{{Key: "_id", Value: key}}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		// The record was released between the update and the find, so another delivery is
+		// still handling the event
+		return &Record{Key: key, Status: StatusInProgress}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Key:       doc.Key,
+		Status:    doc.Status,
+		Result:    []byte(doc.Payload),
+		ExpiresAt: doc.ExpiresAt,
+	}, nil
+}
+
+// Complete stores the result of the event until expiresAt, when the claim that expires at
+// claimedUntil is still held.
+func (m *MongoStore) Complete(ctx context.Context, key string, claimedUntil time.Time, result []byte, expiresAt time.Time) error {
+	doc := mongoRecord{
+		Key:       key,
+		PK:        "IDEMPOTENCY",
+		Status:    StatusCompleted,
+		Payload:   string(result),
+		ExpiresAt: expiresAt,
+	}
+
+	res, err := m.coll.ReplaceOne(ctx, claimed(key, claimedUntil), doc)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// Release removes the claim that expires at claimedUntil, so the event can be processed again.
+func (m *MongoStore) Release(ctx context.Context, key string, claimedUntil time.Time) error {
+	res, err := m.coll.DeleteOne(ctx, claimed(key, claimedUntil))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// claimed returns the filter that matches the record when it is still the claim that expires at
+// claimedUntil.
+func claimed(key string, claimedUntil time.Time) bson.D {
+	return bson.D{
+		{Key: "_id", Value: key},
+		{Key: "Status", Value: StatusInProgress},
+		{Key: "ExpiresAt", Value: claimedUntil},
+	}
+}
+
+// isDuplicateKey reports whether err is caused by a document with the same _id.
+func isDuplicateKey(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == 11000
+	}
+
+	return false
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless/messaging"
+)
+
+// eventNames maps the types in the metadata of events to the names of their events, for the events
+// whose name isn't their type.
+var eventNames = map[string]string{
+	"CreditCardValidated":    acmeserverless.CreditCardValidatedEventName,
+	"PaymentRequested":       acmeserverless.PaymentRequestedEventName,
+	"PaymentRefundRequested": acmeserverless.PaymentRefundRequestedEventName,
+}
+
+// event is a single event read from a file, with the place it was read from.
+type event struct {
+	source  string
+	payload json.RawMessage
+}
+
+// maxRate is the highest rate of the rate flag, at which events are sent every nanosecond.
+const maxRate = 1e9
+
+const usage = `Usage: acme-events send [flags] <file|directory|->...
+
+Sends the events in JSON files, the files in directories, or JSON lines read from
+stdin (-) to a queue, event bus, topic, or HTTP endpoint.
+
+Flags:
+`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 || os.Args[1] != "send" {
+		fmt.Fprint(os.Stderr, usage)
+		newSendFlags(&sendOptions{}).PrintDefaults()
+		os.Exit(2)
+	}
+
+	opts := &sendOptions{}
+	fs := newSendFlags(opts)
+	fs.Parse(os.Args[2:])
+
+	if fs.NArg() == 0 {
+		log.Fatal("Error: no files, directories, or - to send events from")
+	}
+
+	if opts.repeat < 1 {
+		log.Fatal("Error: the 'repeat' flag must be at least 1")
+	}
+
+	if !(opts.rate >= 0 && opts.rate <= maxRate) {
+		log.Fatalf("Error: the 'rate' flag must be between 0 and %.0f", maxRate)
+	}
+
+	publisher, err := newPublisher(opts)
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	events, err := readEvents(fs.Args())
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	sent, failed := send(context.Background(), publisher, events, opts)
+	log.Printf("Sent %d events, %d failed", sent, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// sendOptions are the flags of the send command.
+type sendOptions struct {
+	config messaging.Config
+	event  string
+	rate   float64
+	repeat int
+	create bool
+}
+
+// newSendFlags creates the FlagSet of the send command.
+func newSendFlags(opts *sendOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	opts.config.RegisterFlags(fs)
+	fs.StringVar(&opts.event, "event", "", "The name of the events, when it isn't the type in their metadata (optional)")
+	fs.Float64Var(&opts.rate, "rate", 0, "The maximum number of events sent per second, 0 sends them as fast as possible")
+	fs.IntVar(&opts.repeat, "repeat", 1, "The number of times every event is sent, each time as a new message")
+	fs.BoolVar(&opts.create, "create", false, "Create the Pub/Sub topics when they don't exist, which is useful with the emulator (optional)")
+	return fs
+}
+
+// newPublisher creates the publisher of the transport, and creates the Pub/Sub topics when asked to.
+func newPublisher(opts *sendOptions) (messaging.Publisher, error) {
+	p, err := messaging.NewPublisher(opts.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.create && opts.config.Transport == messaging.PubSub {
+		topics := []string{opts.config.Topic}
+		for _, t := range opts.config.Queues {
+			topics = append(topics, t)
+		}
+
+		client := messaging.NewPubSubClient(opts.config.Project)
+		for _, t := range topics {
+			if len(t) == 0 {
+				continue
+			}
+			if err := client.CreateTopic(context.Background(), t); err != nil {
+				return nil, fmt.Errorf("error creating topic %s: %s", t, err.Error())
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// readEvents reads the events of all paths, in the order of the paths. A directory is read
+// recursively, in the order of the names of its .json and .jsonl files, and - reads stdin.
+func readEvents(paths []string) ([]event, error) {
+	var events []event
+
+	for _, p := range paths {
+		if p == "-" {
+			e, err := decodeEvents("stdin", os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e...)
+			continue
+		}
+
+		files, err := listFiles(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, fmt.Errorf("error reading file: %s", err.Error())
+			}
+			e, err := decodeEvents(file, f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e...)
+		}
+	}
+
+	return events, nil
+}
+
+// listFiles returns the path itself when it is a file, and the .json and .jsonl files in it when
+// it is a directory.
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %s", err.Error())
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := filepath.Ext(p); !info.IsDir() && (ext == ".json" || ext == ".jsonl") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %s", err.Error())
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// decodeEvents reads the JSON values from r. A file with a single event, which may span multiple
+// lines, and a file with one event per line are both read this way. When r contains more than one
+// event, the source of each event is its position in r, like events.jsonl:3.
+func decodeEvents(name string, r io.Reader) ([]event, error) {
+	var events []event
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var payload json.RawMessage
+		if err := dec.Decode(&payload); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading event %d of %s: %s", len(events)+1, name, err.Error())
+		}
+		events = append(events, event{source: fmt.Sprintf("%s:%d", name, len(events)+1), payload: payload})
+	}
+
+	if len(events) == 1 {
+		events[0].source = name
+	}
+	return events, nil
+}
+
+// message creates a new message for the event. The metadata of the event is sent as the attributes
+// of the message. It returns an error when the event isn't an object or its metadata can't be read.
+func (e event) message(name string) (messaging.Message, error) {
+	var payload struct {
+		Metadata acmeserverless.Metadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(e.payload, &payload); err != nil {
+		return messaging.Message{}, fmt.Errorf("error reading metadata: %s", err.Error())
+	}
+
+	if len(name) == 0 {
+		name = payload.Metadata.Type
+		if n, ok := eventNames[name]; ok {
+			name = n
+		}
+	}
+
+	return messaging.Message{
+		ID:       uuid.Must(uuid.NewV4()).String(),
+		Name:     name,
+		Metadata: payload.Metadata,
+		Payload:  e.payload,
+	}, nil
+}
+
+// send publishes every event the number of times in the options, at most at the rate in the
+// options. It prints the ID of every message that was sent to stdout and every failure to stderr,
+// and returns the number of messages that were sent and that failed.
+func send(ctx context.Context, p messaging.Publisher, events []event, opts *sendOptions) (int, int) {
+	var tick <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	sent, failed := 0, 0
+	for i := 0; i < opts.repeat; i++ {
+		for _, e := range events {
+			if tick != nil && sent+failed > 0 {
+				<-tick
+			}
+
+			m, err := e.message(opts.event)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "FAILED\t%s\t%s\t%s\n", e.source, opts.event, err.Error())
+				continue
+			}
+
+			id, err := p.Publish(ctx, m)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "FAILED\t%s\t%s\t%s\n", e.source, m.Name, strings.TrimSpace(err.Error()))
+				continue
+			}
+
+			sent++
+			fmt.Printf("%s\t%s\t%s\n", id, e.source, m.Name)
+		}
+	}
+
+	return sent, failed
+}
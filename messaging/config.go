@@ -0,0 +1,260 @@
+package messaging
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+const (
+	// SQS sends messages to Amazon SQS queues.
+	SQS = "sqs"
+
+	// EventBridge sends messages to an Amazon EventBridge event bus, and receives them from an
+	// Amazon SQS queue that is the target of a rule of the bus.
+	EventBridge = "eventbridge"
+
+	// Memory sends messages to the queues of a Broker in the same process.
+	Memory = "memory"
+
+	// PubSub sends messages to Google Cloud Pub/Sub topics, and receives them from a pull
+	// subscription.
+	PubSub = "pubsub"
+
+	// HTTP sends messages to an HTTP endpoint, in the request of a Pub/Sub push subscription. It
+	// can't receive messages, services receive them with PubSubPushHandler.
+	HTTP = "http"
+)
+
+// DefaultBus is the event bus that is used when no bus is configured.
+const DefaultBus = "default"
+
+// Config selects the transport of a Publisher or Subscriber, and contains its settings.
+type Config struct {
+	// Transport is one of SQS, EventBridge, Memory, PubSub, or HTTP.
+	Transport string
+
+	// Region is the AWS region of the queues and the event bus.
+	Region string
+
+	// Queue is the URL of the queue a Subscriber receives messages from, or the name of the queue
+	// for Memory. For SQS and Memory, it is also the queue a Publisher sends the events without a
+	// queue in Queues to.
+	Queue string
+
+	// Queues maps the names of events to the queue a Publisher sends them to, for SQS and Memory,
+	// or to the topic for PubSub.
+	Queues map[string]string
+
+	// Bus is the name of the event bus a Publisher sends events to, for EventBridge.
+	Bus string
+
+	// Project is the Google Cloud project of the topics and subscription, for PubSub.
+	Project string
+
+	// Topic is the topic a Publisher sends the events without a topic in Queues to, for PubSub.
+	Topic string
+
+	// Subscription is the pull subscription a Subscriber receives messages from, for PubSub.
+	Subscription string
+
+	// URL is the endpoint a Publisher sends messages to, for HTTP.
+	URL string
+
+	// Broker is the Broker of the Memory transport. All publishers and subscribers of a process
+	// must share it.
+	Broker *Broker
+}
+
+// ConfigFromEnv reads the Config from the environment variables MESSAGING_TRANSPORT, AWS_REGION,
+// MESSAGING_QUEUE, MESSAGING_BUS, MESSAGING_QUEUES, GOOGLE_CLOUD_PROJECT, MESSAGING_TOPIC,
+// MESSAGING_SUBSCRIPTION, and MESSAGING_URL. MESSAGING_QUEUES is a comma separated list of event=url pairs, like
+// PaymentRequested=https://sqs.us-west-2.amazonaws.com/123456789012/payment.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		Transport:    os.Getenv("MESSAGING_TRANSPORT"),
+		Region:       os.Getenv("AWS_REGION"),
+		Queue:        os.Getenv("MESSAGING_QUEUE"),
+		Bus:          os.Getenv("MESSAGING_BUS"),
+		Project:      os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		Topic:        os.Getenv("MESSAGING_TOPIC"),
+		Subscription: os.Getenv("MESSAGING_SUBSCRIPTION"),
+		URL:          os.Getenv("MESSAGING_URL"),
+	}
+
+	queues, err := ParseQueues(os.Getenv("MESSAGING_QUEUES"))
+	if err != nil {
+		return c, err
+	}
+	c.Queues = queues
+
+	return c, nil
+}
+
+// ParseQueues parses a comma separated list of event=url pairs.
+func ParseQueues(s string) (map[string]string, error) {
+	queues := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid queue %q, must be event=url", pair)
+		}
+		queues[kv[0]] = kv[1]
+	}
+
+	return queues, nil
+}
+
+// queuesFlag is a flag.Value that adds event=url pairs to the queues of a Config.
+type queuesFlag struct {
+	c *Config
+}
+
+func (q queuesFlag) String() string {
+	if q.c == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(q.c.Queues))
+	for k, v := range q.c.Queues {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (q queuesFlag) Set(s string) error {
+	queues, err := ParseQueues(s)
+	if err != nil {
+		return err
+	}
+
+	if q.c.Queues == nil {
+		q.c.Queues = make(map[string]string)
+	}
+	for k, v := range queues {
+		q.c.Queues[k] = v
+	}
+	return nil
+}
+
+// RegisterFlags registers the flags of the Config with the FlagSet.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Transport, "transport", SQS, "The transport to use: sqs, eventbridge, pubsub, or http")
+	fs.StringVar(&c.Region, "region", "us-west-2", "The AWS region of the queues and the event bus")
+	fs.StringVar(&c.Queue, "queue", "", "The URL of the Amazon SQS queue to send events to or receive them from")
+	fs.Var(queuesFlag{c}, "queues", "A comma separated list of event=url pairs of the Amazon SQS queues to send events to (optional)")
+	fs.StringVar(&c.Bus, "bus", DefaultBus, "The name of the Amazon EventBridge event bus to send events to")
+	fs.StringVar(&c.Project, "project", "", "The Google Cloud project of the Pub/Sub topics and subscription")
+	fs.StringVar(&c.Topic, "topic", "", "The Pub/Sub topic to send events to")
+	fs.StringVar(&c.Subscription, "subscription", "", "The Pub/Sub subscription to receive events from")
+	fs.StringVar(&c.URL, "url", "", "The URL of the HTTP endpoint to send events to")
+}
+
+// NewPublisher creates the Publisher of the transport in the Config.
+func NewPublisher(c Config) (Publisher, error) {
+	switch c.Transport {
+	case SQS:
+		if len(c.Queue) == 0 && len(c.Queues) == 0 {
+			return nil, fmt.Errorf("no queues configured for %s", SQS)
+		}
+		sess, err := newSession(c)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQSPublisher(sqs.New(sess), c.Queues).WithDefaultQueue(c.Queue), nil
+	case EventBridge:
+		sess, err := newSession(c)
+		if err != nil {
+			return nil, err
+		}
+		bus := c.Bus
+		if len(bus) == 0 {
+			bus = DefaultBus
+		}
+		return NewEventBridgePublisher(eventbridge.New(sess), bus), nil
+	case Memory:
+		if c.Broker == nil {
+			return nil, fmt.Errorf("no broker configured for %s", Memory)
+		}
+		return NewMemoryPublisher(c.Broker, c.Queues).WithDefaultQueue(c.Queue), nil
+	case PubSub:
+		if len(c.Project) == 0 {
+			return nil, fmt.Errorf("no project configured for %s", PubSub)
+		}
+		if len(c.Topic) == 0 && len(c.Queues) == 0 {
+			return nil, fmt.Errorf("no topics configured for %s", PubSub)
+		}
+		return NewPubSubPublisher(NewPubSubClient(c.Project), c.Queues).WithDefaultTopic(c.Topic), nil
+	case HTTP:
+		if len(c.URL) == 0 {
+			return nil, fmt.Errorf("no url configured for %s", HTTP)
+		}
+		return NewHTTPPublisher(c.URL), nil
+	default:
+		return nil, unknownTransport(c.Transport)
+	}
+}
+
+// NewSubscriber creates the Subscriber of the transport in the Config.
+func NewSubscriber(c Config) (Subscriber, error) {
+	switch c.Transport {
+	case SQS, EventBridge:
+		if len(c.Queue) == 0 {
+			return nil, fmt.Errorf("no queue configured to receive from")
+		}
+		sess, err := newSession(c)
+		if err != nil {
+			return nil, err
+		}
+		if c.Transport == EventBridge {
+			return NewEventBridgeSubscriber(sqs.New(sess), c.Queue), nil
+		}
+		return NewSQSSubscriber(sqs.New(sess), c.Queue), nil
+	case Memory:
+		if c.Broker == nil {
+			return nil, fmt.Errorf("no broker configured for %s", Memory)
+		}
+		if len(c.Queue) == 0 {
+			return nil, fmt.Errorf("no queue configured to receive from")
+		}
+		return NewMemorySubscriber(c.Broker, c.Queue), nil
+	case PubSub:
+		if len(c.Project) == 0 {
+			return nil, fmt.Errorf("no project configured for %s", PubSub)
+		}
+		if len(c.Subscription) == 0 {
+			return nil, fmt.Errorf("no subscription configured to receive from")
+		}
+		return NewPubSubSubscriber(NewPubSubClient(c.Project), c.Subscription), nil
+	case HTTP:
+		return nil, fmt.Errorf("%s can't receive messages, use PubSubPushHandler to receive them", HTTP)
+	default:
+		return nil, unknownTransport(c.Transport)
+	}
+}
+
+// newSession creates an AWS session for the region in the Config.
+func newSession(c Config) (*session.Session, error) {
+	return session.NewSession(&aws.Config{
+		Region: aws.String(c.Region),
+	})
+}
+
+// unknownTransport returns the error for a transport that doesn't exist.
+func unknownTransport(transport string) error {
+	if len(transport) == 0 {
+		return fmt.Errorf("no transport configured")
+	}
+	return fmt.Errorf("unknown transport %q, must be one of %s, %s, %s, %s, or %s", transport, SQS, EventBridge, Memory, PubSub, HTTP)
+}
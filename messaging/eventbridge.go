@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+)
+
+// SourcePrefix is the prefix of the source of the events an EventBridgePublisher sends, which is
+// followed by the lowercase domain of the event, like acmeserverless.order.
+const SourcePrefix = "acmeserverless."
+
+// resourcePrefix is the prefix of the resource of an event that contains the ID of the message.
+const resourcePrefix = "acmeserverless:message/"
+
+// EventBridgePublisher publishes messages to an Amazon EventBridge event bus.
+type EventBridgePublisher struct {
+	svc *eventbridge.EventBridge
+	bus string
+}
+
+// NewEventBridgePublisher creates an EventBridgePublisher that sends events to the given bus.
+func NewEventBridgePublisher(svc *eventbridge.EventBridge, bus string) *EventBridgePublisher {
+	return &EventBridgePublisher{
+		svc: svc,
+		bus: bus,
+	}
+}
+
+// Publish sends the payload of the message as the detail of an event. The detail type is the name
+// of the event, the source is SourcePrefix followed by the domain, and the ID of the message is sent
+// as a resource of the event, so rules can match on all of them.
+func (p *EventBridgePublisher) Publish(ctx context.Context, m Message) (string, error) {
+	entry := &eventbridge.PutEventsRequestEntry{
+		Detail:       aws.String(string(m.Payload)),
+		DetailType:   aws.String(m.Name),
+		EventBusName: aws.String(p.bus),
+		Source:       aws.String(SourcePrefix + strings.ToLower(m.Metadata.Domain)),
+	}
+	if len(m.ID) > 0 {
+		entry.Resources = aws.StringSlice([]string{resourcePrefix + m.ID})
+	}
+
+	output, err := p.svc.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if aws.Int64Value(output.FailedEntryCount) > 0 {
+		e := output.Entries[0]
+		return "", fmt.Errorf("%s: %s", aws.StringValue(e.ErrorCode), aws.StringValue(e.ErrorMessage))
+	}
+
+	return aws.StringValue(output.Entries[0].EventId), nil
+}
@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+)
+
+// HTTPPublisher sends messages to an HTTP endpoint, in the same request a push subscription of
+// Google Cloud Pub/Sub sends. A service that receives messages with PubSubPushHandler can be sent
+// events directly, like when it runs on your machine.
+type HTTPPublisher struct {
+	client *http.Client
+	url    string
+}
+
+// NewHTTPPublisher creates an HTTPPublisher that sends messages to the URL.
+func NewHTTPPublisher(url string) *HTTPPublisher {
+	return &HTTPPublisher{
+		client: http.DefaultClient,
+		url:    url,
+	}
+}
+
+// WithHTTPClient sets the HTTP client that sends the requests.
+func (p *HTTPPublisher) WithHTTPClient(client *http.Client) *HTTPPublisher {
+	p.client = client
+	return p
+}
+
+// Publish posts the message to the URL. There is no transport to assign an ID to the message, so
+// the ID of the message is returned, and a message without an ID is given a new one.
+func (p *HTTPPublisher) Publish(ctx context.Context, m Message) (string, error) {
+	if len(m.ID) == 0 {
+		m.ID = uuid.Must(uuid.NewV4()).String()
+	}
+
+	in := struct {
+		Message      pubSubMessage `json:"message"`
+		Subscription string        `json:"subscription"`
+	}{
+		Message: pubSubMessage{
+			Data:       m.Payload,
+			Attributes: m.Attributes(),
+			MessageID:  m.ID,
+		},
+		Subscription: "acme-events",
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling message: %s", err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %s", err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending message: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return "", fmt.Errorf("http: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	return m.ID, nil
+}
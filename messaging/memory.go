@@ -0,0 +1,431 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// DefaultVisibilityTimeout is the time a received message is hidden from other receivers, which is
+// the same as the queues of the shop.
+const DefaultVisibilityTimeout = 30 * time.Second
+
+// The queues of the shop, which are the same as the queues created in messaging/sqs/pulumi.
+const (
+	// PaymentRequestQueue receives the payment requests of orders.
+	PaymentRequestQueue = "payment-request"
+
+	// PaymentResponseQueue receives the results of payments.
+	PaymentResponseQueue = "payment-response"
+
+	// PaymentRefundQueue receives the refund requests of payments of orders that were cancelled.
+	PaymentRefundQueue = "payment-refund"
+
+	// PaymentErrorQueue receives the messages of the payment queues that couldn't be handled.
+	PaymentErrorQueue = "payment-error"
+
+	// ShipmentRequestQueue receives the shipment requests of orders.
+	ShipmentRequestQueue = "shipment-request"
+
+	// ShipmentResponseQueue receives the updates of shipments.
+	ShipmentResponseQueue = "shipment-response"
+
+	// ShipmentErrorQueue receives the messages of the shipment queues that couldn't be handled.
+	ShipmentErrorQueue = "shipment-error"
+
+	// OrderCancelledQueue receives the orders that were cancelled.
+	OrderCancelledQueue = "order-cancelled"
+
+	// OrderErrorQueue receives the messages of the order queues that couldn't be handled.
+	OrderErrorQueue = "order-error"
+)
+
+// ShopQueues maps the events of the order, payment, and shipment flow, and the compensations of
+// an order that is cancelled, to the queue they are sent to.
+var ShopQueues = map[string]string{
+	acmeserverless.PaymentRequestedEventName:       PaymentRequestQueue,
+	acmeserverless.CreditCardValidatedEventName:    PaymentResponseQueue,
+	acmeserverless.PaymentRefundRequestedEventName: PaymentRefundQueue,
+	acmeserverless.ShipmentRequestedEventName:      ShipmentRequestQueue,
+	acmeserverless.ShipmentSentEventName:           ShipmentResponseQueue,
+	acmeserverless.ShipmentDeliveredEventName:      ShipmentResponseQueue,
+	acmeserverless.OrderCancelledEventName:         OrderCancelledQueue,
+}
+
+// QueueConfig contains the settings of a queue of a Broker, which work like the settings of an
+// Amazon SQS queue with the same names.
+type QueueConfig struct {
+	// VisibilityTimeout is the time a received message is hidden from other receivers. It defaults
+	// to DefaultVisibilityTimeout.
+	VisibilityTimeout time.Duration
+
+	// Retention is the time a message is kept before it is removed. It defaults to keeping
+	// messages until they are acknowledged.
+	Retention time.Duration
+
+	// DeadLetterQueue is the queue a message is moved to when it was received MaxReceiveCount
+	// times without being acknowledged.
+	DeadLetterQueue string
+
+	// MaxReceiveCount is the number of times a message can be received before it is moved to the
+	// DeadLetterQueue.
+	MaxReceiveCount int
+}
+
+// brokerMessage is a message in a queue of a Broker.
+type brokerMessage struct {
+	Message
+	sentAt       time.Time
+	visibleAt    time.Time
+	receiveCount int
+	receipt      string
+}
+
+// brokerQueue is a queue of a Broker.
+type brokerQueue struct {
+	config   QueueConfig
+	messages []*brokerMessage
+}
+
+// Broker is an in-process message broker with the semantics of the Amazon SQS queues of the shop,
+// so the order, payment, and shipment flow can run in a single process without AWS. A received
+// message is hidden until it is acknowledged or its visibility timeout passes, and a message that is
+// received too often is moved to the dead-letter queue. It is safe for concurrent use.
+type Broker struct {
+	mu       sync.Mutex
+	queues   map[string]*brokerQueue
+	seq      int
+	now      func() time.Time
+	arrived  chan struct{}
+	interval time.Duration
+}
+
+// NewBroker creates a Broker without queues.
+func NewBroker() *Broker {
+	return &Broker{
+		queues:   make(map[string]*brokerQueue),
+		now:      time.Now,
+		arrived:  make(chan struct{}),
+		interval: 10 * time.Millisecond,
+	}
+}
+
+// NewShopBroker creates a Broker with the queues of the shop, which have the same settings as the
+// queues created in messaging/sqs/pulumi. Publishers of the Broker can send the events in ShopQueues.
+func NewShopBroker() *Broker {
+	b := NewBroker()
+
+	for _, q := range []struct {
+		name       string
+		deadLetter string
+	}{
+		{PaymentErrorQueue, ""},
+		{PaymentRequestQueue, PaymentErrorQueue},
+		{PaymentResponseQueue, PaymentErrorQueue},
+		{PaymentRefundQueue, PaymentErrorQueue},
+		{ShipmentErrorQueue, ""},
+		{ShipmentRequestQueue, ShipmentErrorQueue},
+		{ShipmentResponseQueue, ShipmentErrorQueue},
+		{OrderErrorQueue, ""},
+		{OrderCancelledQueue, OrderErrorQueue},
+	} {
+		cfg := QueueConfig{
+			VisibilityTimeout: DefaultVisibilityTimeout,
+			Retention:         120 * time.Second,
+		}
+		if len(q.deadLetter) > 0 {
+			cfg.DeadLetterQueue = q.deadLetter
+			cfg.MaxReceiveCount = 1
+		}
+
+		// The queues are created in an order in which every dead-letter queue exists
+		_ = b.CreateQueue(q.name, cfg)
+	}
+
+	return b
+}
+
+// WithClock sets the function the Broker uses to get the current time, so tests can move past the
+// visibility timeout of a message without waiting for it.
+func (b *Broker) WithClock(now func() time.Time) *Broker {
+	b.now = now
+	return b
+}
+
+// CreateQueue creates a queue. The dead-letter queue of the queue must already exist.
+func (b *Broker) CreateQueue(name string, cfg QueueConfig) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queues[name]; ok {
+		return fmt.Errorf("queue %s already exists", name)
+	}
+
+	if len(cfg.DeadLetterQueue) > 0 {
+		if _, ok := b.queues[cfg.DeadLetterQueue]; !ok {
+			return fmt.Errorf("dead-letter queue %s of queue %s doesn't exist", cfg.DeadLetterQueue, name)
+		}
+		if cfg.MaxReceiveCount <= 0 {
+			return fmt.Errorf("the maximum receive count of queue %s must be at least 1", name)
+		}
+	}
+
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+
+	b.queues[name] = &brokerQueue{config: cfg}
+	return nil
+}
+
+// Messages returns the messages in a queue, including the ones that are hidden because they were
+// received, in the order they were sent. Tests can use it to check the dead-letter queues.
+func (b *Broker) Messages(queue string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil
+	}
+
+	b.expire(q)
+	msgs := make([]Message, len(q.messages))
+	for i, m := range q.messages {
+		msgs[i] = m.Message
+	}
+	return msgs
+}
+
+// send adds a message to a queue.
+func (b *Broker) send(queue string, m Message) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return "", fmt.Errorf("queue %s doesn't exist", queue)
+	}
+
+	b.seq++
+	id := strconv.Itoa(b.seq)
+	if len(m.ID) == 0 {
+		m.ID = id
+	}
+
+	now := b.now()
+	q.messages = append(q.messages, &brokerMessage{
+		Message:   m,
+		sentAt:    now,
+		visibleAt: now,
+	})
+	b.signal()
+
+	return id, nil
+}
+
+// receive returns at most max visible messages of a queue, and hides them.
+func (b *Broker) receive(queue string, max int) ([]Delivery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %s doesn't exist", queue)
+	}
+
+	b.expire(q)
+	now := b.now()
+
+	var deliveries []Delivery
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if len(deliveries) >= max || now.Before(m.visibleAt) {
+			kept = append(kept, m)
+			continue
+		}
+
+		// Like Amazon SQS, a message is moved to the dead-letter queue when it is received again
+		// after it was received the maximum number of times
+		if q.config.MaxReceiveCount > 0 && m.receiveCount >= q.config.MaxReceiveCount {
+			dlq := b.queues[q.config.DeadLetterQueue]
+			dlq.messages = append(dlq.messages, &brokerMessage{
+				Message:   m.Message,
+				sentAt:    m.sentAt,
+				visibleAt: now,
+			})
+			b.signal()
+			continue
+		}
+
+		b.seq++
+		m.receiveCount++
+		m.visibleAt = now.Add(q.config.VisibilityTimeout)
+		m.receipt = strconv.Itoa(b.seq)
+		kept = append(kept, m)
+
+		deliveries = append(deliveries, Delivery{
+			Message: m.Message,
+			Attempt: m.receiveCount,
+			receipt: m.receipt,
+		})
+	}
+	q.messages = kept
+
+	return deliveries, nil
+}
+
+// settle removes the message of a delivery from the queue when ack is true, and makes it visible
+// again otherwise. It fails when the message was received again after the delivery, because its
+// visibility timeout passed.
+func (b *Broker) settle(queue string, d Delivery, ack bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		return fmt.Errorf("queue %s doesn't exist", queue)
+	}
+
+	for i, m := range q.messages {
+		if m.receipt != d.receipt {
+			continue
+		}
+
+		if ack {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+		} else {
+			m.visibleAt = b.now()
+			b.signal()
+		}
+		return nil
+	}
+
+	return fmt.Errorf("message %s isn't received with this delivery anymore", d.ID)
+}
+
+// expire removes the messages that are older than the retention of the queue.
+func (b *Broker) expire(q *brokerQueue) {
+	if q.config.Retention <= 0 {
+		return
+	}
+
+	cutoff := b.now().Add(-q.config.Retention)
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if m.sentAt.After(cutoff) {
+			kept = append(kept, m)
+		}
+	}
+	q.messages = kept
+}
+
+// signal wakes up the receivers that wait for messages.
+func (b *Broker) signal() {
+	close(b.arrived)
+	b.arrived = make(chan struct{})
+}
+
+// wait returns a channel that is closed when a message arrives or becomes visible.
+func (b *Broker) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.arrived
+}
+
+// MemoryPublisher publishes messages to the queues of a Broker.
+type MemoryPublisher struct {
+	broker *Broker
+	queues map[string]string
+	queue  string
+}
+
+// NewMemoryPublisher creates a MemoryPublisher. The queues map the name of an event to the queue of
+// the Broker it must be sent to, like ShopQueues.
+func NewMemoryPublisher(b *Broker, queues map[string]string) *MemoryPublisher {
+	return &MemoryPublisher{
+		broker: b,
+		queues: queues,
+	}
+}
+
+// WithDefaultQueue sets the queue the events without a queue of their own are sent to.
+func (p *MemoryPublisher) WithDefaultQueue(queue string) *MemoryPublisher {
+	p.queue = queue
+	return p
+}
+
+// Publish sends the message to the queue configured for its event.
+func (p *MemoryPublisher) Publish(ctx context.Context, m Message) (string, error) {
+	queue, ok := p.queues[m.Name]
+	if !ok {
+		queue = p.queue
+	}
+	if len(queue) == 0 {
+		return "", fmt.Errorf("no queue configured for event %s", m.Name)
+	}
+
+	return p.broker.send(queue, m)
+}
+
+// MemorySubscriber receives messages from a queue of a Broker.
+type MemorySubscriber struct {
+	broker *Broker
+	queue  string
+	wait   time.Duration
+}
+
+// NewMemorySubscriber creates a MemorySubscriber for a queue of the Broker.
+func NewMemorySubscriber(b *Broker, queue string) *MemorySubscriber {
+	return &MemorySubscriber{
+		broker: b,
+		queue:  queue,
+		wait:   DefaultWaitTime,
+	}
+}
+
+// WithWaitTime sets the time Receive waits for messages to arrive.
+func (s *MemorySubscriber) WithWaitTime(wait time.Duration) *MemorySubscriber {
+	s.wait = wait
+	return s
+}
+
+// Receive waits for messages to arrive and returns at most 10 of them. Messages that become visible
+// again because their visibility timeout passed are noticed within a few milliseconds.
+func (s *MemorySubscriber) Receive(ctx context.Context) ([]Delivery, error) {
+	timeout := time.NewTimer(s.wait)
+	defer timeout.Stop()
+
+	for {
+		arrived := s.broker.wait()
+
+		deliveries, err := s.broker.receive(s.queue, 10)
+		if err != nil || len(deliveries) > 0 {
+			return deliveries, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, nil
+		case <-arrived:
+		case <-time.After(s.broker.interval):
+		}
+	}
+}
+
+// Ack removes the message from the queue.
+func (s *MemorySubscriber) Ack(ctx context.Context, d Delivery) error {
+	return s.broker.settle(s.queue, d, true)
+}
+
+// Nack makes the message visible again right away, instead of after the visibility timeout of the
+// queue.
+func (s *MemorySubscriber) Nack(ctx context.Context, d Delivery) error {
+	return s.broker.settle(s.queue, d, false)
+}
@@ -0,0 +1,246 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clock is a manual clock for a Broker.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBroker(t *testing.T, cfg QueueConfig) (*Broker, *clock) {
+	t.Helper()
+
+	c := &clock{now: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)}
+	b := NewBroker().WithClock(c.Now)
+	if err := b.CreateQueue("errors", QueueConfig{}); err != nil {
+		t.Fatalf("error creating queue: %s", err.Error())
+	}
+	if err := b.CreateQueue("requests", cfg); err != nil {
+		t.Fatalf("error creating queue: %s", err.Error())
+	}
+	return b, c
+}
+
+func receive(t *testing.T, s *MemorySubscriber) []Delivery {
+	t.Helper()
+
+	d, err := s.WithWaitTime(time.Millisecond).Receive(context.Background())
+	if err != nil {
+		t.Fatalf("error receiving messages: %s", err.Error())
+	}
+	return d
+}
+
+func ids(msgs []Message) []string {
+	n := []string{}
+	for _, m := range msgs {
+		n = append(n, m.ID)
+	}
+	return n
+}
+
+func publish(t *testing.T, b *Broker, ids ...string) {
+	t.Helper()
+
+	p := NewMemoryPublisher(b, nil).WithDefaultQueue("requests")
+	for _, id := range ids {
+		if _, err := p.Publish(context.Background(), Message{ID: id, Name: "PaymentRequestedEvent"}); err != nil {
+			t.Fatalf("error publishing message: %s", err.Error())
+		}
+	}
+}
+
+func TestVisibilityTimeout(t *testing.T) {
+	b, c := newTestBroker(t, QueueConfig{VisibilityTimeout: time.Minute})
+	s := NewMemorySubscriber(b, "requests")
+	publish(t, b, "1")
+
+	d := receive(t, s)
+	if len(d) != 1 || d[0].ID != "1" || d[0].Attempt != 1 {
+		t.Fatalf("received %+v, want the first attempt of message 1", d)
+	}
+
+	// The message is hidden until its visibility timeout passed
+	c.Advance(time.Minute - time.Second)
+	if d := receive(t, s); len(d) != 0 {
+		t.Fatalf("received %+v before the visibility timeout passed", d)
+	}
+
+	c.Advance(time.Second)
+	d = receive(t, s)
+	if len(d) != 1 || d[0].Attempt != 2 {
+		t.Fatalf("received %+v, want the second attempt of message 1", d)
+	}
+
+	if err := s.Ack(context.Background(), d[0]); err != nil {
+		t.Fatalf("error acknowledging message: %s", err.Error())
+	}
+	if msgs := b.Messages("requests"); len(msgs) != 0 {
+		t.Fatalf("queue has %v after the message was acknowledged", ids(msgs))
+	}
+}
+
+func TestNackRedeliversRightAway(t *testing.T) {
+	b, _ := newTestBroker(t, QueueConfig{VisibilityTimeout: time.Minute})
+	s := NewMemorySubscriber(b, "requests")
+	publish(t, b, "1")
+
+	d := receive(t, s)
+	if err := s.Nack(context.Background(), d[0]); err != nil {
+		t.Fatalf("error rejecting message: %s", err.Error())
+	}
+
+	d = receive(t, s)
+	if len(d) != 1 || d[0].Attempt != 2 {
+		t.Fatalf("received %+v, want the second attempt of message 1", d)
+	}
+}
+
+func TestRedriveToDeadLetterQueue(t *testing.T) {
+	b, c := newTestBroker(t, QueueConfig{
+		VisibilityTimeout: time.Minute,
+		DeadLetterQueue:   "errors",
+		MaxReceiveCount:   2,
+	})
+	s := NewMemorySubscriber(b, "requests")
+	publish(t, b, "1", "2")
+
+	// Message 2 is acknowledged on its second attempt, message 1 never is
+	for attempt := 1; attempt <= 2; attempt++ {
+		d := receive(t, s)
+		if len(d) != 2 || d[0].Attempt != attempt {
+			t.Fatalf("received %+v, want attempt %d of both messages", d, attempt)
+		}
+		if attempt == 2 {
+			s.Ack(context.Background(), d[1])
+		}
+		c.Advance(time.Minute)
+	}
+
+	// The message is moved when it is received after MaxReceiveCount attempts
+	if d := receive(t, s); len(d) != 0 {
+		t.Fatalf("received %+v after the maximum receive count", d)
+	}
+	if got := ids(b.Messages("requests")); len(got) != 0 {
+		t.Fatalf("queue has %v, want no messages", got)
+	}
+	if got := ids(b.Messages("errors")); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("dead-letter queue has %v, want message 1", got)
+	}
+
+	d := receive(t, NewMemorySubscriber(b, "errors"))
+	if len(d) != 1 || d[0].ID != "1" || d[0].Attempt != 1 {
+		t.Fatalf("received %+v from the dead-letter queue, want the first attempt of message 1", d)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	b, c := newTestBroker(t, QueueConfig{Retention: time.Hour})
+	s := NewMemorySubscriber(b, "requests")
+	publish(t, b, "1")
+	c.Advance(30 * time.Minute)
+	publish(t, b, "2")
+
+	// Messages expire whether or not they were received
+	receive(t, s)
+	c.Advance(30 * time.Minute)
+
+	if got := ids(b.Messages("requests")); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Fatalf("queue has %v, want only message 2", got)
+	}
+
+	c.Advance(time.Minute)
+	if d := receive(t, s); len(d) != 1 || d[0].ID != "2" {
+		t.Fatalf("received %+v, want message 2", d)
+	}
+
+	c.Advance(30 * time.Minute)
+	if d := receive(t, s); len(d) != 0 {
+		t.Fatalf("received %+v after the retention passed", d)
+	}
+}
+
+func TestSettleStaleReceipt(t *testing.T) {
+	b, c := newTestBroker(t, QueueConfig{VisibilityTimeout: time.Minute})
+	s := NewMemorySubscriber(b, "requests")
+	publish(t, b, "1")
+
+	stale := receive(t, s)[0]
+	c.Advance(time.Minute)
+	current := receive(t, s)[0]
+
+	// The first delivery can't settle the message once it was received again
+	if err := s.Ack(context.Background(), stale); err == nil {
+		t.Fatal("expected an error acknowledging a stale delivery")
+	}
+	if err := s.Nack(context.Background(), stale); err == nil {
+		t.Fatal("expected an error rejecting a stale delivery")
+	}
+	if got := ids(b.Messages("requests")); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("queue has %v, want message 1", got)
+	}
+
+	if err := s.Ack(context.Background(), current); err != nil {
+		t.Fatalf("error acknowledging message: %s", err.Error())
+	}
+	if err := s.Ack(context.Background(), current); err == nil {
+		t.Fatal("expected an error acknowledging a message twice")
+	}
+}
+
+func TestShopBroker(t *testing.T) {
+	c := &clock{now: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)}
+	b := NewShopBroker().WithClock(c.Now)
+	p := NewMemoryPublisher(b, ShopQueues)
+
+	// Every event of the shop, including the compensations of a cancelled order, has a queue
+	for event, queue := range ShopQueues {
+		if _, err := p.Publish(context.Background(), Message{ID: event, Name: event}); err != nil {
+			t.Fatalf("error publishing %s: %s", event, err.Error())
+		}
+
+		found := false
+		for _, m := range b.Messages(queue) {
+			found = found || m.ID == event
+		}
+		if !found {
+			t.Fatalf("queue %s has %v, want %s", queue, ids(b.Messages(queue)), event)
+		}
+	}
+
+	// Messages that aren't handled are moved to the error queue of their domain
+	tests := []struct {
+		queue      string
+		deadLetter string
+	}{
+		{PaymentRefundQueue, PaymentErrorQueue},
+		{OrderCancelledQueue, OrderErrorQueue},
+	}
+
+	for _, tt := range tests {
+		s := NewMemorySubscriber(b, tt.queue)
+		if d := receive(t, s); len(d) != 1 {
+			t.Fatalf("received %+v from %s, want 1 message", d, tt.queue)
+		}
+		c.Advance(DefaultVisibilityTimeout)
+		if d := receive(t, s); len(d) != 0 {
+			t.Fatalf("received %+v from %s again, want it moved to %s", d, tt.queue, tt.deadLetter)
+		}
+		if got := b.Messages(tt.deadLetter); len(got) != 1 {
+			t.Fatalf("queue %s has %v, want the message of %s", tt.deadLetter, ids(got), tt.queue)
+		}
+	}
+}
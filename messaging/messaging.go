@@ -0,0 +1,209 @@
+// Package messaging sends and receives the events of the ACME Serverless Fitness Shop. Services
+// publish events with a Publisher and receive them with a Subscriber, and the transport, like an
+// Amazon SQS queue or an Amazon EventBridge event bus, is selected with a Config. Every transport
+// carries the same attributes with a message, so swapping transports doesn't change the code of a
+// service.
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/gofrs/uuid"
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// The attributes that are sent with every message, as message attributes for Amazon SQS.
+const (
+	// AttributeID is the ID of the message, which stays the same when a message is sent again.
+	AttributeID = "id"
+
+	// AttributeEvent is the name of the event, like acmeserverless.PaymentRequestedEventName.
+	AttributeEvent = "event"
+
+	// AttributeDomain is the domain in the metadata of the event.
+	AttributeDomain = "domain"
+
+	// AttributeSource is the source in the metadata of the event.
+	AttributeSource = "source"
+
+	// AttributeType is the type in the metadata of the event.
+	AttributeType = "type"
+
+	// AttributeStatus is the status in the metadata of the event.
+	AttributeStatus = "status"
+)
+
+// Message is a single event of the shop.
+type Message struct {
+	// ID uniquely identifies the message, so consumers can ignore messages they already handled.
+	ID string
+
+	// Name is the name of the event, like acmeserverless.ShipmentRequestedEventName.
+	Name string
+
+	// Metadata is the metadata of the event.
+	Metadata acmeserverless.Metadata
+
+	// Payload is the JSON encoded event.
+	Payload json.RawMessage
+}
+
+// NewMessage creates a message for an event. The event must be one of the event structs of the
+// acmeserverless package, like ShipmentRequested.
+func NewMessage(name string, metadata acmeserverless.Metadata, event interface{}) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("error marshalling event: %s", err.Error())
+	}
+
+	return Message{
+		ID:       uuid.Must(uuid.NewV4()).String(),
+		Name:     name,
+		Metadata: metadata,
+		Payload:  payload,
+	}, nil
+}
+
+// Decode parses the payload of the message into an event struct of the acmeserverless package.
+func (m Message) Decode(event interface{}) error {
+	return json.Unmarshal(m.Payload, event)
+}
+
+// Attributes returns the attributes of the message that aren't empty.
+func (m Message) Attributes() map[string]string {
+	attrs := make(map[string]string)
+	for k, v := range map[string]string{
+		AttributeID:     m.ID,
+		AttributeEvent:  m.Name,
+		AttributeDomain: m.Metadata.Domain,
+		AttributeSource: m.Metadata.Source,
+		AttributeType:   m.Metadata.Type,
+		AttributeStatus: m.Metadata.Status,
+	} {
+		if len(v) > 0 {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// messageFromAttributes creates a message from the attributes and the payload it was received with.
+// When the attributes don't contain the metadata, like for a message that was sent without them,
+// the metadata is read from the payload, and the type in the metadata is used as the name of the
+// event. For most events that type is the event name, but not for the events of the payment domain.
+func messageFromAttributes(attrs map[string]string, payload []byte) Message {
+	m := Message{
+		ID:   attrs[AttributeID],
+		Name: attrs[AttributeEvent],
+		Metadata: acmeserverless.Metadata{
+			Domain: attrs[AttributeDomain],
+			Source: attrs[AttributeSource],
+			Type:   attrs[AttributeType],
+			Status: attrs[AttributeStatus],
+		},
+		Payload: payload,
+	}
+
+	if m.Metadata == (acmeserverless.Metadata{}) {
+		var event struct {
+			Metadata acmeserverless.Metadata `json:"metadata"`
+		}
+		if err := json.Unmarshal(payload, &event); err == nil {
+			m.Metadata = event.Metadata
+		}
+	}
+
+	if len(m.Name) == 0 {
+		m.Name = m.Metadata.Type
+	}
+
+	return m
+}
+
+// Publisher sends messages to a queue or event bus.
+type Publisher interface {
+	// Publish sends the message and returns the ID assigned to it by the transport.
+	Publish(ctx context.Context, m Message) (string, error)
+}
+
+// Delivery is a message that was received by a Subscriber.
+type Delivery struct {
+	Message
+
+	// Attempt is the number of times the message was received, starting at 1.
+	Attempt int
+
+	// receipt identifies the delivery to the transport when it is acknowledged.
+	receipt string
+}
+
+// Subscriber receives messages from a queue. A message that is received is hidden from other
+// receivers until it is acknowledged, which removes it, or until it is rejected or its visibility
+// timeout passes, after which it is received again.
+type Subscriber interface {
+	// Receive waits for messages and returns the messages that arrived. It returns no messages
+	// when none arrived within the wait time of the transport.
+	Receive(ctx context.Context) ([]Delivery, error)
+
+	// Ack removes a message that was handled.
+	Ack(ctx context.Context, d Delivery) error
+
+	// Nack makes a message that couldn't be handled available to be received again.
+	Nack(ctx context.Context, d Delivery) error
+}
+
+// Handler handles a single message.
+type Handler func(ctx context.Context, d Delivery) error
+
+// Router is a Handler that calls the Handler of the event of a message. Messages of events without
+// a Handler are ignored.
+type Router map[string]Handler
+
+// Handle calls the Handler of the event of the message.
+func (r Router) Handle(ctx context.Context, d Delivery) error {
+	h, ok := r[d.Name]
+	if !ok {
+		return nil
+	}
+
+	return h(ctx, d)
+}
+
+// Subscribe receives messages until the context is cancelled, and calls h for each of them. A
+// message is acknowledged when h returns nil, and rejected when h returns an error, so it is received
+// again. Consumers must be idempotent, as every transport may deliver a message more than once.
+// Errors acknowledging or rejecting a message are only logged, as the message is received again
+// after its visibility timeout. Only an error receiving messages stops Subscribe.
+func Subscribe(ctx context.Context, s Subscriber, h Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		deliveries, err := s.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error receiving messages: %s", err.Error())
+		}
+
+		for _, d := range deliveries {
+			if err := h(ctx, d); err != nil {
+				if err := s.Nack(ctx, d); err != nil {
+					log.Printf("error rejecting message %s: %s", d.ID, err.Error())
+				}
+				continue
+			}
+
+			if err := s.Ack(ctx, d); err != nil {
+				log.Printf("error acknowledging message %s: %s", d.ID, err.Error())
+			}
+		}
+	}
+}
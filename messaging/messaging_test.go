@@ -0,0 +1,64 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// flakySubscriber returns each batch of deliveries once, fails to acknowledge or reject them, and
+// fails to receive when it runs out of batches.
+type flakySubscriber struct {
+	batches [][]Delivery
+	acked   []string
+	nacked  []string
+}
+
+func (f *flakySubscriber) Receive(ctx context.Context) ([]Delivery, error) {
+	if len(f.batches) == 0 {
+		return nil, errors.New("queue removed")
+	}
+
+	b := f.batches[0]
+	f.batches = f.batches[1:]
+	return b, nil
+}
+
+func (f *flakySubscriber) Ack(ctx context.Context, d Delivery) error {
+	f.acked = append(f.acked, d.ID)
+	return errors.New("receipt expired")
+}
+
+func (f *flakySubscriber) Nack(ctx context.Context, d Delivery) error {
+	f.nacked = append(f.nacked, d.ID)
+	return errors.New("receipt expired")
+}
+
+func TestSubscribeContinuesAfterAckErrors(t *testing.T) {
+	s := &flakySubscriber{
+		batches: [][]Delivery{
+			{{Message: Message{ID: "1"}}, {Message: Message{ID: "2"}}},
+			{{Message: Message{ID: "3"}}},
+		},
+	}
+
+	var handled []string
+	err := Subscribe(context.Background(), s, func(ctx context.Context, d Delivery) error {
+		handled = append(handled, d.ID)
+		if d.ID == "2" {
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+
+	// Only the error receiving messages stops Subscribe
+	if err == nil || err.Error() != "error receiving messages: queue removed" {
+		t.Fatalf("error is %v, want the error receiving messages", err)
+	}
+	if len(handled) != 3 {
+		t.Fatalf("handled %v, want all 3 messages", handled)
+	}
+	if len(s.acked) != 2 || len(s.nacked) != 1 || s.nacked[0] != "2" {
+		t.Fatalf("acknowledged %v and rejected %v, want 1 and 3 acknowledged and 2 rejected", s.acked, s.nacked)
+	}
+}
@@ -0,0 +1,423 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPubSubEndpoint is the endpoint of the Google Cloud Pub/Sub API.
+	DefaultPubSubEndpoint = "https://pubsub.googleapis.com"
+
+	// metadataTokenURL is the URL of the metadata server of Google Cloud that returns an access
+	// token for the service account of a Cloud Run service.
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// PubSubClient sends requests to the REST API of Google Cloud Pub/Sub. When the
+// PUBSUB_EMULATOR_HOST environment variable is set, the requests are sent to the Pub/Sub emulator
+// without credentials. Otherwise the requests are authorized with the service account of the Cloud
+// Run service, unless another HTTP client is set.
+type PubSubClient struct {
+	http     *http.Client
+	endpoint string
+	project  string
+}
+
+// NewPubSubClient creates a PubSubClient for the topics and subscriptions of a Google Cloud project.
+func NewPubSubClient(project string) *PubSubClient {
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); len(host) > 0 {
+		return &PubSubClient{
+			http:     http.DefaultClient,
+			endpoint: "http://" + host,
+			project:  project,
+		}
+	}
+
+	return &PubSubClient{
+		http:     &http.Client{Transport: &metadataTransport{}},
+		endpoint: DefaultPubSubEndpoint,
+		project:  project,
+	}
+}
+
+// WithHTTPClient sets the HTTP client that sends the requests, like a client that adds the
+// credentials of a service account key.
+func (c *PubSubClient) WithHTTPClient(client *http.Client) *PubSubClient {
+	c.http = client
+	return c
+}
+
+// WithEndpoint sets the URL the requests are sent to, like http://localhost:8085 for the emulator.
+func (c *PubSubClient) WithEndpoint(endpoint string) *PubSubClient {
+	c.endpoint = strings.TrimSuffix(endpoint, "/")
+	return c
+}
+
+// CreateTopic creates a topic. A topic that already exists is not an error.
+func (c *PubSubClient) CreateTopic(ctx context.Context, topic string) error {
+	err := c.do(ctx, http.MethodPut, c.topic(topic), struct{}{}, nil)
+	if isAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateSubscription creates a pull subscription of a topic, or a push subscription when the push
+// endpoint is set. A subscription that already exists is not an error.
+func (c *PubSubClient) CreateSubscription(ctx context.Context, subscription string, topic string, ackDeadline time.Duration, pushEndpoint string) error {
+	body := map[string]interface{}{
+		"topic":              c.topic(topic),
+		"ackDeadlineSeconds": int(ackDeadline / time.Second),
+	}
+	if len(pushEndpoint) > 0 {
+		body["pushConfig"] = map[string]string{"pushEndpoint": pushEndpoint}
+	}
+
+	err := c.do(ctx, http.MethodPut, c.subscription(subscription), body, nil)
+	if isAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// topic returns the full name of a topic.
+func (c *PubSubClient) topic(name string) string {
+	return fmt.Sprintf("projects/%s/topics/%s", c.project, name)
+}
+
+// subscription returns the full name of a subscription.
+func (c *PubSubClient) subscription(name string) string {
+	return fmt.Sprintf("projects/%s/subscriptions/%s", c.project, name)
+}
+
+// pubSubError is an error returned by the Pub/Sub API.
+type pubSubError struct {
+	StatusCode int
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+}
+
+func (e *pubSubError) Error() string {
+	return fmt.Sprintf("pubsub: %d %s: %s", e.StatusCode, e.Status, e.Message)
+}
+
+// isAlreadyExists reports whether the error is returned for a resource that already exists.
+func isAlreadyExists(err error) bool {
+	pe, ok := err.(*pubSubError)
+	return ok && pe.StatusCode == http.StatusConflict
+}
+
+// do sends a request to the resource with the JSON encoding of in as the body, and decodes the
+// response into out when it isn't nil.
+func (c *PubSubClient) do(ctx context.Context, method string, resource string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", c.endpoint, resource), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		var e struct {
+			Error pubSubError `json:"error"`
+		}
+		json.Unmarshal(data, &e)
+		e.Error.StatusCode = res.StatusCode
+		if len(e.Error.Message) == 0 {
+			e.Error.Message = strings.TrimSpace(string(data))
+		}
+		return &e.Error
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// metadataTransport adds the access token of the service account of a Cloud Run service to the
+// requests, and gets a new token from the metadata server before the token expires.
+type metadataTransport struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (t *metadataTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("error getting access token: %s", err.Error())
+	}
+
+	// A RoundTripper must not change the request it was given
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// accessToken returns a token that is valid for at least another minute.
+func (t *metadataTransport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.token) > 0 && time.Now().Add(time.Minute).Before(t.expires) {
+		return t.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %s", res.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	t.token = token.AccessToken
+	t.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return t.token, nil
+}
+
+// pubSubMessage is a message in the Pub/Sub API. The data is base64 encoded by encoding/json.
+type pubSubMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	MessageID  string            `json:"messageId,omitempty"`
+}
+
+// message converts the Pub/Sub message into a Message.
+func (m pubSubMessage) message() Message {
+	msg := messageFromAttributes(m.Attributes, m.Data)
+
+	// Messages sent without an ID are identified by the ID Pub/Sub gave them
+	if len(msg.ID) == 0 {
+		msg.ID = m.MessageID
+	}
+	return msg
+}
+
+// PubSubPublisher publishes messages to Google Cloud Pub/Sub topics.
+type PubSubPublisher struct {
+	client *PubSubClient
+	topics map[string]string
+	topic  string
+}
+
+// NewPubSubPublisher creates a PubSubPublisher. The topics map the name of an event, like
+// acmeserverless.PaymentRequestedEventName, to the topic it must be sent to.
+func NewPubSubPublisher(client *PubSubClient, topics map[string]string) *PubSubPublisher {
+	return &PubSubPublisher{
+		client: client,
+		topics: topics,
+	}
+}
+
+// WithDefaultTopic sets the topic the events without a topic of their own are sent to.
+func (p *PubSubPublisher) WithDefaultTopic(topic string) *PubSubPublisher {
+	p.topic = topic
+	return p
+}
+
+// Publish sends the payload of the message as the data of a Pub/Sub message to the topic configured
+// for its event. The attributes of the message are sent as the attributes of the Pub/Sub message.
+func (p *PubSubPublisher) Publish(ctx context.Context, m Message) (string, error) {
+	topic, ok := p.topics[m.Name]
+	if !ok {
+		topic = p.topic
+	}
+	if len(topic) == 0 {
+		return "", fmt.Errorf("no topic configured for event %s", m.Name)
+	}
+
+	in := struct {
+		Messages []pubSubMessage `json:"messages"`
+	}{
+		Messages: []pubSubMessage{{Data: m.Payload, Attributes: m.Attributes()}},
+	}
+
+	var out struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	if err := p.client.do(ctx, http.MethodPost, p.client.topic(topic)+":publish", in, &out); err != nil {
+		return "", err
+	}
+
+	if len(out.MessageIDs) == 0 {
+		return "", fmt.Errorf("pubsub: no message ID returned")
+	}
+	return out.MessageIDs[0], nil
+}
+
+// PubSubSubscriber receives messages from a pull subscription of Google Cloud Pub/Sub. Messages
+// that aren't acknowledged within the ack deadline of the subscription are received again, and
+// moved to the dead-letter topic of the subscription when it has one.
+type PubSubSubscriber struct {
+	client       *PubSubClient
+	subscription string
+	wait         time.Duration
+	interval     time.Duration
+	maxMessages  int
+}
+
+// NewPubSubSubscriber creates a PubSubSubscriber for a pull subscription.
+func NewPubSubSubscriber(client *PubSubClient, subscription string) *PubSubSubscriber {
+	return &PubSubSubscriber{
+		client:       client,
+		subscription: subscription,
+		wait:         DefaultWaitTime,
+		interval:     time.Second,
+		maxMessages:  10,
+	}
+}
+
+// WithWaitTime sets the time Receive waits for messages to arrive.
+func (s *PubSubSubscriber) WithWaitTime(wait time.Duration) *PubSubSubscriber {
+	s.wait = wait
+	return s
+}
+
+// Receive pulls at most 10 messages. Pub/Sub may return no messages while there are messages, so
+// Receive pulls again every second until messages arrive or the wait time passed.
+func (s *PubSubSubscriber) Receive(ctx context.Context) ([]Delivery, error) {
+	deadline := time.Now().Add(s.wait)
+
+	for {
+		var out struct {
+			ReceivedMessages []struct {
+				AckID           string        `json:"ackId"`
+				Message         pubSubMessage `json:"message"`
+				DeliveryAttempt int           `json:"deliveryAttempt"`
+			} `json:"receivedMessages"`
+		}
+		if err := s.client.do(ctx, http.MethodPost, s.client.subscription(s.subscription)+":pull", map[string]interface{}{
+			"maxMessages": s.maxMessages,
+		}, &out); err != nil {
+			return nil, err
+		}
+
+		if len(out.ReceivedMessages) > 0 {
+			deliveries := make([]Delivery, len(out.ReceivedMessages))
+			for i, rm := range out.ReceivedMessages {
+				deliveries[i] = Delivery{
+					Message: rm.Message.message(),
+					Attempt: attempt(rm.DeliveryAttempt),
+					receipt: rm.AckID,
+				}
+			}
+			return deliveries, nil
+		}
+
+		if !time.Now().Add(s.interval).Before(deadline) {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Ack acknowledges the message, so it isn't delivered again.
+func (s *PubSubSubscriber) Ack(ctx context.Context, d Delivery) error {
+	return s.client.do(ctx, http.MethodPost, s.client.subscription(s.subscription)+":acknowledge", map[string]interface{}{
+		"ackIds": []string{d.receipt},
+	}, nil)
+}
+
+// Nack sets the ack deadline of the message to 0, so it is delivered again right away.
+func (s *PubSubSubscriber) Nack(ctx context.Context, d Delivery) error {
+	return s.client.do(ctx, http.MethodPost, s.client.subscription(s.subscription)+":modifyAckDeadline", map[string]interface{}{
+		"ackIds":             []string{d.receipt},
+		"ackDeadlineSeconds": 0,
+	}, nil)
+}
+
+// attempt returns the delivery attempt of a Pub/Sub message, which is only set for subscriptions
+// with a dead-letter topic.
+func attempt(deliveryAttempt int) int {
+	if deliveryAttempt < 1 {
+		return 1
+	}
+	return deliveryAttempt
+}
+
+// PubSubPushHandler returns an http.Handler for the push endpoint of a push subscription, which
+// calls h for every message Pub/Sub pushes. A message is acknowledged when h returns nil, and
+// pushed again later when h returns an error. Cloud Run services can use it as the handler of the
+// URL of the push subscription. The handler doesn't authenticate requests, so it must either be
+// wrapped with the Handler of a PushAuth, or only be reachable through an authenticating layer like
+// a Cloud Run service that doesn't allow unauthenticated invocations.
+func PubSubPushHandler(h Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var push struct {
+			Message         pubSubMessage `json:"message"`
+			Subscription    string        `json:"subscription"`
+			DeliveryAttempt int           `json:"deliveryAttempt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			// Pub/Sub pushes the request again, until it moves it to the dead-letter topic of the
+			// subscription when it has one
+			http.Error(w, fmt.Sprintf("error reading push request: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		d := Delivery{
+			Message: push.Message.message(),
+			Attempt: attempt(push.DeliveryAttempt),
+		}
+		if err := h(r.Context(), d); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
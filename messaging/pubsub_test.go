@@ -0,0 +1,310 @@
+package messaging
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+func testMessage(id string) Message {
+	return Message{
+		ID:   id,
+		Name: acmeserverless.PaymentRequestedEventName,
+		Metadata: acmeserverless.Metadata{
+			Domain: acmeserverless.PaymentDomain,
+			Source: "test",
+			Type:   "PaymentRequested",
+			Status: acmeserverless.DefaultSuccessStatus,
+		},
+		Payload: json.RawMessage(`{"data":{"orderID":"order-1"}}`),
+	}
+}
+
+func TestPubSubPushHandler(t *testing.T) {
+	var received []Delivery
+	srv := httptest.NewServer(PubSubPushHandler(func(ctx context.Context, d Delivery) error {
+		received = append(received, d)
+		if d.ID == "fail" {
+			return errors.New("handler failed")
+		}
+		return nil
+	}))
+	defer srv.Close()
+
+	// The HTTPPublisher sends the same request as a push subscription
+	p := NewHTTPPublisher(srv.URL)
+	if _, err := p.Publish(context.Background(), testMessage("1")); err != nil {
+		t.Fatalf("error pushing message: %s", err.Error())
+	}
+	if len(received) != 1 {
+		t.Fatalf("handled %d messages, want 1", len(received))
+	}
+	d := received[0]
+	if d.ID != "1" || d.Name != acmeserverless.PaymentRequestedEventName || d.Metadata.Source != "test" || d.Attempt != 1 {
+		t.Fatalf("handled %+v, want message 1", d)
+	}
+	if string(d.Payload) != `{"data":{"orderID":"order-1"}}` {
+		t.Fatalf("payload is %s, want the payload that was sent", d.Payload)
+	}
+
+	// A failing handler makes Pub/Sub push the message again
+	if _, err := p.Publish(context.Background(), testMessage("fail")); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("error is %v, want 500 Internal Server Error", err)
+	}
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("error sending request: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET returned %s, want 405 Method Not Allowed", res.Status)
+	}
+
+	res, err = http.Post(srv.URL, "application/json", strings.NewReader(`{"message":`))
+	if err != nil {
+		t.Fatalf("error sending request: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("malformed request returned %s, want 400 Bad Request", res.Status)
+	}
+
+	// The delivery attempt of a subscription with a dead-letter topic is passed on
+	body := `{"message":{"data":"e30=","attributes":{"id":"2","event":"PaymentRequestedEvent"}},"deliveryAttempt":3}`
+	res, err = http.Post(srv.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error sending request: %s", err.Error())
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent || received[len(received)-1].Attempt != 3 {
+		t.Fatalf("returned %s with attempt %d, want 204 No Content with attempt 3", res.Status, received[len(received)-1].Attempt)
+	}
+}
+
+// signer signs OIDC tokens like Google does for push subscriptions, and serves its key like
+// GoogleCertsURL.
+type signer struct {
+	key  *rsa.PrivateKey
+	kid  string
+	srv  *httptest.Server
+	hits int
+}
+
+func newSigner(t *testing.T) *signer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err.Error())
+	}
+
+	s := &signer{key: key, kid: "key-1"}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits++
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": s.kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	return s
+}
+
+func (s *signer) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	segment := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := segment(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatalf("error signing token: %s", err.Error())
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestPushAuth(t *testing.T) {
+	const (
+		audience       = "https://payment.example.com/events"
+		serviceAccount = "pubsub-push@acmeserverless.iam.gserviceaccount.com"
+	)
+
+	s := newSigner(t)
+	defer s.srv.Close()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	auth := NewPushAuth(audience, serviceAccount).WithCertsURL(s.srv.URL).WithClock(func() time.Time { return now })
+
+	handled := 0
+	srv := httptest.NewServer(auth.Handler(PubSubPushHandler(func(ctx context.Context, d Delivery) error {
+		handled++
+		return nil
+	})))
+	defer srv.Close()
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            "https://accounts.google.com",
+			"aud":            audience,
+			"iat":            now.Add(-time.Minute).Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+			"email":          serviceAccount,
+			"email_verified": true,
+		}
+	}
+	with := func(claim string, value interface{}) map[string]interface{} {
+		c := valid()
+		c[claim] = value
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"valid token", "Bearer " + s.token(t, s.kid, valid()), http.StatusNoContent},
+		{"no token", "", http.StatusUnauthorized},
+		{"malformed token", "Bearer not-a-token", http.StatusUnauthorized},
+		{"other audience", "Bearer " + s.token(t, s.kid, with("aud", "https://other.example.com")), http.StatusUnauthorized},
+		{"other service account", "Bearer " + s.token(t, s.kid, with("email", "someone@example.com")), http.StatusUnauthorized},
+		{"unverified email", "Bearer " + s.token(t, s.kid, with("email_verified", false)), http.StatusUnauthorized},
+		{"other issuer", "Bearer " + s.token(t, s.kid, with("iss", "https://example.com")), http.StatusUnauthorized},
+		{"expired token", "Bearer " + s.token(t, s.kid, with("exp", now.Add(-2*time.Minute).Unix())), http.StatusUnauthorized},
+		{"unknown key", "Bearer " + s.token(t, "key-2", valid()), http.StatusUnauthorized},
+		{"changed claims", "Bearer " + tamper(s.token(t, s.kid, valid())), http.StatusUnauthorized},
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"message": pubSubMessage{Data: []byte(`{}`), Attributes: testMessage("1").Attributes(), MessageID: "1"},
+	})
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(string(body)))
+		if len(tt.header) > 0 {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: error sending request: %s", tt.name, err.Error())
+		}
+		res.Body.Close()
+
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: returned %s, want %d", tt.name, res.Status, tt.status)
+		}
+	}
+
+	if handled != 1 {
+		t.Fatalf("handled %d messages, want only the one with a valid token", handled)
+	}
+
+	// The keys are cached, and a token with an unknown key only reads them again after
+	// minRefreshInterval
+	if s.hits != 1 {
+		t.Fatalf("read the keys %d times, want 1", s.hits)
+	}
+
+	now = now.Add(minRefreshInterval)
+	if err := auth.Verify(authorized(s.token(t, "key-2", valid()))); err == nil || s.hits != 2 {
+		t.Fatalf("error is %v after reading the keys %d times, want an unknown key after 2", err, s.hits)
+	}
+}
+
+// authorized returns a request with the bearer token.
+func authorized(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// tamper changes the claims of a token without signing it again.
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	claims = []byte(strings.Replace(string(claims), "pubsub-push@", "attacker@", 1))
+	parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+	return strings.Join(parts, ".")
+}
+
+// TestPubSubEmulator publishes and receives messages with the Pub/Sub emulator, and only runs when
+// PUBSUB_EMULATOR_HOST is set.
+func TestPubSubEmulator(t *testing.T) {
+	if len(os.Getenv("PUBSUB_EMULATOR_HOST")) == 0 {
+		t.Skip("PUBSUB_EMULATOR_HOST is not set")
+	}
+
+	ctx := context.Background()
+	client := NewPubSubClient("acmeserverless")
+	name := fmt.Sprintf("messaging-test-%d", time.Now().UnixNano())
+
+	if err := client.CreateTopic(ctx, name); err != nil {
+		t.Fatalf("error creating topic: %s", err.Error())
+	}
+	if err := client.CreateSubscription(ctx, name, name, 10*time.Second, ""); err != nil {
+		t.Fatalf("error creating subscription: %s", err.Error())
+	}
+
+	p := NewPubSubPublisher(client, nil).WithDefaultTopic(name)
+	if _, err := p.Publish(ctx, testMessage("1")); err != nil {
+		t.Fatalf("error publishing message: %s", err.Error())
+	}
+
+	s := NewPubSubSubscriber(client, name).WithWaitTime(10 * time.Second)
+	receive := func() []Delivery {
+		t.Helper()
+		d, err := s.Receive(ctx)
+		if err != nil {
+			t.Fatalf("error receiving messages: %s", err.Error())
+		}
+		return d
+	}
+
+	d := receive()
+	if len(d) != 1 || d[0].ID != "1" || d[0].Name != acmeserverless.PaymentRequestedEventName || d[0].Metadata.Domain != acmeserverless.PaymentDomain {
+		t.Fatalf("received %+v, want message 1", d)
+	}
+
+	// A rejected message is received again right away
+	if err := s.Nack(ctx, d[0]); err != nil {
+		t.Fatalf("error rejecting message: %s", err.Error())
+	}
+	d = receive()
+	if len(d) != 1 || d[0].ID != "1" {
+		t.Fatalf("received %+v, want message 1 again", d)
+	}
+
+	if err := s.Ack(ctx, d[0]); err != nil {
+		t.Fatalf("error acknowledging message: %s", err.Error())
+	}
+	s.WithWaitTime(2 * time.Second)
+	if d := receive(); len(d) != 0 {
+		t.Fatalf("received %+v after the message was acknowledged", d)
+	}
+}
@@ -0,0 +1,257 @@
+package messaging
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GoogleCertsURL is the URL of the keys Google signs OIDC tokens with, in the JSON Web Key format.
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// maxClockSkew is how far the clock of the service may differ from the clock of Google.
+const maxClockSkew = time.Minute
+
+// minRefreshInterval is how long a PushAuth waits before it reads the signing keys again for a
+// token signed with an unknown key, so tokens with made up key IDs don't cause a request each.
+const minRefreshInterval = time.Minute
+
+// PushAuth authenticates the requests of a Pub/Sub push subscription that is configured to send
+// an OIDC token, which Google signs for the service account of the subscription. A request is only
+// accepted when its token is valid, is meant for the audience, and belongs to the service account.
+// It is safe for concurrent use.
+type PushAuth struct {
+	audience       string
+	serviceAccount string
+	certsURL       string
+	client         *http.Client
+	now            func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	expires time.Time
+}
+
+// NewPushAuth creates a PushAuth for the tokens of a push subscription. The audience is the
+// audience set on the subscription, which defaults to the URL of the push endpoint, and the
+// service account is the email address of the service account set on the subscription.
+func NewPushAuth(audience string, serviceAccount string) *PushAuth {
+	return &PushAuth{
+		audience:       audience,
+		serviceAccount: serviceAccount,
+		certsURL:       GoogleCertsURL,
+		client:         http.DefaultClient,
+		now:            time.Now,
+	}
+}
+
+// WithCertsURL sets the URL the signing keys are read from.
+func (a *PushAuth) WithCertsURL(url string) *PushAuth {
+	a.certsURL = url
+	return a
+}
+
+// WithHTTPClient sets the HTTP client that reads the signing keys.
+func (a *PushAuth) WithHTTPClient(client *http.Client) *PushAuth {
+	a.client = client
+	return a
+}
+
+// WithClock sets the function the PushAuth uses to get the current time.
+func (a *PushAuth) WithClock(now func() time.Time) *PushAuth {
+	a.now = now
+	return a
+}
+
+// Handler returns an http.Handler that only passes the requests with a valid token to next, and
+// rejects the other requests with 401 Unauthorized.
+func (a *PushAuth) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.Verify(r); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Verify checks the bearer token in the Authorization header of the request.
+func (a *PushAuth) Verify(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return fmt.Errorf("pushauth: no bearer token")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("pushauth: malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("pushauth: error reading token header: %s", err.Error())
+	}
+	if header.Alg != "RS256" {
+		return fmt.Errorf("pushauth: unsupported algorithm %q", header.Alg)
+	}
+
+	key, err := a.key(r, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("pushauth: error reading token signature: %s", err.Error())
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+		return fmt.Errorf("pushauth: invalid token signature")
+	}
+
+	var claims struct {
+		Issuer        string `json:"iss"`
+		Audience      string `json:"aud"`
+		Expires       int64  `json:"exp"`
+		IssuedAt      int64  `json:"iat"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("pushauth: error reading token claims: %s", err.Error())
+	}
+
+	now := a.now()
+	switch {
+	case claims.Issuer != "accounts.google.com" && claims.Issuer != "https://accounts.google.com":
+		return fmt.Errorf("pushauth: token issued by %q", claims.Issuer)
+	case claims.Audience != a.audience:
+		return fmt.Errorf("pushauth: token for audience %q", claims.Audience)
+	case now.Add(-maxClockSkew).After(time.Unix(claims.Expires, 0)):
+		return fmt.Errorf("pushauth: token expired")
+	case now.Add(maxClockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return fmt.Errorf("pushauth: token issued in the future")
+	case claims.Email != a.serviceAccount || !claims.EmailVerified:
+		return fmt.Errorf("pushauth: token of %q", claims.Email)
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key with the ID, and reads the keys again when they expired or the key
+// is unknown, as Google rotates its keys.
+func (a *PushAuth) key(r *http.Request, kid string) (*rsa.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	key, ok := a.keys[kid]
+	if ok && now.Before(a.expires) {
+		return key, nil
+	}
+
+	if !now.Before(a.expires) || now.Sub(a.fetched) >= minRefreshInterval {
+		if err := a.refresh(r); err != nil {
+			return nil, fmt.Errorf("pushauth: error reading signing keys: %s", err.Error())
+		}
+	}
+
+	key, ok = a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("pushauth: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// refresh reads the signing keys, and keeps them as long as the Cache-Control header allows.
+func (a *PushAuth) refresh(r *http.Request) error {
+	req, err := http.NewRequest(http.MethodGet, a.certsURL, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(r.Context())
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", a.certsURL, res.Status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("error reading key %s: %s", k.Kid, err.Error())
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("error reading key %s: %s", k.Kid, err.Error())
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	a.keys = keys
+	a.fetched = a.now()
+	a.expires = a.fetched.Add(maxAge(res.Header.Get("Cache-Control")))
+	return nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, or an hour when it has none.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		if s, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+			return time.Duration(s) * time.Second
+		}
+	}
+	return time.Hour
+}
@@ -0,0 +1,206 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DefaultWaitTime is the time a Subscriber waits for messages to arrive, which is the longest
+// Amazon SQS allows.
+const DefaultWaitTime = 20 * time.Second
+
+// SQSPublisher publishes messages to Amazon SQS queues.
+type SQSPublisher struct {
+	svc    *sqs.SQS
+	queues map[string]string
+	queue  string
+}
+
+// NewSQSPublisher creates an SQSPublisher. The queues map the name of an event, like
+// acmeserverless.PaymentRequestedEventName, to the URL of the queue it must be sent to.
+func NewSQSPublisher(svc *sqs.SQS, queues map[string]string) *SQSPublisher {
+	return &SQSPublisher{
+		svc:    svc,
+		queues: queues,
+	}
+}
+
+// WithDefaultQueue sets the URL of the queue the events without a queue of their own are sent to.
+func (p *SQSPublisher) WithDefaultQueue(url string) *SQSPublisher {
+	p.queue = url
+	return p
+}
+
+// Publish sends the payload of the message to the queue configured for its event. The attributes
+// of the message are sent as message attributes.
+func (p *SQSPublisher) Publish(ctx context.Context, m Message) (string, error) {
+	queue, ok := p.queues[m.Name]
+	if !ok {
+		queue = p.queue
+	}
+	if len(queue) == 0 {
+		return "", fmt.Errorf("no queue configured for event %s", m.Name)
+	}
+
+	// Amazon SQS doesn't allow empty attribute values, which Attributes leaves out
+	attrs := make(map[string]*sqs.MessageAttributeValue)
+	for k, v := range m.Attributes() {
+		attrs[k] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+
+	smo, err := p.svc.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queue),
+		MessageBody:       aws.String(string(m.Payload)),
+		MessageAttributes: attrs,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(smo.MessageId), nil
+}
+
+// SQSSubscriber receives messages from an Amazon SQS queue. Messages that are rejected too often
+// are moved to the dead-letter queue of the redrive policy of the queue.
+type SQSSubscriber struct {
+	svc         *sqs.SQS
+	queue       string
+	wait        time.Duration
+	maxMessages int64
+	eventBridge bool
+}
+
+// NewSQSSubscriber creates an SQSSubscriber for the queue with the given URL, which receives the
+// messages of an SQSPublisher.
+func NewSQSSubscriber(svc *sqs.SQS, url string) *SQSSubscriber {
+	return &SQSSubscriber{
+		svc:         svc,
+		queue:       url,
+		wait:        DefaultWaitTime,
+		maxMessages: 10,
+	}
+}
+
+// NewEventBridgeSubscriber creates an SQSSubscriber for the queue with the given URL, which is the
+// target of a rule of an Amazon EventBridge event bus. The messages of an EventBridgePublisher are
+// taken from the events EventBridge sends to the queue.
+func NewEventBridgeSubscriber(svc *sqs.SQS, url string) *SQSSubscriber {
+	s := NewSQSSubscriber(svc, url)
+	s.eventBridge = true
+	return s
+}
+
+// WithWaitTime sets the time Receive waits for messages to arrive, which is at most 20 seconds.
+func (s *SQSSubscriber) WithWaitTime(wait time.Duration) *SQSSubscriber {
+	s.wait = wait
+	return s
+}
+
+// Receive waits for messages to arrive and returns at most 10 of them.
+func (s *SQSSubscriber) Receive(ctx context.Context) ([]Delivery, error) {
+	rmo, err := s.svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(s.queue),
+		MaxNumberOfMessages:   aws.Int64(s.maxMessages),
+		WaitTimeSeconds:       aws.Int64(int64(s.wait / time.Second)),
+		AttributeNames:        aws.StringSlice([]string{sqs.MessageSystemAttributeNameApproximateReceiveCount}),
+		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(rmo.Messages))
+	for _, msg := range rmo.Messages {
+		d := Delivery{
+			receipt: aws.StringValue(msg.ReceiptHandle),
+			Attempt: 1,
+		}
+
+		if n, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount])); err == nil {
+			d.Attempt = n
+		}
+
+		// A body that isn't an EventBridge event is passed on as it is, so the handler can reject it
+		// and the redrive policy of the queue moves it aside
+		var ok bool
+		if s.eventBridge {
+			d.Message, ok = messageFromEventBridge([]byte(aws.StringValue(msg.Body)))
+		}
+		if !ok {
+			attrs := make(map[string]string, len(msg.MessageAttributes))
+			for k, v := range msg.MessageAttributes {
+				attrs[k] = aws.StringValue(v.StringValue)
+			}
+			d.Message = messageFromAttributes(attrs, []byte(aws.StringValue(msg.Body)))
+		}
+
+		// Messages sent without an ID are identified by the ID Amazon SQS gave them
+		if len(d.ID) == 0 {
+			d.ID = aws.StringValue(msg.MessageId)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// Ack removes the message from the queue.
+func (s *SQSSubscriber) Ack(ctx context.Context, d Delivery) error {
+	_, err := s.svc.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(s.queue),
+		ReceiptHandle: aws.String(d.receipt),
+	})
+	return err
+}
+
+// Nack makes the message visible again right away, instead of after the visibility timeout of the
+// queue.
+func (s *SQSSubscriber) Nack(ctx context.Context, d Delivery) error {
+	_, err := s.svc.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(s.queue),
+		ReceiptHandle:     aws.String(d.receipt),
+		VisibilityTimeout: aws.Int64(0),
+	})
+	return err
+}
+
+// eventBridgeEvent contains the fields of an Amazon EventBridge event that a message is read from.
+type eventBridgeEvent struct {
+	ID         string          `json:"id"`
+	DetailType string          `json:"detail-type"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// messageFromEventBridge reads a message from the event that EventBridge sent to a queue. The ID
+// of the message is the ID an EventBridgePublisher sent as a resource, or the ID of the event for
+// events that were sent in another way. It returns false when the body isn't an EventBridge event.
+func messageFromEventBridge(body []byte) (Message, bool) {
+	var event eventBridgeEvent
+	if err := json.Unmarshal(body, &event); err != nil || len(event.DetailType) == 0 {
+		return Message{}, false
+	}
+
+	id := event.ID
+	for _, r := range event.Resources {
+		if strings.HasPrefix(r, resourcePrefix) {
+			id = strings.TrimPrefix(r, resourcePrefix)
+		}
+	}
+
+	return messageFromAttributes(map[string]string{
+		AttributeID:    id,
+		AttributeEvent: event.DetailType,
+	}, event.Detail), true
+}
@@ -84,6 +84,18 @@ func main() {
 			return err
 		}
 
+		// Create the Payment Refund Queue
+		paymentRefundQueue, err := sqs.NewQueue(ctx, fmt.Sprintf("%s-%s-payment-refund", ctx.Stack(), ctx.Project()), &sqs.QueueArgs{
+			MessageRetentionSeconds:  pulumi.Int(120),
+			Name:                     pulumi.String(fmt.Sprintf("%s-%s-payment-refund", ctx.Stack(), ctx.Project())),
+			VisibilityTimeoutSeconds: pulumi.Int(30),
+			Tags:                     pulumi.Map(tagMap),
+			RedrivePolicy:            paymentRedrivePolicy,
+		})
+		if err != nil {
+			return err
+		}
+
 		// Create the Shipment Error Queue
 		shipmentErrQueue, err := sqs.NewQueue(ctx, fmt.Sprintf("%s-%s-shipment-error", ctx.Stack(), ctx.Project()), &sqs.QueueArgs{
 			MessageRetentionSeconds:  pulumi.Int(120),
@@ -125,6 +137,34 @@ func main() {
 			return err
 		}
 
+		// Create the Order Error Queue
+		orderErrQueue, err := sqs.NewQueue(ctx, fmt.Sprintf("%s-%s-order-error", ctx.Stack(), ctx.Project()), &sqs.QueueArgs{
+			MessageRetentionSeconds:  pulumi.Int(120),
+			Name:                     pulumi.String(fmt.Sprintf("%s-%s-order-error", ctx.Stack(), ctx.Project())),
+			VisibilityTimeoutSeconds: pulumi.Int(30),
+			Tags:                     pulumi.Map(tagMap),
+		})
+		if err != nil {
+			return err
+		}
+
+		// The redrive policy for the Order Cancelled Queue is based on the ARN of the Order Error Queue
+		orderRedrivePolicy := orderErrQueue.Arn.ApplyString(func(name string) string {
+			return fmt.Sprintf("{\"maxReceiveCount\":1,\"deadLetterTargetArn\":\"%s\"}", name)
+		})
+
+		// Create the Order Cancelled Queue
+		orderCancelledQueue, err := sqs.NewQueue(ctx, fmt.Sprintf("%s-%s-order-cancelled", ctx.Stack(), ctx.Project()), &sqs.QueueArgs{
+			MessageRetentionSeconds:  pulumi.Int(120),
+			Name:                     pulumi.String(fmt.Sprintf("%s-%s-order-cancelled", ctx.Stack(), ctx.Project())),
+			VisibilityTimeoutSeconds: pulumi.Int(30),
+			Tags:                     pulumi.Map(tagMap),
+			RedrivePolicy:            orderRedrivePolicy,
+		})
+		if err != nil {
+			return err
+		}
+
 		// Export the ARNs and Names of the queues
 		ctx.Export("PaymentErrorQueue::Arn", paymentErrQueue.Arn)
 		ctx.Export("PaymentErrorQueue::Name", paymentErrQueue.Name)
@@ -132,12 +172,18 @@ func main() {
 		ctx.Export("PaymentRequestQueue::Name", paymentRequestQueue.Name)
 		ctx.Export("PaymentResponseQueue::Arn", paymentResponseQueue.Arn)
 		ctx.Export("PaymentResponseQueue::Name", paymentResponseQueue.Name)
+		ctx.Export("PaymentRefundQueue::Arn", paymentRefundQueue.Arn)
+		ctx.Export("PaymentRefundQueue::Name", paymentRefundQueue.Name)
 		ctx.Export("ShipmentErrorQueue::Arn", shipmentErrQueue.Arn)
 		ctx.Export("ShipmentErrorQueue::Name", shipmentErrQueue.Name)
 		ctx.Export("ShipmentRequestQueue::Arn", shipmentRequestQueue.Arn)
 		ctx.Export("ShipmentRequestQueue::Name", shipmentRequestQueue.Name)
 		ctx.Export("ShipmentResponseQueue::Arn", shipmentResponseQueue.Arn)
 		ctx.Export("ShipmentResponseQueue::Name", shipmentResponseQueue.Name)
+		ctx.Export("OrderErrorQueue::Arn", orderErrQueue.Arn)
+		ctx.Export("OrderErrorQueue::Name", orderErrQueue.Name)
+		ctx.Export("OrderCancelledQueue::Arn", orderCancelledQueue.Arn)
+		ctx.Export("OrderCancelledQueue::Name", orderCancelledQueue.Name)
 
 		return nil
 	})
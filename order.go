@@ -97,3 +97,78 @@ type OrderStatus struct {
 func (r *OrderStatus) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+// OrderCancelled is the event sent by the Order service when an order is cancelled and all
+// resources held for it are released.
+type OrderCancelled struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data OrderCancellation `json:"data"`
+}
+
+// OrderCancellation is the data that the order service emits when an order is cancelled.
+type OrderCancellation struct {
+	// OrderID uniquely represents an order
+	OrderID string `json:"order_id"`
+
+	// UserID is the unique representation of the user
+	UserID string `json:"userid"`
+
+	// Reason explains why the order was cancelled
+	Reason string `json:"reason"`
+}
+
+// UnmarshalOrderCancelled parses the JSON-encoded data and stores the result in an
+// OrderCancelled.
+func UnmarshalOrderCancelled(data []byte) (OrderCancelled, error) {
+	var r OrderCancelled
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of OrderCancelled.
+func (e *OrderCancelled) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// OrderUpdated is the event sent when an order is placed or its status changes.
+type OrderUpdated struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data OrderChange `json:"data"`
+}
+
+// OrderChange is the data of an order that was placed or changed. It doesn't contain the payment
+// details of the order.
+type OrderChange struct {
+	// OrderID uniquely represents an order
+	OrderID string `json:"order_id"`
+
+	// UserID is the unique representation of the user
+	UserID string `json:"userid"`
+
+	// Status is the status of the order after the change
+	Status string `json:"status"`
+
+	// PreviousStatus is the status of the order before the change, which is empty for new orders
+	PreviousStatus string `json:"previousStatus,omitempty"`
+
+	// Total represents the monetary value of the order
+	Total string `json:"total,omitempty"`
+}
+
+// UnmarshalOrderUpdated parses the JSON-encoded data and stores the result in an OrderUpdated.
+func UnmarshalOrderUpdated(data []byte) (OrderUpdated, error) {
+	var r OrderUpdated
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of OrderUpdated.
+func (e *OrderUpdated) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
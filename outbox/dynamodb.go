@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SentRetention is how long sent messages are kept before the TTL of the table removes them.
+const SentRetention = 7 * 24 * time.Hour
+
+// PendingIndex is the name of the sparse global secondary index with Pending as hash key and SK as
+// range key. Only pending messages have the Pending attribute, so the index contains nothing else.
+const PendingIndex = "Pending-index"
+
+// DynamoDBStore is an outbox in the Amazon DynamoDB table of the shop, using the access pattern
+// PK = OUTBOX SK = CreatedAt#ID.
+type DynamoDBStore struct {
+	dbs          *dynamodb.DynamoDB
+	table        string
+	pendingIndex string
+}
+
+// NewDynamoDBStore creates a DynamoDBStore that uses the given table. Pending uses the index
+// PendingIndex, which can be changed using WithPendingIndex.
+func NewDynamoDBStore(dbs *dynamodb.DynamoDB, table string) *DynamoDBStore {
+	return &DynamoDBStore{
+		dbs:          dbs,
+		table:        table,
+		pendingIndex: PendingIndex,
+	}
+}
+
+// WithPendingIndex sets the name of the global secondary index Pending uses. With an empty name
+// Pending queries the OUTBOX partition and filters the messages on their status, which reads all sent
+// messages until the TTL of the table removes them, but works for tables without the index.
+func (d *DynamoDBStore) WithPendingIndex(name string) *DynamoDBStore {
+	d.pendingIndex = name
+	return d
+}
+
+// TransactItem returns the write that stores the message in the outbox, so it can be added to a
+// TransactWriteItems call.
+func (d *DynamoDBStore) TransactItem(m Message) (*dynamodb.TransactWriteItem, error) {
+	payload, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue)
+	item["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(PK),
+	}
+	item["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(m.SortKey()),
+	}
+	item["Status"] = &dynamodb.AttributeValue{
+		S: aws.String(m.Status),
+	}
+	item["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+	if m.Status == StatusPending {
+		item["Pending"] = &dynamodb.AttributeValue{
+			S: aws.String(PK),
+		}
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: aws.String(d.table),
+			Item:      item,
+		},
+	}, nil
+}
+
+// Write stores the messages in the outbox in the same transaction as the business write, like the
+// Update that stores an order. Either all items are written or none are. DynamoDB allows at most
+// 25 items in a single transaction.
+func (d *DynamoDBStore) Write(ctx context.Context, write *dynamodb.TransactWriteItem, msgs ...Message) error {
+	items := make([]*dynamodb.TransactWriteItem, 0, len(msgs)+1)
+	if write != nil {
+		items = append(items, write)
+	}
+
+	for _, m := range msgs {
+		item, err := d.TransactItem(m)
+		if err != nil {
+			return fmt.Errorf("error marshalling message: %s", err.Error())
+		}
+		items = append(items, item)
+	}
+
+	_, err := d.dbs.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+
+	return err
+}
+
+// Pending returns at most limit messages that have not been published, oldest first. The index is
+// eventually consistent, so a message that was just marked sent can be returned again, and is then
+// published twice.
+func (d *DynamoDBStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(PK),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		IndexName:                 aws.String(d.pendingIndex),
+		KeyConditionExpression:    aws.String("Pending = :pk"),
+		ExpressionAttributeValues: em,
+		Limit:                     aws.Int64(int64(limit)),
+	}
+
+	// Without the index all messages are read, and the sent ones are filtered out
+	if len(d.pendingIndex) == 0 {
+		em[":pending"] = &dynamodb.AttributeValue{
+			S: aws.String(StatusPending),
+		}
+		qi = &dynamodb.QueryInput{
+			TableName:                 aws.String(d.table),
+			KeyConditionExpression:    aws.String("PK = :pk"),
+			FilterExpression:          aws.String("#status = :pending"),
+			ExpressionAttributeNames:  map[string]*string{"#status": aws.String("Status")},
+			ExpressionAttributeValues: em,
+			ConsistentRead:            aws.Bool(true),
+		}
+	}
+
+	var msgs []Message
+	var merr error
+
+	err := d.dbs.QueryPagesWithContext(ctx, qi, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			m, err := UnmarshalMessage([]byte(aws.StringValue(item["Payload"].S)))
+			if err != nil {
+				merr = err
+				return false
+			}
+			msgs = append(msgs, m)
+			if len(msgs) == limit {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msgs, merr
+}
+
+// MarkSent marks the message as published, which removes it from the PendingIndex. Sent messages are
+// removed by the TTL of the table after SentRetention.
+func (d *DynamoDBStore) MarkSent(ctx context.Context, m Message) error {
+	now := time.Now().UTC()
+	m.Status = StatusSent
+	m.SentAt = &now
+
+	payload, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(PK),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(m.SortKey()),
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table data elements
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":status"] = &dynamodb.AttributeValue{
+		S: aws.String(StatusSent),
+	}
+	em[":payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+	em[":ttl"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(now.Add(SentRetention).Unix(), 10)),
+	}
+
+	_, err = d.dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       km,
+		ExpressionAttributeNames:  map[string]*string{"#status": aws.String("Status"), "#ttl": aws.String("TTL")},
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET #status = :status, Payload = :payload, #ttl = :ttl REMOVE Pending"),
+	})
+
+	return err
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an outbox that keeps its messages in memory. It is meant for tests and local
+// development, and is safe for concurrent use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	messages map[string]Message
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages: make(map[string]Message),
+	}
+}
+
+// Add stores the message in the outbox.
+func (s *MemoryStore) Add(m Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[m.ID] = m
+}
+
+// Messages returns all messages in the outbox, sent or not, ordered by SortKey.
+func (s *MemoryStore) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]Message, 0, len(s.messages))
+	for _, m := range s.messages {
+		msgs = append(msgs, m)
+	}
+
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].SortKey() < msgs[j].SortKey()
+	})
+
+	return msgs
+}
+
+// Pending returns at most limit messages that have not been published, oldest first.
+func (s *MemoryStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	var msgs []Message
+	for _, m := range s.Messages() {
+		if len(msgs) == limit {
+			break
+		}
+		if m.Status == StatusPending {
+			msgs = append(msgs, m)
+		}
+	}
+
+	return msgs, nil
+}
+
+// MarkSent marks the message as published.
+func (s *MemoryStore) MarkSent(ctx context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.messages[m.ID]
+	if !ok {
+		return fmt.Errorf("message %s not found", m.ID)
+	}
+
+	now := time.Now().UTC()
+	stored.Status = StatusSent
+	stored.SentAt = &now
+	s.messages[m.ID] = stored
+	return nil
+}
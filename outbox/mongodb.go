@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMessage is a Message as it is stored in MongoDB.
+type mongoMessage struct {
+	ID        string     `bson:"_id"`
+	PK        string     `bson:"PK"`
+	SK        string     `bson:"SK"`
+	Status    string     `bson:"Status"`
+	Payload   string     `bson:"Payload"`
+	CreatedAt time.Time  `bson:"CreatedAt"`
+	ExpireAt  *time.Time `bson:"ExpireAt,omitempty"`
+}
+
+// MongoStore is an outbox in a MongoDB collection of the shop, like the order collection. The
+// messages are stored as documents with PK OUTBOX next to the documents of the business data.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore creates a MongoStore that stores messages in the given collection.
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{
+		coll: coll,
+	}
+}
+
+// EnsureIndexes creates the index Pending uses to find the pending messages, and the TTL index that
+// lets MongoDB remove sent messages after SentRetention. Creating an index that already exists does
+// nothing.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "PK", Value: 1}, {Key: "Status", Value: 1}, {Key: "SK", Value: 1}},
+			Options: options.Index().SetName("Outbox_pending"),
+		},
+		{
+			Keys:    bson.D{{Key: "ExpireAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("ExpireAt_ttl"),
+		},
+	})
+
+	return err
+}
+
+// Write runs the business write and stores the messages in a single transaction. The write must use
+// the SessionContext it is given for all operations that should be part of the transaction. Either
+// all writes succeed or none do. MongoDB only supports transactions on replica sets.
+func (s *MongoStore) Write(ctx context.Context, write func(sc mongo.SessionContext) error, msgs ...Message) error {
+	docs := make([]interface{}, 0, len(msgs))
+	for _, m := range msgs {
+		payload, err := m.Marshal()
+		if err != nil {
+			return err
+		}
+
+		docs = append(docs, mongoMessage{
+			ID:        m.ID,
+			PK:        PK,
+			SK:        m.SortKey(),
+			Status:    m.Status,
+			Payload:   string(payload),
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	return s.coll.Database().Client().UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, func(tc mongo.SessionContext) (interface{}, error) {
+			if write != nil {
+				if err := write(tc); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(docs) == 0 {
+				return nil, nil
+			}
+
+			return s.coll.InsertMany(tc, docs)
+		})
+		return err
+	})
+}
+
+// Pending returns at most limit messages that have not been published, oldest first.
+func (s *MongoStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	filter := bson.D{{Key: "PK", Value: PK}, {Key: "Status", Value: StatusPending}}
+	opts := options.Find().SetSort(bson.D{{Key: "SK", Value: 1}}).SetLimit(int64(limit))
+
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var msgs []Message
+	for cur.Next(ctx) {
+		var doc mongoMessage
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		m, err := UnmarshalMessage([]byte(doc.Payload))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, cur.Err()
+}
+
+// MarkSent marks the message as published. Sent messages are removed by the TTL index on ExpireAt
+// after SentRetention.
+func (s *MongoStore) MarkSent(ctx context.Context, m Message) error {
+	now := time.Now().UTC()
+	m.Status = StatusSent
+	m.SentAt = &now
+
+	payload, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "Status", Value: StatusSent},
+		{Key: "Payload", Value: string(payload)},
+		{Key: "ExpireAt", Value: now.Add(SentRetention)},
+	}}}
+
+	_, err = s.coll.UpdateOne(ctx, bson.D{{Key: "_id", Value: m.ID}}, update)
+	return err
+}
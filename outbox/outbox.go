@@ -0,0 +1,167 @@
+// Package outbox implements the transactional outbox pattern for the ACME Serverless Fitness Shop.
+// Events are stored in the same table or collection, and in the same transaction, as the business
+// write they belong to. A Relay publishes the stored events to Amazon SQS or Amazon EventBridge and
+// marks them sent, so an event is never lost when a service crashes after writing to the datastore.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// PK is the partition key, or the value of the PK field in MongoDB, of all outbox messages.
+	PK = "OUTBOX"
+
+	// StatusPending means the message has not been published yet.
+	StatusPending = "PENDING"
+
+	// StatusSent means the message has been published.
+	StatusSent = "SENT"
+)
+
+// Message is a single event in the outbox.
+type Message struct {
+	// ID uniquely identifies the message.
+	ID string `json:"id"`
+
+	// Name is the name of the event, like acmeserverless.ShipmentRequestedEventName.
+	Name string `json:"name"`
+
+	// Metadata is the metadata of the event.
+	Metadata acmeserverless.Metadata `json:"metadata"`
+
+	// Payload is the JSON encoded event.
+	Payload json.RawMessage `json:"payload"`
+
+	// Status is either StatusPending or StatusSent.
+	Status string `json:"status"`
+
+	// CreatedAt is when the message was written to the outbox.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// SentAt is when the message was published.
+	SentAt *time.Time `json:"sentAt,omitempty"`
+}
+
+// Marshal returns the JSON encoding of Message.
+func (m *Message) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// UnmarshalMessage parses the JSON-encoded data and stores the result in a Message.
+func UnmarshalMessage(data []byte) (Message, error) {
+	var r Message
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// SortKey returns the sort key of the message. Messages are sorted by the time they were created.
+func (m *Message) SortKey() string {
+	return fmt.Sprintf("%s#%s", m.CreatedAt.UTC().Format(time.RFC3339Nano), m.ID)
+}
+
+// NewMessage creates a pending message for an event. The event must be one of the event structs
+// of the acmeserverless package, like ShipmentRequested.
+func NewMessage(name string, metadata acmeserverless.Metadata, event interface{}) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("error marshalling event: %s", err.Error())
+	}
+
+	return Message{
+		ID:        uuid.Must(uuid.NewV4()).String(),
+		Name:      name,
+		Metadata:  metadata,
+		Payload:   payload,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Store gives the Relay access to the messages in the outbox.
+type Store interface {
+	// Pending returns at most limit messages that have not been published, oldest first.
+	Pending(ctx context.Context, limit int) ([]Message, error)
+
+	// MarkSent marks the message as published.
+	MarkSent(ctx context.Context, m Message) error
+}
+
+// Publisher sends a message to a queue or event bus.
+type Publisher interface {
+	// Publish sends the message and returns the ID assigned to it by the transport.
+	Publish(ctx context.Context, m Message) (string, error)
+}
+
+// Relay publishes the pending messages in an outbox.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	batchSize int
+}
+
+// NewRelay creates a Relay that publishes at most batchSize messages per run. When batchSize is
+// not larger than 0 a batch size of 25 is used.
+func NewRelay(store Store, publisher Publisher, batchSize int) *Relay {
+	if batchSize <= 0 {
+		batchSize = 25
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+}
+
+// Run publishes one batch of pending messages, in the order they were created, and returns the
+// number of messages that were published. It stops at the first message that can't be published so
+// the order of messages is kept. A message is published again when the Relay stops after publishing
+// but before marking it sent, so consumers must be idempotent.
+func (r *Relay) Run(ctx context.Context) (int, error) {
+	msgs, err := r.store.Pending(ctx, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error reading outbox: %s", err.Error())
+	}
+
+	for i, m := range msgs {
+		if _, err := r.publisher.Publish(ctx, m); err != nil {
+			return i, fmt.Errorf("error publishing message %s: %s", m.ID, err.Error())
+		}
+
+		if err := r.store.MarkSent(ctx, m); err != nil {
+			return i, fmt.Errorf("error marking message %s as sent: %s", m.ID, err.Error())
+		}
+	}
+
+	return len(msgs), nil
+}
+
+// Poll calls Run every interval until the context is cancelled, or Run returns an error.
+func (r *Relay) Poll(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going without waiting as long as there are full batches
+		n, err := r.Run(ctx)
+		if err != nil {
+			return err
+		}
+		if n == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recorder is a Publisher that records the IDs of the messages it publishes, and fails to publish
+// the message with ID fail.
+type recorder struct {
+	fail      string
+	published []string
+	// after is called after each message is published
+	after func()
+}
+
+func (r *recorder) Publish(ctx context.Context, m Message) (string, error) {
+	if m.ID == r.fail {
+		return "", errors.New("queue unavailable")
+	}
+
+	r.published = append(r.published, m.ID)
+	if r.after != nil {
+		r.after()
+	}
+	return "sqs-" + m.ID, nil
+}
+
+// newTestStore creates a MemoryStore with a pending message for every ID, created a second apart in
+// the order of the IDs, but added in reverse.
+func newTestStore(ids ...string) *MemoryStore {
+	s := NewMemoryStore()
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := len(ids) - 1; i >= 0; i-- {
+		s.Add(Message{
+			ID:        ids[i],
+			Name:      "ShipmentRequestedEvent",
+			Status:    StatusPending,
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return s
+}
+
+// statuses returns the IDs of the messages with the status.
+func statuses(s *MemoryStore, status string) []string {
+	ids := []string{}
+	for _, m := range s.Messages() {
+		if m.Status == status {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+func TestRelayRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		fail      string
+		n         int
+		err       string
+		published []string
+		pending   []string
+	}{
+		{"all messages", 10, "", 3, "", []string{"1", "2", "3"}, []string{}},
+		{"one batch", 2, "", 2, "", []string{"1", "2"}, []string{"3"}},
+		{"stops at the first error", 10, "2", 1, "error publishing message 2: queue unavailable", []string{"1"}, []string{"2", "3"}},
+	}
+
+	for _, tt := range tests {
+		s := newTestStore("1", "2", "3")
+		p := &recorder{fail: tt.fail}
+
+		n, err := NewRelay(s, p, tt.batchSize).Run(context.Background())
+		if (err == nil && len(tt.err) > 0) || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: error is %v, want %q", tt.name, err, tt.err)
+		}
+		if n != tt.n {
+			t.Errorf("%s: published %d messages, want %d", tt.name, n, tt.n)
+		}
+		if !reflect.DeepEqual(p.published, tt.published) {
+			t.Errorf("%s: published %v, want %v", tt.name, p.published, tt.published)
+		}
+		if got := statuses(s, StatusSent); !reflect.DeepEqual(got, tt.published) {
+			t.Errorf("%s: marked %v as sent, want %v", tt.name, got, tt.published)
+		}
+		if got := statuses(s, StatusPending); !reflect.DeepEqual(got, tt.pending) {
+			t.Errorf("%s: %v are pending, want %v", tt.name, got, tt.pending)
+		}
+	}
+
+	s := newTestStore("1")
+	if _, err := NewRelay(s, &recorder{}, 10).Run(context.Background()); err != nil {
+		t.Fatalf("error running relay: %s", err.Error())
+	}
+	if m := s.Messages()[0]; m.SentAt == nil {
+		t.Fatalf("message %s has no SentAt", m.ID)
+	}
+}
+
+func TestRelayPoll(t *testing.T) {
+	// Full batches are published without waiting for the interval
+	s := newTestStore("1", "2", "3", "4", "5")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := &recorder{}
+	p.after = func() {
+		if len(p.published) == 5 {
+			cancel()
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- NewRelay(s, p, 2).Poll(ctx, time.Hour)
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("error is %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll waited for the interval between full batches")
+	}
+
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(p.published, want) {
+		t.Fatalf("published %v, want %v", p.published, want)
+	}
+
+	// Poll stops at the first error
+	s = newTestStore("1", "2", "3")
+	p = &recorder{fail: "2"}
+	err := NewRelay(s, p, 10).Poll(context.Background(), time.Millisecond)
+	if err == nil || err.Error() != "error publishing message 2: queue unavailable" {
+		t.Fatalf("error is %v, want the error publishing message 2", err)
+	}
+	if got := statuses(s, StatusPending); !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Fatalf("%v are pending, want 2 and 3", got)
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/retgits/acme-serverless/messaging"
+)
+
+// MessagingPublisher publishes the messages in an outbox with a messaging.Publisher, so the Relay
+// can use every transport of the messaging package. The ID of an outbox message is sent with it, so
+// consumers can recognize a message that is published again.
+type MessagingPublisher struct {
+	publisher messaging.Publisher
+}
+
+// NewMessagingPublisher creates a MessagingPublisher that sends the messages with p.
+func NewMessagingPublisher(p messaging.Publisher) *MessagingPublisher {
+	return &MessagingPublisher{
+		publisher: p,
+	}
+}
+
+// Publish sends the message with the messaging.Publisher.
+func (p *MessagingPublisher) Publish(ctx context.Context, m Message) (string, error) {
+	return p.publisher.Publish(ctx, messaging.Message{
+		ID:       m.ID,
+		Name:     m.Name,
+		Metadata: m.Metadata,
+		Payload:  m.Payload,
+	})
+}
+
+// NewSQSPublisher creates a Publisher that sends messages to Amazon SQS queues. The queues map the
+// name of an event, like acmeserverless.PaymentRequestedEventName, to the URL of the queue it must
+// be sent to.
+func NewSQSPublisher(svc *sqs.SQS, queues map[string]string) *MessagingPublisher {
+	return NewMessagingPublisher(messaging.NewSQSPublisher(svc, queues))
+}
+
+// NewEventBridgePublisher creates a Publisher that sends messages to the given Amazon EventBridge
+// event bus.
+func NewEventBridgePublisher(svc *eventbridge.EventBridge, bus string) *MessagingPublisher {
+	return NewMessagingPublisher(messaging.NewEventBridgePublisher(svc, bus))
+}
@@ -153,3 +153,41 @@ func (s *ShopCard) ToCreditCard() creditcard.Card {
 		ExpiryYear:  ey,
 	}
 }
+
+// PaymentRefundRequestedEvent is sent by the Order service when a payment that was already validated
+// must be refunded, for example because the order could not be shipped.
+type PaymentRefundRequestedEvent struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data PaymentRefundDetails `json:"data"`
+}
+
+// UnmarshalPaymentRefundRequestedEvent parses the JSON-encoded data and stores the result in a
+// PaymentRefundRequestedEvent.
+func UnmarshalPaymentRefundRequestedEvent(data []byte) (PaymentRefundRequestedEvent, error) {
+	var r PaymentRefundRequestedEvent
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of PaymentRefundRequestedEvent.
+func (e *PaymentRefundRequestedEvent) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// PaymentRefundDetails contains the data that is needed to refund a payment.
+type PaymentRefundDetails struct {
+	// The unique identifier of the order.
+	OrderID string `json:"orderID"`
+
+	// The unique identifier of the transaction that must be refunded.
+	TransactionID string `json:"transactionID"`
+
+	// The monetary amount to refund.
+	Amount string `json:"amount,omitempty"`
+
+	// Reason explains why the payment is refunded.
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,87 @@
+package saga
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is a manually controlled clock, so timeouts can be tested without waiting.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a Clock that starts at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{
+		now: start,
+	}
+}
+
+// Now returns the current time of the Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the Clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// InMemory is a deterministic Runner that keeps its state in memory, uses a manual Clock, and
+// records the commands it sends instead of sending them to a queue. It is meant to test the
+// complete flow of a saga, including compensations and timeouts.
+type InMemory struct {
+	*Runner
+
+	// Store contains the state of all sagas.
+	Store *MemoryStore
+
+	// Clock is used to calculate and check timeouts.
+	Clock *Clock
+
+	mu   sync.Mutex
+	sent []Command
+}
+
+// NewInMemory creates an InMemory runner for the Definition. The clock starts at the given time.
+func NewInMemory(def Definition, start time.Time) *InMemory {
+	m := &InMemory{
+		Store: NewMemoryStore(),
+		Clock: NewClock(start),
+	}
+	m.Runner = NewRunner(def, m.Store, m.record, m.Clock.Now)
+	return m
+}
+
+// Sent returns the commands sent so far, in the order they were sent.
+func (m *InMemory) Sent() []Command {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := make([]Command, len(m.sent))
+	copy(sent, m.sent)
+	return sent
+}
+
+// Drain returns the commands sent so far and forgets them.
+func (m *InMemory) Drain() []Command {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := m.sent
+	m.sent = nil
+	return sent
+}
+
+func (m *InMemory) record(ctx context.Context, cmd Command) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, cmd)
+	return nil
+}
@@ -0,0 +1,173 @@
+package saga
+
+import (
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+const (
+	// OrderSagaName is the name of the saga that handles the order lifecycle.
+	OrderSagaName = "OrderLifecycle"
+
+	// StepOrder is the step that reserves the order. It is compensated by cancelling the order.
+	StepOrder = "order"
+
+	// StepPayment is the step that validates and charges the creditcard. It is compensated by
+	// refunding the payment.
+	StepPayment = "payment"
+
+	// StepShipment is the step that ships the order to the customer.
+	StepShipment = "shipment"
+
+	// DataTransactionID is the key in State.Data of the transaction ID of the payment.
+	DataTransactionID = "transactionID"
+
+	// DataAmount is the key in State.Data of the amount that was charged.
+	DataAmount = "amount"
+
+	// DataTrackingNumber is the key in State.Data of the tracking number of the shipment.
+	DataTrackingNumber = "trackingNumber"
+
+	// source is the source set in the metadata of all commands sent by the saga.
+	source = "OrderSaga"
+)
+
+// Timeouts contains how long the order saga waits for each of the services.
+type Timeouts struct {
+	// Payment is how long to wait for the CreditCardValidatedEvent.
+	Payment time.Duration
+
+	// Shipment is how long to wait for the ShipmentSent event.
+	Shipment time.Duration
+}
+
+// OrderLifecycle returns the saga definition for the order lifecycle of the ACME Serverless
+// Fitness Shop: payment followed by shipment. When the payment fails the order is cancelled,
+// when the shipment fails the payment is refunded and the order is cancelled.
+func OrderLifecycle(t Timeouts) Definition {
+	return Definition{
+		Name: OrderSagaName,
+		Steps: []Step{
+			{
+				Name:       StepOrder,
+				Compensate: cancelOrder,
+			},
+			{
+				Name:       StepPayment,
+				Invoke:     requestPayment,
+				Compensate: refundPayment,
+				Timeout:    t.Payment,
+			},
+			{
+				Name:    StepShipment,
+				Invoke:  requestShipment,
+				Timeout: t.Shipment,
+			},
+		},
+	}
+}
+
+func requestPayment(s *State) (*Command, error) {
+	return &Command{
+		Name: acmeserverless.PaymentRequestedEventName,
+		Event: &acmeserverless.PaymentRequestedEvent{
+			Metadata: metadata(acmeserverless.PaymentRequestedEventName),
+			Data: acmeserverless.PaymentRequestDetails{
+				OrderID: s.OrderID,
+				Card:    s.Order.Card,
+				Total:   s.Order.Total,
+			},
+		},
+	}, nil
+}
+
+func refundPayment(s *State) (*Command, error) {
+	return &Command{
+		Name: acmeserverless.PaymentRefundRequestedEventName,
+		Event: &acmeserverless.PaymentRefundRequestedEvent{
+			Metadata: metadata(acmeserverless.PaymentRefundRequestedEventName),
+			Data: acmeserverless.PaymentRefundDetails{
+				OrderID:       s.OrderID,
+				TransactionID: s.Data[DataTransactionID],
+				Amount:        s.Data[DataAmount],
+				Reason:        s.Reason,
+			},
+		},
+	}, nil
+}
+
+func requestShipment(s *State) (*Command, error) {
+	return &Command{
+		Name: acmeserverless.ShipmentRequestedEventName,
+		Event: &acmeserverless.ShipmentRequested{
+			Metadata: metadata(acmeserverless.ShipmentRequestedEventName),
+			Data: acmeserverless.ShipmentRequest{
+				OrderID:  s.OrderID,
+				Delivery: s.Order.Delivery,
+			},
+		},
+	}, nil
+}
+
+func cancelOrder(s *State) (*Command, error) {
+	return &Command{
+		Name: acmeserverless.OrderCancelledEventName,
+		Event: &acmeserverless.OrderCancelled{
+			Metadata: metadata(acmeserverless.OrderCancelledEventName),
+			Data: acmeserverless.OrderCancellation{
+				OrderID: s.OrderID,
+				UserID:  s.Order.UserID,
+				Reason:  s.Reason,
+			},
+		},
+	}, nil
+}
+
+func metadata(eventType string) acmeserverless.Metadata {
+	return acmeserverless.Metadata{
+		Domain: acmeserverless.OrderDomain,
+		Source: source,
+		Type:   eventType,
+		Status: acmeserverless.DefaultSuccessStatus,
+	}
+}
+
+// ReplyFromCreditCardValidated converts the event sent by the payment service into a Reply for
+// the payment step.
+func ReplyFromCreditCardValidated(e acmeserverless.CreditCardValidatedEvent) Reply {
+	r := Reply{
+		OrderID: e.Data.OrderID,
+		Step:    StepPayment,
+		Success: e.Data.Success,
+		Data: map[string]string{
+			DataTransactionID: e.Data.TransactionID,
+			DataAmount:        e.Data.Amount,
+		},
+	}
+
+	if !r.Success {
+		r.Reason = e.Data.Message
+	}
+
+	return r
+}
+
+// ReplyFromShipmentSent converts the event sent by the shipment service into a Reply for the
+// shipment step. The shipment failed when the status in the metadata is DefaultErrorStatus.
+func ReplyFromShipmentSent(e acmeserverless.ShipmentSent) Reply {
+	r := Reply{
+		OrderID: e.Data.OrderNumber,
+		Step:    StepShipment,
+		Success: e.Metadata.Status != acmeserverless.DefaultErrorStatus,
+		Data: map[string]string{
+			DataTrackingNumber: e.Data.TrackingNumber,
+		},
+	}
+
+	if !r.Success {
+		r.Reason = e.Data.Status
+	}
+
+	return r
+}
@@ -0,0 +1,261 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// Runner executes sagas of a single Definition. The state of every saga is saved to the Store after
+// each transition, so a Runner can be recreated at any time, for example in a new Lambda invocation.
+type Runner struct {
+	def   Definition
+	store Store
+	emit  Emitter
+	now   func() time.Time
+}
+
+// NewRunner creates a Runner for the Definition that persists state in store and sends commands
+// using emit. The clock is used to calculate and check timeouts; when it is nil time.Now is used.
+func NewRunner(def Definition, store Store, emit Emitter, clock func() time.Time) *Runner {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &Runner{
+		def:   def,
+		store: store,
+		emit:  emit,
+		now:   clock,
+	}
+}
+
+// Start creates a new saga for the order and executes the first steps.
+func (r *Runner) Start(ctx context.Context, order acmeserverless.Order) (State, error) {
+	if _, err := r.store.Load(ctx, order.OrderID); err == nil {
+		return State{}, fmt.Errorf("saga for order %s already exists", order.OrderID)
+	} else if err != ErrNotFound {
+		return State{}, err
+	}
+
+	s := State{
+		OrderID: order.OrderID,
+		Saga:    r.def.Name,
+		Status:  StatusRunning,
+		Order:   order,
+		Data:    make(map[string]string),
+	}
+
+	err := r.advance(ctx, &s)
+	return s, err
+}
+
+// Handle processes the reply of a step. Replies for a step that is not currently executing, like
+// duplicate deliveries, are ignored. A successful reply that arrives after the saga stopped waiting
+// for it, like a payment that completed after its timeout, is undone with the Compensate of its
+// step.
+func (r *Runner) Handle(ctx context.Context, reply Reply) (State, error) {
+	s, err := r.store.Load(ctx, reply.OrderID)
+	if err != nil {
+		return s, err
+	}
+
+	if reply.Success && (s.Status == StatusCompensating || s.Status == StatusCompensated) {
+		return s, r.compensateLate(ctx, &s, reply)
+	}
+
+	if s.Status != StatusRunning || s.Step >= len(r.def.Steps) || r.def.Steps[s.Step].Name != reply.Step {
+		return s, nil
+	}
+
+	if !reply.Success {
+		r.record(&s, reply.Step, "failed", reply.Reason)
+		return s, r.compensate(ctx, &s, reply.Reason)
+	}
+
+	if s.Data == nil {
+		s.Data = make(map[string]string)
+	}
+	for k, v := range reply.Data {
+		s.Data[k] = v
+	}
+
+	r.record(&s, reply.Step, "completed", "")
+	s.Step++
+	s.Deadline = nil
+
+	return s, r.advance(ctx, &s)
+}
+
+// CheckTimeouts compensates all sagas of which the current step did not reply before its deadline,
+// and resumes the compensation of sagas that could not send all compensating commands before. Only
+// the sagas that are running or compensating are read.
+func (r *Runner) CheckTimeouts(ctx context.Context) error {
+	states, err := r.store.Active(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range states {
+		s := states[i]
+		if s.Saga != r.def.Name {
+			continue
+		}
+
+		switch {
+		case s.Status == StatusCompensating:
+			err = r.compensate(ctx, &s, s.Reason)
+		case s.Status == StatusRunning && s.Deadline != nil && !r.now().Before(*s.Deadline):
+			reason := fmt.Sprintf("step %s timed out", r.def.Steps[s.Step].Name)
+			r.record(&s, r.def.Steps[s.Step].Name, "timedout", reason)
+			err = r.compensate(ctx, &s, reason)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// advance invokes steps, starting at the current one, until a step is waiting for a reply or all
+// steps have completed.
+func (r *Runner) advance(ctx context.Context, s *State) error {
+	for s.Step < len(r.def.Steps) {
+		step := r.def.Steps[s.Step]
+		if step.Invoke == nil {
+			r.record(s, step.Name, "completed", "")
+			s.Step++
+			continue
+		}
+
+		cmd, err := step.Invoke(s)
+		if err != nil {
+			r.record(s, step.Name, "failed", err.Error())
+			return r.compensate(ctx, s, err.Error())
+		}
+
+		if step.Timeout > 0 {
+			deadline := r.now().Add(step.Timeout)
+			s.Deadline = &deadline
+		}
+
+		r.record(s, step.Name, "invoked", "")
+		if err := r.store.Save(ctx, *s); err != nil {
+			return err
+		}
+
+		if cmd != nil {
+			if err := r.emit(ctx, *cmd); err != nil {
+				return fmt.Errorf("error sending %s: %s", cmd.Name, err.Error())
+			}
+		}
+		return nil
+	}
+
+	s.Status = StatusCompleted
+	s.Deadline = nil
+	return r.store.Save(ctx, *s)
+}
+
+// compensate undoes the completed steps in reverse order. The state is saved after every
+// compensating command, so a compensation that is resumed, after sending a command failed or the
+// Runner stopped, doesn't send the commands that were already sent again.
+func (r *Runner) compensate(ctx context.Context, s *State, reason string) error {
+	if s.Status == StatusRunning {
+		s.Status = StatusCompensating
+		s.Reason = reason
+		s.Deadline = nil
+		s.Step--
+	}
+
+	for s.Step >= 0 {
+		step := r.def.Steps[s.Step]
+		if step.Compensate == nil {
+			s.Step--
+			continue
+		}
+
+		cmd, err := step.Compensate(s)
+		if err != nil {
+			return r.saveAfter(ctx, s, fmt.Errorf("error compensating %s: %s", step.Name, err.Error()))
+		}
+		if cmd != nil {
+			if err := r.emit(ctx, *cmd); err != nil {
+				return r.saveAfter(ctx, s, fmt.Errorf("error sending %s: %s", cmd.Name, err.Error()))
+			}
+		}
+
+		r.record(s, step.Name, "compensated", "")
+		s.Step--
+		if err := r.store.Save(ctx, *s); err != nil {
+			return fmt.Errorf("error saving saga %s: %s", s.OrderID, err.Error())
+		}
+	}
+
+	s.Step = 0
+	s.Status = StatusCompensated
+	return r.store.Save(ctx, *s)
+}
+
+// compensateLate undoes a step that completed after the saga started compensating. A step that
+// completed before, or that was undone already, is skipped, so a duplicate reply doesn't send the
+// compensating command again.
+func (r *Runner) compensateLate(ctx context.Context, s *State, reply Reply) error {
+	var step *Step
+	for i := range r.def.Steps {
+		if r.def.Steps[i].Name == reply.Step {
+			step = &r.def.Steps[i]
+		}
+	}
+	if step == nil || step.Compensate == nil {
+		return nil
+	}
+
+	for _, e := range s.History {
+		if e.Step == step.Name && (e.Event == "completed" || e.Event == "compensated") {
+			return nil
+		}
+	}
+
+	if s.Data == nil {
+		s.Data = make(map[string]string)
+	}
+	for k, v := range reply.Data {
+		s.Data[k] = v
+	}
+
+	cmd, err := step.Compensate(s)
+	if err != nil {
+		return fmt.Errorf("error compensating %s: %s", step.Name, err.Error())
+	}
+	if cmd != nil {
+		if err := r.emit(ctx, *cmd); err != nil {
+			return fmt.Errorf("error sending %s: %s", cmd.Name, err.Error())
+		}
+	}
+
+	r.record(s, step.Name, "compensated", "")
+	return r.store.Save(ctx, *s)
+}
+
+// saveAfter saves the state of a saga that stopped because of err, so it can be resumed, and
+// returns err. When saving fails as well, both errors are returned.
+func (r *Runner) saveAfter(ctx context.Context, s *State, err error) error {
+	if serr := r.store.Save(ctx, *s); serr != nil {
+		return fmt.Errorf("%s (error saving saga %s: %s)", err.Error(), s.OrderID, serr.Error())
+	}
+	return err
+}
+
+// record adds a transition to the history of the saga.
+func (r *Runner) record(s *State, step string, event string, reason string) {
+	s.History = append(s.History, Entry{
+		Step:   step,
+		Event:  event,
+		Reason: reason,
+		Time:   r.now(),
+	})
+}
@@ -0,0 +1,159 @@
+// Package saga coordinates the order lifecycle of the ACME Serverless Fitness Shop across the
+// payment and shipment services. A saga is a sequence of steps, each of which can be undone by a
+// compensating step when a later step fails or times out.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// ErrNotFound is returned by a Store when no saga state exists for the requested order.
+var ErrNotFound = errors.New("saga: state not found")
+
+// Status represents where a saga is in its lifecycle.
+type Status string
+
+const (
+	// StatusRunning means the saga is executing its steps.
+	StatusRunning Status = "running"
+
+	// StatusCompensating means a step failed and the completed steps are being undone.
+	StatusCompensating Status = "compensating"
+
+	// StatusCompleted means all steps finished successfully.
+	StatusCompleted Status = "completed"
+
+	// StatusCompensated means a step failed and all completed steps have been undone.
+	StatusCompensated Status = "compensated"
+)
+
+// Command is an event the saga sends to another service, like a PaymentRequestedEvent.
+type Command struct {
+	// Name is the name of the event, like acmeserverless.PaymentRequestedEventName.
+	Name string
+
+	// Event is one of the event structs of the acmeserverless package.
+	Event interface{}
+}
+
+// Marshal returns the JSON encoding of the event in the Command.
+func (c Command) Marshal() ([]byte, error) {
+	return json.Marshal(c.Event)
+}
+
+// Emitter sends the commands of a saga to the services that have to act on them.
+type Emitter func(ctx context.Context, cmd Command) error
+
+// Step is a single step in a saga.
+type Step struct {
+	// Name uniquely identifies the step within the saga.
+	Name string
+
+	// Invoke creates the command that starts the step. A step without Invoke completes as soon
+	// as it is reached.
+	Invoke func(s *State) (*Command, error)
+
+	// Compensate creates the command that undoes the step after it has completed. A step
+	// without Compensate has nothing to undo.
+	Compensate func(s *State) (*Command, error)
+
+	// Timeout is how long the saga waits for a reply to the command of the step. A zero value
+	// means the saga waits forever.
+	Timeout time.Duration
+}
+
+// Definition describes the steps of a saga in the order they must be executed.
+type Definition struct {
+	// Name is the name of the saga.
+	Name string
+
+	// Steps are executed in order and compensated in reverse order.
+	Steps []Step
+}
+
+// Reply is the outcome of a step, as reported by the service that executed it.
+type Reply struct {
+	// OrderID identifies the saga the reply belongs to.
+	OrderID string
+
+	// Step is the name of the step the reply belongs to.
+	Step string
+
+	// Success indicates whether the step completed successfully.
+	Success bool
+
+	// Reason explains why the step failed.
+	Reason string
+
+	// Data contains values that later steps or compensations need, like the transaction ID of
+	// a payment.
+	Data map[string]string
+}
+
+// Entry is a single transition in the history of a saga.
+type Entry struct {
+	// Step is the name of the step the transition belongs to.
+	Step string `json:"step"`
+
+	// Event describes what happened, like invoked, completed, failed, or compensated.
+	Event string `json:"event"`
+
+	// Reason explains why a step failed.
+	Reason string `json:"reason,omitempty"`
+
+	// Time is when the transition happened.
+	Time time.Time `json:"time"`
+}
+
+// State is the persisted state of a single saga. There is one saga per order.
+type State struct {
+	// OrderID uniquely identifies the saga.
+	OrderID string `json:"orderID"`
+
+	// Saga is the name of the Definition the saga executes.
+	Saga string `json:"saga"`
+
+	// Status is the current status of the saga.
+	Status Status `json:"status"`
+
+	// Step is the index of the step that is executing or, while compensating, the index of the
+	// next step to compensate.
+	Step int `json:"step"`
+
+	// Deadline is the time by which the current step must have replied.
+	Deadline *time.Time `json:"deadline,omitempty"`
+
+	// Order is the order the saga was started for.
+	Order acmeserverless.Order `json:"order"`
+
+	// Data contains the values collected from the replies of the steps.
+	Data map[string]string `json:"data,omitempty"`
+
+	// Reason explains why the saga was compensated.
+	Reason string `json:"reason,omitempty"`
+
+	// History contains all transitions of the saga.
+	History []Entry `json:"history"`
+}
+
+// Marshal returns the JSON encoding of State.
+func (s *State) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// UnmarshalState parses the JSON-encoded data and stores the result in a State.
+func UnmarshalState(data []byte) (State, error) {
+	var r State
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Done reports whether the saga has reached a final status.
+func (s *State) Done() bool {
+	return s.Status == StatusCompleted || s.Status == StatusCompensated
+}
@@ -0,0 +1,319 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+var start = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+var timeouts = Timeouts{
+	Payment:  time.Minute,
+	Shipment: time.Hour,
+}
+
+func testOrder() acmeserverless.Order {
+	return acmeserverless.Order{
+		OrderID:  "order-1",
+		UserID:   "user-1",
+		Total:    "123",
+		Delivery: "UPS",
+	}
+}
+
+func names(cmds []Command) []string {
+	n := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		n = append(n, c.Name)
+	}
+	return n
+}
+
+func assertSent(t *testing.T, got []Command, want ...string) {
+	t.Helper()
+	if len(want) == 0 {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(names(got), want) {
+		t.Fatalf("sent %v, want %v", names(got), want)
+	}
+}
+
+func assertStatus(t *testing.T, m *InMemory, want Status) State {
+	t.Helper()
+	s, err := m.Store.Load(context.Background(), "order-1")
+	if err != nil {
+		t.Fatalf("error loading saga: %s", err.Error())
+	}
+	if s.Status != want {
+		t.Fatalf("status is %s, want %s", s.Status, want)
+	}
+	return s
+}
+
+func paymentReply(success bool) Reply {
+	return ReplyFromCreditCardValidated(acmeserverless.CreditCardValidatedEvent{
+		Data: acmeserverless.CreditCardValidationDetails{
+			OrderID:       "order-1",
+			Success:       success,
+			Message:       "card declined",
+			Amount:        "123",
+			TransactionID: "tx-1",
+		},
+	})
+}
+
+func shipmentReply(status string) Reply {
+	return ReplyFromShipmentSent(acmeserverless.ShipmentSent{
+		Metadata: acmeserverless.Metadata{Status: status},
+		Data: acmeserverless.ShipmentData{
+			OrderNumber:    "order-1",
+			TrackingNumber: "track-1",
+			Status:         "lost",
+		},
+	})
+}
+
+func TestOrderLifecycleCompleted(t *testing.T) {
+	ctx := context.Background()
+	m := NewInMemory(OrderLifecycle(timeouts), start)
+
+	if _, err := m.Start(ctx, testOrder()); err != nil {
+		t.Fatalf("error starting saga: %s", err.Error())
+	}
+	assertSent(t, m.Drain(), acmeserverless.PaymentRequestedEventName)
+
+	if _, err := m.Handle(ctx, paymentReply(true)); err != nil {
+		t.Fatalf("error handling payment: %s", err.Error())
+	}
+	assertSent(t, m.Drain(), acmeserverless.ShipmentRequestedEventName)
+
+	if _, err := m.Handle(ctx, shipmentReply(acmeserverless.DefaultSuccessStatus)); err != nil {
+		t.Fatalf("error handling shipment: %s", err.Error())
+	}
+	assertSent(t, m.Drain())
+
+	s := assertStatus(t, m, StatusCompleted)
+	if s.Data[DataTransactionID] != "tx-1" || s.Data[DataTrackingNumber] != "track-1" {
+		t.Fatalf("data is %v, want the transaction ID and tracking number", s.Data)
+	}
+
+	// A duplicate reply doesn't change a completed saga
+	if _, err := m.Handle(ctx, paymentReply(false)); err != nil {
+		t.Fatalf("error handling duplicate: %s", err.Error())
+	}
+	assertSent(t, m.Drain())
+	assertStatus(t, m, StatusCompleted)
+}
+
+func TestOrderLifecyclePaymentFailed(t *testing.T) {
+	ctx := context.Background()
+	m := NewInMemory(OrderLifecycle(timeouts), start)
+
+	m.Start(ctx, testOrder())
+	m.Drain()
+
+	if _, err := m.Handle(ctx, paymentReply(false)); err != nil {
+		t.Fatalf("error handling payment: %s", err.Error())
+	}
+
+	// Only the order is cancelled, as the payment never completed
+	cmds := m.Drain()
+	assertSent(t, cmds, acmeserverless.OrderCancelledEventName)
+	if e := cmds[0].Event.(*acmeserverless.OrderCancelled); e.Data.Reason != "card declined" {
+		t.Fatalf("reason is %q, want the reason of the payment", e.Data.Reason)
+	}
+	assertStatus(t, m, StatusCompensated)
+}
+
+func TestOrderLifecycleShipmentFailed(t *testing.T) {
+	ctx := context.Background()
+	m := NewInMemory(OrderLifecycle(timeouts), start)
+
+	m.Start(ctx, testOrder())
+	m.Handle(ctx, paymentReply(true))
+	m.Drain()
+
+	if _, err := m.Handle(ctx, shipmentReply(acmeserverless.DefaultErrorStatus)); err != nil {
+		t.Fatalf("error handling shipment: %s", err.Error())
+	}
+
+	// The completed steps are compensated in reverse order
+	cmds := m.Drain()
+	assertSent(t, cmds, acmeserverless.PaymentRefundRequestedEventName, acmeserverless.OrderCancelledEventName)
+	if e := cmds[0].Event.(*acmeserverless.PaymentRefundRequestedEvent); e.Data.TransactionID != "tx-1" {
+		t.Fatalf("refunded transaction %q, want tx-1", e.Data.TransactionID)
+	}
+	assertStatus(t, m, StatusCompensated)
+
+	// A duplicate of the payment, which was refunded with the compensation, isn't refunded again
+	if _, err := m.Handle(ctx, paymentReply(true)); err != nil {
+		t.Fatalf("error handling duplicate: %s", err.Error())
+	}
+	assertSent(t, m.Drain())
+}
+
+func TestOrderLifecycleTimeout(t *testing.T) {
+	ctx := context.Background()
+	m := NewInMemory(OrderLifecycle(timeouts), start)
+
+	m.Start(ctx, testOrder())
+	m.Drain()
+
+	m.Clock.Advance(timeouts.Payment - time.Second)
+	if err := m.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("error checking timeouts: %s", err.Error())
+	}
+	assertSent(t, m.Drain())
+	assertStatus(t, m, StatusRunning)
+
+	m.Clock.Advance(time.Second)
+	if err := m.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("error checking timeouts: %s", err.Error())
+	}
+	assertSent(t, m.Drain(), acmeserverless.OrderCancelledEventName)
+	s := assertStatus(t, m, StatusCompensated)
+	if s.Reason != "step payment timed out" {
+		t.Fatalf("reason is %q, want the timeout", s.Reason)
+	}
+
+	// A payment that completes after the timeout is refunded, once
+	if _, err := m.Handle(ctx, paymentReply(true)); err != nil {
+		t.Fatalf("error handling late reply: %s", err.Error())
+	}
+	cmds := m.Drain()
+	assertSent(t, cmds, acmeserverless.PaymentRefundRequestedEventName)
+	if e := cmds[0].Event.(*acmeserverless.PaymentRefundRequestedEvent); e.Data.TransactionID != "tx-1" || e.Data.Amount != "123" {
+		t.Fatalf("refunded transaction %q of %q, want tx-1 of 123", e.Data.TransactionID, e.Data.Amount)
+	}
+
+	if _, err := m.Handle(ctx, paymentReply(true)); err != nil {
+		t.Fatalf("error handling duplicate late reply: %s", err.Error())
+	}
+	assertSent(t, m.Drain())
+	assertStatus(t, m, StatusCompensated)
+}
+
+func TestCompensationResumes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	var sent []Command
+	failCancel := true
+	emit := func(ctx context.Context, cmd Command) error {
+		if cmd.Name == acmeserverless.OrderCancelledEventName && failCancel {
+			return errors.New("queue unavailable")
+		}
+		sent = append(sent, cmd)
+		return nil
+	}
+
+	r := NewRunner(OrderLifecycle(timeouts), store, emit, NewClock(start).Now)
+	r.Start(ctx, testOrder())
+	r.Handle(ctx, paymentReply(true))
+	sent = nil
+
+	if _, err := r.Handle(ctx, shipmentReply(acmeserverless.DefaultErrorStatus)); err == nil {
+		t.Fatal("expected an error when the cancellation can't be sent")
+	}
+	assertSent(t, sent, acmeserverless.PaymentRefundRequestedEventName)
+
+	s, _ := store.Load(ctx, "order-1")
+	if s.Status != StatusCompensating || s.Step != 0 {
+		t.Fatalf("saved status %s at step %d, want compensating at step 0", s.Status, s.Step)
+	}
+
+	// A new Runner resumes the compensation without refunding the payment again
+	failCancel = false
+	sent = nil
+	r = NewRunner(OrderLifecycle(timeouts), store, emit, NewClock(start).Now)
+	if err := r.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("error resuming compensation: %s", err.Error())
+	}
+	assertSent(t, sent, acmeserverless.OrderCancelledEventName)
+
+	s, _ = store.Load(ctx, "order-1")
+	if s.Status != StatusCompensated {
+		t.Fatalf("status is %s, want compensated", s.Status)
+	}
+}
+
+func TestCompensateErrorSavesState(t *testing.T) {
+	ctx := context.Background()
+	def := Definition{
+		Name: "broken",
+		Steps: []Step{
+			{
+				Name: "first",
+				Compensate: func(s *State) (*Command, error) {
+					return nil, errors.New("no compensation")
+				},
+			},
+			{
+				Name: "second",
+				Invoke: func(s *State) (*Command, error) {
+					return nil, errors.New("no invocation")
+				},
+			},
+		},
+	}
+
+	m := NewInMemory(def, start)
+	if _, err := m.Start(ctx, testOrder()); err == nil {
+		t.Fatal("expected the error of the compensation")
+	}
+
+	s := assertStatus(t, m, StatusCompensating)
+	if s.Step != 0 {
+		t.Fatalf("saved step %d, want 0", s.Step)
+	}
+}
+
+// activeOnly is a Store that fails to list all sagas, so only Active can be used.
+type activeOnly struct {
+	*MemoryStore
+}
+
+func (activeOnly) List(ctx context.Context) ([]State, error) {
+	return nil, errors.New("listing all sagas")
+}
+
+func TestCheckTimeoutsReadsActiveSagas(t *testing.T) {
+	ctx := context.Background()
+	store := activeOnly{NewMemoryStore()}
+	clock := NewClock(start)
+	r := NewRunner(OrderLifecycle(timeouts), store, func(ctx context.Context, cmd Command) error { return nil }, clock.Now)
+
+	for _, id := range []string{"order-1", "order-2", "order-3"} {
+		order := testOrder()
+		order.OrderID = id
+		r.Start(ctx, order)
+	}
+	r.Handle(ctx, paymentReply(true))
+	r.Handle(ctx, shipmentReply(acmeserverless.DefaultSuccessStatus))
+
+	active, err := store.Active(ctx)
+	if err != nil {
+		t.Fatalf("error listing active sagas: %s", err.Error())
+	}
+	if len(active) != 2 || active[0].OrderID != "order-2" || active[1].OrderID != "order-3" {
+		t.Fatalf("active sagas are %v, want order-2 and order-3", active)
+	}
+
+	clock.Advance(timeouts.Payment)
+	if err := r.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("error checking timeouts: %s", err.Error())
+	}
+
+	if active, _ := store.Active(ctx); len(active) != 0 {
+		t.Fatalf("%d sagas are still active after they timed out", len(active))
+	}
+	if s, _ := store.Load(ctx, "order-1"); s.Status != StatusCompleted {
+		t.Fatalf("status of order-1 is %s, want completed", s.Status)
+	}
+}
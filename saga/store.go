@@ -0,0 +1,268 @@
+package saga
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Store persists the state of sagas.
+type Store interface {
+	// Load returns the state of the saga for the order, or ErrNotFound.
+	Load(ctx context.Context, orderID string) (State, error)
+
+	// Save creates or replaces the state of a saga.
+	Save(ctx context.Context, s State) error
+
+	// List returns the state of all sagas.
+	List(ctx context.Context) ([]State, error)
+
+	// Active returns the state of the sagas that are running or compensating, which are the only
+	// sagas that can time out or have to resume their compensation.
+	Active(ctx context.Context) ([]State, error)
+}
+
+// PendingIndex is the name of the sparse global secondary index with Pending as hash key and SK as
+// range key. Only sagas that are running or compensating have the Pending attribute.
+const PendingIndex = "Pending-index"
+
+// MemoryStore is a Store that keeps the state of sagas in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string][]byte),
+	}
+}
+
+// Load returns the state of the saga for the order, or ErrNotFound.
+func (m *MemoryStore) Load(ctx context.Context, orderID string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payload, ok := m.states[orderID]
+	if !ok {
+		return State{}, ErrNotFound
+	}
+
+	return UnmarshalState(payload)
+}
+
+// Save creates or replaces the state of a saga. The state is stored as JSON so later changes to
+// the State passed in don't affect the stored state.
+func (m *MemoryStore) Save(ctx context.Context, s State) error {
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[s.OrderID] = payload
+	return nil
+}
+
+// List returns the state of all sagas, ordered by OrderID.
+func (m *MemoryStore) List(ctx context.Context) ([]State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.states))
+	for id := range m.states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	states := make([]State, 0, len(ids))
+	for _, id := range ids {
+		s, err := UnmarshalState(m.states[id])
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+
+	return states, nil
+}
+
+// Active returns the state of the sagas that are running or compensating, ordered by OrderID.
+func (m *MemoryStore) Active(ctx context.Context) ([]State, error) {
+	states, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return active(states), nil
+}
+
+// active returns the states that aren't done.
+func active(states []State) []State {
+	var a []State
+	for _, s := range states {
+		if !s.Done() {
+			a = append(a, s)
+		}
+	}
+	return a
+}
+
+// DynamoDBStore is a Store that keeps the state of sagas in the Amazon DynamoDB table of the shop,
+// using the access pattern PK = SAGA SK = OrderID.
+type DynamoDBStore struct {
+	dbs          *dynamodb.DynamoDB
+	table        string
+	pendingIndex string
+}
+
+// NewDynamoDBStore creates a DynamoDBStore that uses the given table. Active uses the index
+// PendingIndex, which can be changed using WithPendingIndex.
+func NewDynamoDBStore(dbs *dynamodb.DynamoDB, table string) *DynamoDBStore {
+	return &DynamoDBStore{
+		dbs:          dbs,
+		table:        table,
+		pendingIndex: PendingIndex,
+	}
+}
+
+// WithPendingIndex sets the name of the global secondary index Active uses. With an empty name
+// Active reads all sagas and filters them, which works for tables without the index.
+func (d *DynamoDBStore) WithPendingIndex(name string) *DynamoDBStore {
+	d.pendingIndex = name
+	return d
+}
+
+// Load returns the state of the saga for the order, or ErrNotFound.
+func (d *DynamoDBStore) Load(ctx context.Context, orderID string) (State, error) {
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("SAGA"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(orderID),
+	}
+
+	gio, err := d.dbs.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.table),
+		Key:       km,
+	})
+	if err != nil {
+		return State{}, err
+	}
+
+	if gio.Item == nil || gio.Item["Payload"] == nil {
+		return State{}, ErrNotFound
+	}
+
+	return UnmarshalState([]byte(aws.StringValue(gio.Item["Payload"].S)))
+}
+
+// Save creates or replaces the state of a saga.
+func (d *DynamoDBStore) Save(ctx context.Context, s State) error {
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String("SAGA"),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(s.OrderID),
+	}
+
+	// Create a map of DynamoDB Attribute Values containing the table data elements
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+
+	// Only sagas that aren't done are in the PendingIndex
+	expr := "SET Payload = :payload REMOVE Pending"
+	if !s.Done() {
+		em[":pending"] = &dynamodb.AttributeValue{
+			S: aws.String("SAGA"),
+		}
+		expr = "SET Payload = :payload, Pending = :pending"
+	}
+
+	_, err = d.dbs.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       km,
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String(expr),
+	})
+
+	return err
+}
+
+// List returns the state of all sagas.
+func (d *DynamoDBStore) List(ctx context.Context) ([]State, error) {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String("SAGA"),
+	}
+
+	return d.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: em,
+	})
+}
+
+// Active returns the state of the sagas that are running or compensating. The index is eventually
+// consistent, so a saga that just finished can be returned as well, which CheckTimeouts ignores.
+func (d *DynamoDBStore) Active(ctx context.Context) ([]State, error) {
+	if len(d.pendingIndex) == 0 {
+		states, err := d.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return active(states), nil
+	}
+
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":pending"] = &dynamodb.AttributeValue{
+		S: aws.String("SAGA"),
+	}
+
+	return d.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		IndexName:                 aws.String(d.pendingIndex),
+		KeyConditionExpression:    aws.String("Pending = :pending"),
+		ExpressionAttributeValues: em,
+	})
+}
+
+// query returns the state of the sagas the query returns.
+func (d *DynamoDBStore) query(ctx context.Context, qi *dynamodb.QueryInput) ([]State, error) {
+	var states []State
+	var serr error
+
+	err := d.dbs.QueryPagesWithContext(ctx, qi, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			s, err := UnmarshalState([]byte(aws.StringValue(item["Payload"].S)))
+			if err != nil {
+				serr = err
+				return false
+			}
+			states = append(states, s)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return states, serr
+}
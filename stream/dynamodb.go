@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/retgits/acme-serverless/datastore"
+)
+
+// ttlPrincipal is the principal of the changes DynamoDB makes when it removes an expired item.
+const ttlPrincipal = "dynamodb.amazonaws.com"
+
+// ChangeFromDynamoDB converts a DynamoDB Streams record into a Change. The stream must contain the
+// new and old images of the items to create events from the changes.
+func ChangeFromDynamoDB(r *dynamodbstreams.Record) Change {
+	c := Change{
+		ID:        aws.StringValue(r.EventID),
+		Operation: Operation(aws.StringValue(r.EventName)),
+	}
+
+	if r.Dynamodb != nil {
+		if len(r.Dynamodb.OldImage) > 0 {
+			old := datastore.RecordFromItem(r.Dynamodb.OldImage)
+			c.Old = &old
+		}
+		if len(r.Dynamodb.NewImage) > 0 {
+			rec := datastore.RecordFromItem(r.Dynamodb.NewImage)
+			c.New = &rec
+		}
+	}
+
+	if r.UserIdentity != nil && aws.StringValue(r.UserIdentity.Type) == "Service" && aws.StringValue(r.UserIdentity.PrincipalId) == ttlPrincipal {
+		c.Expired = true
+	}
+
+	return c
+}
+
+// shard is the position in a single shard of the stream.
+type shard struct {
+	parent   string
+	iterator string
+	next     string
+	done     bool
+}
+
+// DynamoDBStream reads the changes of a DynamoDB table from its stream. It reads all shards of the
+// stream, and the shards that are split from them, in order. The position in the stream is only kept
+// in memory, so a new DynamoDBStream starts at the end of the stream, or at the oldest change when
+// it is created with FromTrimHorizon.
+type DynamoDBStream struct {
+	svc          *dynamodbstreams.DynamoDBStreams
+	arn          string
+	iteratorType string
+	shards       map[string]*shard
+	limit        int64
+}
+
+// NewDynamoDBStream creates a DynamoDBStream for the stream with the given ARN, which starts with
+// the changes made after the first Read.
+func NewDynamoDBStream(svc *dynamodbstreams.DynamoDBStreams, arn string) *DynamoDBStream {
+	return &DynamoDBStream{
+		svc:          svc,
+		arn:          arn,
+		iteratorType: dynamodbstreams.ShardIteratorTypeLatest,
+		shards:       make(map[string]*shard),
+		limit:        1000,
+	}
+}
+
+// FromTrimHorizon makes the DynamoDBStream start with the oldest change in the stream, which is at
+// most 24 hours old.
+func (d *DynamoDBStream) FromTrimHorizon() *DynamoDBStream {
+	d.iteratorType = dynamodbstreams.ShardIteratorTypeTrimHorizon
+	return d
+}
+
+// Read returns the next changes of every shard of which the parent has been read completely. The
+// changes of a single shard are in order. Until Commit is called, Read returns the same changes.
+func (d *DynamoDBStream) Read(ctx context.Context) ([]Change, error) {
+	if err := d.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for id, s := range d.shards {
+		if s.done || !d.ready(s) {
+			continue
+		}
+
+		if len(s.iterator) == 0 {
+			if err := d.iterate(ctx, id, s); err != nil {
+				return nil, err
+			}
+		}
+
+		gro, err := d.svc.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: aws.String(s.iterator),
+			Limit:         aws.Int64(d.limit),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range gro.Records {
+			changes = append(changes, ChangeFromDynamoDB(r))
+		}
+		s.next = aws.StringValue(gro.NextShardIterator)
+		if len(s.next) == 0 {
+			// A closed shard has no next iterator once all of its changes were read
+			s.next = "-"
+		}
+	}
+
+	return changes, nil
+}
+
+// Commit moves every shard past the changes returned by the last Read.
+func (d *DynamoDBStream) Commit(ctx context.Context) error {
+	for _, s := range d.shards {
+		switch s.next {
+		case "":
+			continue
+		case "-":
+			s.done = true
+		default:
+			s.iterator = s.next
+		}
+		s.next = ""
+	}
+
+	return nil
+}
+
+// ready reports whether the parent of the shard has been read completely.
+func (d *DynamoDBStream) ready(s *shard) bool {
+	parent, ok := d.shards[s.parent]
+	return !ok || parent.done
+}
+
+// refresh adds the shards that were created since the last Read.
+func (d *DynamoDBStream) refresh(ctx context.Context) error {
+	input := &dynamodbstreams.DescribeStreamInput{
+		StreamArn: aws.String(d.arn),
+	}
+
+	for {
+		dso, err := d.svc.DescribeStreamWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range dso.StreamDescription.Shards {
+			id := aws.StringValue(s.ShardId)
+			if _, ok := d.shards[id]; !ok {
+				d.shards[id] = &shard{parent: aws.StringValue(s.ParentShardId)}
+			}
+		}
+
+		if dso.StreamDescription.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = dso.StreamDescription.LastEvaluatedShardId
+	}
+
+	return nil
+}
+
+// iterate gets the first iterator of a shard. A shard that was split from a shard this stream read
+// starts at its oldest change, so no changes are missed, other shards start at the configured position.
+func (d *DynamoDBStream) iterate(ctx context.Context, id string, s *shard) error {
+	iteratorType := dynamodbstreams.ShardIteratorTypeTrimHorizon
+	if _, ok := d.shards[s.parent]; !ok {
+		iteratorType = d.iteratorType
+	}
+
+	gsio, err := d.svc.GetShardIteratorWithContext(ctx, &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(d.arn),
+		ShardId:           aws.String(id),
+		ShardIteratorType: aws.String(iteratorType),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.iterator = aws.StringValue(gsio.ShardIterator)
+	return nil
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/outbox"
+)
+
+// Events returns the events of a change, which are:
+//
+//   - ProductUpdated when a product is added, changed, or removed
+//   - UserRegistered when a user is added
+//   - OrderUpdated when an order is added or its status changes
+//   - CartAbandoned when a cart with items is removed because its TTL passed
+//
+// Changes to other partitions, and changes that only change the Version or TTL of a record, don't
+// have events.
+func Events(c Change) ([]outbox.Message, error) {
+	// Streams that only contain the keys of the records have no data to create events from
+	if c.Old == nil && c.New == nil {
+		return nil, nil
+	}
+
+	if c.Operation == Modify && c.Old != nil && c.New != nil && c.Old.Payload == c.New.Payload {
+		return nil, nil
+	}
+
+	switch c.Record().PK {
+	case datastore.PartitionProduct:
+		return productEvents(c)
+	case datastore.PartitionUser:
+		return userEvents(c)
+	case datastore.PartitionOrder:
+		return orderEvents(c)
+	case datastore.PartitionCart:
+		return cartEvents(c)
+	default:
+		return nil, nil
+	}
+}
+
+// metadata returns the metadata of an event created by the Processor.
+func metadata(domain string, name string) acmeserverless.Metadata {
+	return acmeserverless.Metadata{
+		Domain: domain,
+		Source: Source,
+		Type:   name,
+		Status: acmeserverless.DefaultSuccessStatus,
+	}
+}
+
+// message wraps a single event into a message.
+func message(name string, md acmeserverless.Metadata, event interface{}) ([]outbox.Message, error) {
+	m, err := outbox.NewMessage(name, md, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return []outbox.Message{m}, nil
+}
+
+func productEvents(c Change) ([]outbox.Message, error) {
+	var data acmeserverless.ProductChange
+
+	if c.Old != nil {
+		old, err := acmeserverless.UnmarshalCatalogItem(c.Old.Payload)
+		if err != nil {
+			return nil, err
+		}
+		data.Previous = &old
+	}
+
+	if c.New != nil {
+		product, err := acmeserverless.UnmarshalCatalogItem(c.New.Payload)
+		if err != nil {
+			return nil, err
+		}
+		data.Product = product
+	} else {
+		data.Product = *data.Previous
+		data.Previous = nil
+		data.Deleted = true
+	}
+
+	md := metadata(acmeserverless.CatalogDomain, acmeserverless.ProductUpdatedEventName)
+	return message(acmeserverless.ProductUpdatedEventName, md, acmeserverless.ProductUpdated{Metadata: md, Data: data})
+}
+
+func userEvents(c Change) ([]outbox.Message, error) {
+	if c.Operation != Insert {
+		return nil, nil
+	}
+
+	usr, err := acmeserverless.UnmarshalUser(c.New.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	md := metadata(acmeserverless.UserDomain, acmeserverless.UserRegisteredEventName)
+	return message(acmeserverless.UserRegisteredEventName, md, acmeserverless.UserRegistered{
+		Metadata: md,
+		Data: acmeserverless.UserRegistration{
+			ID:        usr.ID,
+			Username:  usr.Username,
+			Firstname: usr.Firstname,
+			Lastname:  usr.Lastname,
+			Email:     usr.Email,
+		},
+	})
+}
+
+func orderEvents(c Change) ([]outbox.Message, error) {
+	if c.New == nil {
+		return nil, nil
+	}
+
+	ord, err := acmeserverless.UnmarshalOrder(c.New.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	data := acmeserverless.OrderChange{
+		OrderID: ord.OrderID,
+		UserID:  ord.UserID,
+		Total:   ord.Total,
+	}
+	if ord.Status != nil {
+		data.Status = *ord.Status
+	}
+
+	if c.Old != nil {
+		old, err := acmeserverless.UnmarshalOrder(c.Old.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if old.Status != nil {
+			data.PreviousStatus = *old.Status
+		}
+		if data.PreviousStatus == data.Status {
+			return nil, nil
+		}
+	}
+
+	md := metadata(acmeserverless.OrderDomain, acmeserverless.OrderUpdatedEventName)
+	return message(acmeserverless.OrderUpdatedEventName, md, acmeserverless.OrderUpdated{Metadata: md, Data: data})
+}
+
+func cartEvents(c Change) ([]outbox.Message, error) {
+	if c.Operation != Remove || !c.Expired {
+		return nil, nil
+	}
+
+	event, abandoned, err := datastore.CartAbandonedEvent(*c.Old, Source)
+	if err != nil || !abandoned {
+		return nil, err
+	}
+
+	return message(acmeserverless.CartAbandonedEventName, event.Metadata, event)
+}
@@ -0,0 +1,213 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless/datastore"
+)
+
+// RecordingStore is a datastore.Store that records every change it makes to the store it wraps, and
+// provides those changes as a Stream. It is a local stand-in for DynamoDB Streams, so the events of
+// changes can be tested with a MemoryStore or a local database. Changes are recorded after they were
+// made, by reading the record before and after the write, so concurrent writes to the same record may
+// be recorded in another order than they were made.
+type RecordingStore struct {
+	store datastore.Store
+
+	mu      sync.Mutex
+	seq     int
+	changes []Change
+	read    int
+}
+
+// NewRecordingStore creates a RecordingStore that wraps s.
+func NewRecordingStore(s datastore.Store) *RecordingStore {
+	return &RecordingStore{
+		store: s,
+	}
+}
+
+// Get returns the record with the given partition and sort key, or ErrNotFound.
+func (r *RecordingStore) Get(ctx context.Context, pk string, sk string) (datastore.Record, error) {
+	return r.store.Get(ctx, pk, sk)
+}
+
+// List returns all records in a partition.
+func (r *RecordingStore) List(ctx context.Context, pk string) ([]datastore.Record, error) {
+	return r.store.List(ctx, pk)
+}
+
+// QueryKeyID returns all records in a partition with the given KeyID.
+func (r *RecordingStore) QueryKeyID(ctx context.Context, pk string, keyID string) ([]datastore.Record, error) {
+	return r.store.QueryKeyID(ctx, pk, keyID)
+}
+
+// Put creates or replaces a record, and records the change.
+func (r *RecordingStore) Put(ctx context.Context, rec datastore.Record) error {
+	old := r.get(ctx, rec.PK, rec.SK)
+	if err := r.store.Put(ctx, rec); err != nil {
+		return err
+	}
+
+	r.record(old, r.get(ctx, rec.PK, rec.SK), false)
+	return nil
+}
+
+// PutIfVersion writes the record if the stored record has the Version of rec, and records the
+// change. The wrapped store must be a datastore.VersionedStore.
+func (r *RecordingStore) PutIfVersion(ctx context.Context, rec datastore.Record) (datastore.Record, error) {
+	vs, ok := r.store.(datastore.VersionedStore)
+	if !ok {
+		return datastore.Record{}, fmt.Errorf("stream: %T doesn't support versioned writes", r.store)
+	}
+
+	old := r.get(ctx, rec.PK, rec.SK)
+	written, err := vs.PutIfVersion(ctx, rec)
+	if err != nil {
+		return written, err
+	}
+
+	r.record(old, &written, false)
+	return written, nil
+}
+
+// PutBatch writes the records, and records a change for each of them. When the wrapped store isn't a
+// datastore.BatchWriter, the records are written one by one.
+func (r *RecordingStore) PutBatch(ctx context.Context, recs []datastore.Record) error {
+	olds := make([]*datastore.Record, len(recs))
+	for i, rec := range recs {
+		olds[i] = r.get(ctx, rec.PK, rec.SK)
+	}
+
+	var err error
+	if bw, ok := r.store.(datastore.BatchWriter); ok {
+		err = bw.PutBatch(ctx, recs)
+	} else {
+		for _, rec := range recs {
+			if perr := r.store.Put(ctx, rec); perr != nil {
+				err = perr
+			}
+		}
+	}
+
+	// Only the records that are stored now have changed, even when some of them failed
+	for i, rec := range recs {
+		if now := r.get(ctx, rec.PK, rec.SK); now != nil && (olds[i] == nil || *now != *olds[i]) {
+			r.record(olds[i], now, false)
+		}
+	}
+
+	return err
+}
+
+// Delete removes a record, and records the change when it existed.
+func (r *RecordingStore) Delete(ctx context.Context, pk string, sk string) error {
+	old := r.get(ctx, pk, sk)
+	if err := r.store.Delete(ctx, pk, sk); err != nil {
+		return err
+	}
+
+	if old != nil {
+		r.record(old, nil, false)
+	}
+	return nil
+}
+
+// DeleteIfVersion removes the record if it has the given version, and records the change. The
+// wrapped store must be a datastore.VersionedStore.
+func (r *RecordingStore) DeleteIfVersion(ctx context.Context, pk string, sk string, version int64) error {
+	vs, ok := r.store.(datastore.VersionedStore)
+	if !ok {
+		return fmt.Errorf("stream: %T doesn't support versioned writes", r.store)
+	}
+
+	old := r.get(ctx, pk, sk)
+	if err := vs.DeleteIfVersion(ctx, pk, sk, version); err != nil {
+		return err
+	}
+
+	r.record(old, nil, false)
+	return nil
+}
+
+// Expire removes the records in the partitions whose TTL passed at now, like DynamoDB does, and
+// records their changes as expired.
+func (r *RecordingStore) Expire(ctx context.Context, now time.Time, partitions ...string) error {
+	for _, pk := range partitions {
+		recs, err := r.store.List(ctx, pk)
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range recs {
+			if rec.TTL == 0 || rec.TTL > now.Unix() {
+				continue
+			}
+
+			if err := r.store.Delete(ctx, rec.PK, rec.SK); err != nil {
+				return err
+			}
+
+			old := rec
+			r.record(&old, nil, true)
+		}
+	}
+
+	return nil
+}
+
+// Read returns the changes that were recorded since the last Commit.
+func (r *RecordingStore) Read(ctx context.Context) ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.read = len(r.changes)
+	return append([]Change(nil), r.changes...), nil
+}
+
+// Commit removes the changes returned by the last Read.
+func (r *RecordingStore) Commit(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.changes = r.changes[r.read:]
+	r.read = 0
+	return nil
+}
+
+// get returns the stored record, or nil when it doesn't exist or can't be read.
+func (r *RecordingStore) get(ctx context.Context, pk string, sk string) *datastore.Record {
+	rec, err := r.store.Get(ctx, pk, sk)
+	if err != nil {
+		return nil
+	}
+
+	return &rec
+}
+
+// record adds a change from old to cur.
+func (r *RecordingStore) record(old *datastore.Record, cur *datastore.Record, expired bool) {
+	op := Modify
+	switch {
+	case old == nil:
+		op = Insert
+	case cur == nil:
+		op = Remove
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seq++
+	r.changes = append(r.changes, Change{
+		ID:        strconv.Itoa(r.seq),
+		Operation: op,
+		Old:       old,
+		New:       cur,
+		Expired:   expired,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/messaging"
+	"github.com/retgits/acme-serverless/outbox"
+	"github.com/retgits/acme-serverless/stream"
+)
+
+var (
+	region    string
+	streamARN string
+	from      string
+	publisher string
+	queue     string
+	bus       string
+	interval  time.Duration
+	keyFile   string
+	kmsKeyID  string
+)
+
+// logPublisher writes the messages to the log instead of sending them.
+type logPublisher struct{}
+
+func (logPublisher) Publish(ctx context.Context, m outbox.Message) (string, error) {
+	log.Printf("%s: %s", m.Name, string(m.Payload))
+	return m.ID, nil
+}
+
+func main() {
+	// Read flags
+	flag.StringVar(&region, "region", "us-west-2", "The AWS region of the table and the queue or event bus")
+	flag.StringVar(&streamARN, "stream-arn", "", "The ARN of the stream of the DynamoDB table (required)")
+	flag.StringVar(&from, "from", "latest", "Where to start reading the stream: latest or trim_horizon")
+	flag.StringVar(&publisher, "publisher", "log", "Where to send the events: log, sqs, or eventbridge")
+	flag.StringVar(&queue, "queue", "", "The URL of the Amazon SQS queue (required for sqs)")
+	flag.StringVar(&bus, "bus", "default", "The name of the Amazon EventBridge event bus (optional for eventbridge)")
+	flag.DurationVar(&interval, "interval", time.Second, "How often to read the stream")
+	flag.StringVar(&keyFile, "key-file", "", "A file with the master key the sensitive fields in the table are encrypted with (optional)")
+	flag.StringVar(&kmsKeyID, "kms-key-id", "", "The AWS KMS key the sensitive fields in the table are encrypted with (optional)")
+	flag.Parse()
+
+	if len(streamARN) == 0 {
+		log.Fatalf("Error: the 'stream-arn' flag must be set")
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	s := stream.NewDynamoDBStream(dynamodbstreams.New(sess), streamARN)
+	switch from {
+	case "latest":
+	case "trim_horizon":
+		s.FromTrimHorizon()
+	default:
+		log.Fatalf("Error: unknown position %q, must be one of latest or trim_horizon", from)
+	}
+
+	p, err := newPublisher()
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	processor := stream.NewProcessor(s, p)
+	switch {
+	case len(keyFile) > 0:
+		keys, err := datastore.LoadKeyFile(keyFile)
+		if err != nil {
+			log.Fatalf("Error: %s", err.Error())
+		}
+		processor.WithDecrypter(datastore.NewEncryptedStore(nil, keys))
+	case len(kmsKeyID) > 0:
+		processor.WithDecrypter(datastore.NewEncryptedStore(nil, datastore.NewKMSKeyProvider(kms.New(sess), kmsKeyID)))
+	}
+
+	log.Printf("reading changes from %s", streamARN)
+	if err := processor.Poll(context.Background(), interval); err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+}
+
+// newPublisher creates the publisher selected with the publisher flag.
+func newPublisher() (outbox.Publisher, error) {
+	switch publisher {
+	case "log":
+		return logPublisher{}, nil
+	case messaging.SQS, messaging.EventBridge:
+		if publisher == messaging.SQS && len(queue) == 0 {
+			return nil, fmt.Errorf("the 'queue' flag must be set")
+		}
+		p, err := messaging.NewPublisher(messaging.Config{
+			Transport: publisher,
+			Region:    region,
+			Queue:     queue,
+			Bus:       bus,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewMessagingPublisher(p), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher %q, must be one of log, sqs, or eventbridge", publisher)
+	}
+}
@@ -0,0 +1,172 @@
+// Package stream turns the changes to the single table of the ACME Serverless Fitness Shop into
+// events, so other services can react to new users, changes to the catalog, and orders that change
+// status, without the services that make those changes having to send events themselves. Changes are
+// read from DynamoDB Streams, or from a RecordingStore when testing without AWS.
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/outbox"
+)
+
+// Operation is the kind of change to a record.
+type Operation string
+
+const (
+	// Insert means the record was created.
+	Insert Operation = "INSERT"
+
+	// Modify means an existing record was changed.
+	Modify Operation = "MODIFY"
+
+	// Remove means the record was removed.
+	Remove Operation = "REMOVE"
+)
+
+// Source is the source in the metadata of the events of a Processor.
+const Source = "StreamProcessor"
+
+// Change is a single change to a record in the single table.
+type Change struct {
+	// ID uniquely identifies the change, like the event ID of a DynamoDB Streams record.
+	ID string
+
+	// Operation is the kind of change.
+	Operation Operation
+
+	// Old is the record before the change. It is nil for an Insert.
+	Old *datastore.Record
+
+	// New is the record after the change. It is nil for a Remove.
+	New *datastore.Record
+
+	// Expired is true when the record was removed because its TTL passed.
+	Expired bool
+}
+
+// Record returns the record after the change, or before the change when it was removed.
+func (c Change) Record() datastore.Record {
+	if c.New != nil {
+		return *c.New
+	}
+	if c.Old != nil {
+		return *c.Old
+	}
+	return datastore.Record{}
+}
+
+// Stream provides the changes to the single table, in the order they were made.
+type Stream interface {
+	// Read returns the changes that were made since the last commit. It returns the same changes
+	// until they are committed, and no changes when there are none.
+	Read(ctx context.Context) ([]Change, error)
+
+	// Commit marks the changes returned by the last Read as processed.
+	Commit(ctx context.Context) error
+}
+
+// Decrypter decrypts the encrypted fields of a record, like a datastore.EncryptedStore does.
+type Decrypter interface {
+	Decrypt(ctx context.Context, rec datastore.Record) (datastore.Record, error)
+}
+
+// Processor publishes the events of the changes in a Stream.
+type Processor struct {
+	stream    Stream
+	publisher outbox.Publisher
+	decrypter Decrypter
+}
+
+// NewProcessor creates a Processor that reads the changes from stream and sends their events to
+// publisher.
+func NewProcessor(stream Stream, publisher outbox.Publisher) *Processor {
+	return &Processor{
+		stream:    stream,
+		publisher: publisher,
+	}
+}
+
+// WithDecrypter makes the Processor decrypt the records of the changes before it creates their
+// events, which is needed when the records in the table are encrypted by a datastore.EncryptedStore.
+func (p *Processor) WithDecrypter(d Decrypter) *Processor {
+	p.decrypter = d
+	return p
+}
+
+// Run reads one batch of changes and publishes their events, and returns the number of events that
+// were published. The changes are only committed after all events were published, so when Run fails
+// the changes are read again by the next Run and consumers must be idempotent.
+func (p *Processor) Run(ctx context.Context) (int, error) {
+	changes, err := p.stream.Read(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error reading stream: %s", err.Error())
+	}
+
+	n := 0
+	for _, c := range changes {
+		if err := p.decrypt(ctx, &c); err != nil {
+			return n, fmt.Errorf("error decrypting change %s: %s", c.ID, err.Error())
+		}
+
+		msgs, err := Events(c)
+		if err != nil {
+			return n, fmt.Errorf("error converting change %s: %s", c.ID, err.Error())
+		}
+
+		for _, m := range msgs {
+			if _, err := p.publisher.Publish(ctx, m); err != nil {
+				return n, fmt.Errorf("error publishing %s of change %s: %s", m.Name, c.ID, err.Error())
+			}
+			n++
+		}
+	}
+
+	if err := p.stream.Commit(ctx); err != nil {
+		return n, fmt.Errorf("error committing stream: %s", err.Error())
+	}
+
+	return n, nil
+}
+
+// decrypt decrypts the records of the change when the Processor has a Decrypter.
+func (p *Processor) decrypt(ctx context.Context, c *Change) error {
+	if p.decrypter == nil {
+		return nil
+	}
+
+	for _, rec := range []**datastore.Record{&c.Old, &c.New} {
+		if *rec == nil {
+			continue
+		}
+
+		dec, err := p.decrypter.Decrypt(ctx, **rec)
+		if err != nil {
+			return err
+		}
+		*rec = &dec
+	}
+
+	return nil
+}
+
+// Poll calls Run every interval until the context is cancelled, or Run returns an error.
+func (p *Processor) Poll(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Run(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless/datastore"
+	"github.com/retgits/acme-serverless/outbox"
+)
+
+// recorder is an outbox.Publisher that keeps the messages it is sent.
+type recorder struct {
+	msgs []outbox.Message
+}
+
+func (r *recorder) Publish(ctx context.Context, m outbox.Message) (string, error) {
+	r.msgs = append(r.msgs, m)
+	return m.ID, nil
+}
+
+// run processes the changes recorded by s and returns the messages that were published.
+func run(t *testing.T, s *RecordingStore) []outbox.Message {
+	t.Helper()
+
+	r := &recorder{}
+	if _, err := NewProcessor(s, r).Run(context.Background()); err != nil {
+		t.Fatalf("error processing changes: %s", err.Error())
+	}
+	return r.msgs
+}
+
+func assertNames(t *testing.T, msgs []outbox.Message, want ...string) {
+	t.Helper()
+
+	got := []string{}
+	for _, m := range msgs {
+		got = append(got, m.Name)
+	}
+	if len(want) == 0 {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("published %v, want %v", got, want)
+	}
+}
+
+func put(t *testing.T, s datastore.Store, rec datastore.Record, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("error creating record: %s", err.Error())
+	}
+	if err := s.Put(context.Background(), rec); err != nil {
+		t.Fatalf("error writing record: %s", err.Error())
+	}
+}
+
+func TestChangeFromDynamoDB(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"PK":      {S: aws.String(datastore.PartitionCart)},
+		"SK":      {S: aws.String("user-1")},
+		"Payload": {S: aws.String(`[]`)},
+		"TTL":     {N: aws.String("1590000000")},
+	}
+
+	tests := []struct {
+		name     string
+		identity *dynamodbstreams.Identity
+		expired  bool
+	}{
+		{"removed by the TTL", &dynamodbstreams.Identity{Type: aws.String("Service"), PrincipalId: aws.String(ttlPrincipal)}, true},
+		{"removed by a user", nil, false},
+		{"removed by another service", &dynamodbstreams.Identity{Type: aws.String("Service"), PrincipalId: aws.String("lambda.amazonaws.com")}, false},
+	}
+
+	for _, tt := range tests {
+		c := ChangeFromDynamoDB(&dynamodbstreams.Record{
+			EventID:      aws.String("event-1"),
+			EventName:    aws.String(string(Remove)),
+			Dynamodb:     &dynamodbstreams.StreamRecord{OldImage: item},
+			UserIdentity: tt.identity,
+		})
+
+		if c.Expired != tt.expired {
+			t.Errorf("%s: expired is %t, want %t", tt.name, c.Expired, tt.expired)
+		}
+		if c.ID != "event-1" || c.Operation != Remove || c.New != nil {
+			t.Errorf("%s: change is %+v, want the removal of event-1", tt.name, c)
+		}
+		if c.Old == nil || c.Old.SK != "user-1" || c.Old.TTL != 1590000000 {
+			t.Errorf("%s: old record is %+v, want the cart of user-1", tt.name, c.Old)
+		}
+	}
+}
+
+func TestEventsOfChanges(t *testing.T) {
+	ctx := context.Background()
+	s := NewRecordingStore(datastore.NewMemoryStore())
+
+	// A new user is registered, a changed user isn't
+	usr := acmeserverless.User{ID: "user-1", Username: "jdoe", Firstname: "John", Password: "secret"}
+	rec, err := datastore.UserRecord(usr)
+	put(t, s, rec, err)
+	usr.Lastname = "Doe"
+	rec, err = datastore.UserRecord(usr)
+	put(t, s, rec, err)
+
+	msgs := run(t, s)
+	assertNames(t, msgs, acmeserverless.UserRegisteredEventName)
+	var registered acmeserverless.UserRegistered
+	json.Unmarshal(msgs[0].Payload, &registered)
+	if registered.Data.Username != "jdoe" || registered.Metadata.Source != Source {
+		t.Fatalf("event is %+v, want the registration of jdoe", registered)
+	}
+	if containsPassword(msgs[0].Payload) {
+		t.Fatal("the event of a new user contains its password")
+	}
+
+	// Every change to a product is an update, with the product before the change
+	product := acmeserverless.CatalogItem{ID: "product-1", Name: "Yoga mat", Price: 10}
+	rec, err = datastore.ProductRecord(product)
+	put(t, s, rec, err)
+	product.Price = 12
+	rec, err = datastore.ProductRecord(product)
+	put(t, s, rec, err)
+	if err := s.Delete(ctx, datastore.PartitionProduct, "product-1"); err != nil {
+		t.Fatalf("error removing product: %s", err.Error())
+	}
+
+	msgs = run(t, s)
+	assertNames(t, msgs, acmeserverless.ProductUpdatedEventName, acmeserverless.ProductUpdatedEventName, acmeserverless.ProductUpdatedEventName)
+	var changed, deleted acmeserverless.ProductUpdated
+	json.Unmarshal(msgs[1].Payload, &changed)
+	json.Unmarshal(msgs[2].Payload, &deleted)
+	if changed.Data.Previous == nil || changed.Data.Previous.Price != 10 || changed.Data.Product.Price != 12 {
+		t.Fatalf("change is %+v, want the price change from 10 to 12", changed.Data)
+	}
+	if !deleted.Data.Deleted || deleted.Data.Product.ID != "product-1" {
+		t.Fatalf("change is %+v, want the removal of product-1", deleted.Data)
+	}
+
+	// An order is updated when it is added or its status changes
+	ord := acmeserverless.Order{OrderID: "order-1", UserID: "user-1", Status: aws.String("Pending")}
+	rec, err = datastore.OrderRecord(ord)
+	put(t, s, rec, err)
+	ord.Delivery = "UPS"
+	rec, err = datastore.OrderRecord(ord)
+	put(t, s, rec, err)
+	ord.Status = aws.String("Paid")
+	rec, err = datastore.OrderRecord(ord)
+	put(t, s, rec, err)
+
+	msgs = run(t, s)
+	assertNames(t, msgs, acmeserverless.OrderUpdatedEventName, acmeserverless.OrderUpdatedEventName)
+	var paid acmeserverless.OrderUpdated
+	json.Unmarshal(msgs[1].Payload, &paid)
+	if paid.Data.PreviousStatus != "Pending" || paid.Data.Status != "Paid" {
+		t.Fatalf("change is %+v, want the status change from Pending to Paid", paid.Data)
+	}
+
+	// A change of only the TTL has no event
+	rec.TTL = time.Now().Add(time.Hour).Unix()
+	put(t, s, rec, nil)
+	assertNames(t, run(t, s))
+}
+
+func containsPassword(payload json.RawMessage) bool {
+	var event struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(payload, &event)
+	_, ok := event.Data["password"]
+	return ok
+}
+
+func TestCartAbandonedWhenExpired(t *testing.T) {
+	ctx := context.Background()
+	s := NewRecordingStore(datastore.NewMemoryStore())
+	now := time.Now()
+
+	items := acmeserverless.CartItems{
+		{ItemID: aws.String("product-1"), Name: "Yoga mat", Quantity: 2, Price: 10},
+		{ItemID: aws.String("product-2"), Name: "Water bottle", Quantity: 1, Price: 5},
+	}
+	for _, userID := range []string{"user-1", "user-2"} {
+		rec, err := datastore.CartRecord(userID, items)
+		rec.TTL = now.Add(-time.Minute).Unix()
+		put(t, s, rec, err)
+	}
+	rec, err := datastore.CartRecord("user-3", acmeserverless.CartItems{})
+	rec.TTL = now.Add(-time.Minute).Unix()
+	put(t, s, rec, err)
+	rec, err = datastore.CartRecord("user-4", items)
+	put(t, s, rec, err)
+	assertNames(t, run(t, s))
+
+	// A cart that is removed by a user isn't abandoned
+	if err := s.Delete(ctx, datastore.PartitionCart, "user-2"); err != nil {
+		t.Fatalf("error removing cart: %s", err.Error())
+	}
+	assertNames(t, run(t, s))
+
+	// Only the expired cart with items is abandoned
+	if err := s.Expire(ctx, now, datastore.PartitionCart); err != nil {
+		t.Fatalf("error expiring carts: %s", err.Error())
+	}
+	msgs := run(t, s)
+	assertNames(t, msgs, acmeserverless.CartAbandonedEventName)
+
+	var event acmeserverless.CartAbandoned
+	json.Unmarshal(msgs[0].Payload, &event)
+	if event.Data.UserID != "user-1" || event.Data.ItemTotal != 3 || event.Data.ValueTotal != 25 {
+		t.Fatalf("event is %+v, want the cart of user-1 with 3 items worth 25", event.Data)
+	}
+	if event.Metadata.Source != Source || event.Metadata.Domain != acmeserverless.CartDomain {
+		t.Fatalf("metadata is %+v, want the cart domain and source %s", event.Metadata, Source)
+	}
+
+	if _, err := s.Get(ctx, datastore.PartitionCart, "user-4"); err != nil {
+		t.Fatalf("the cart that didn't expire was removed: %s", err.Error())
+	}
+}
@@ -151,3 +151,42 @@ type UserDetailsResponse struct {
 func (r *UserDetailsResponse) Marshal() ([]byte, error) {
 	return json.Marshal(r)
 }
+
+// UserRegistered is the event sent when a new user registered with the shop.
+type UserRegistered struct {
+	// Metadata for the event.
+	Metadata Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data UserRegistration `json:"data"`
+}
+
+// UserRegistration is the data of a new user. It doesn't contain the password of the user.
+type UserRegistration struct {
+	// ID is the unique identifier of the user in the shop
+	ID string `json:"id"`
+
+	// Username is the username of the user
+	Username string `json:"username"`
+
+	// Firstname is the firstname of the user
+	Firstname string `json:"firstname"`
+
+	// Lastname is the lastname of the user
+	Lastname string `json:"lastname"`
+
+	// Email is the email address of the user
+	Email string `json:"email"`
+}
+
+// UnmarshalUserRegistered parses the JSON-encoded data and stores the result in a UserRegistered.
+func UnmarshalUserRegistered(data []byte) (UserRegistered, error) {
+	var r UserRegistered
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of UserRegistered.
+func (e *UserRegistered) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}